@@ -0,0 +1,47 @@
+package mastodon
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+
+	"github.com/tidwall/gjson"
+)
+
+// Account contains details about the Mastodon account a broadcaster posts as.
+type Account struct {
+	// Id is the ID of the account.
+	Id string `json:"id"`
+	// Username is the username of the account.
+	Username string `json:"username"`
+	// Acct is the Webfinger account URI of the account, relative to its instance.
+	Acct string `json:"acct"`
+	// URL is the location of the account's profile page.
+	URL string `json:"url"`
+}
+
+// VerifyCredentials returns the `Account` associated with the credentials used by 'b'. It returns an
+// error if those credentials are not valid.
+func (b *MastodonBroadcaster) VerifyCredentials(ctx context.Context) (*Account, error) {
+
+	body, err := b.executeJSON(ctx, "GET", "/api/v1/accounts/verify_credentials", &url.Values{})
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to verify account credentials, %w", withPhase(err, PhaseVerifyCredentials))
+	}
+
+	id_rsp := gjson.GetBytes(body, "id")
+
+	if !id_rsp.Exists() {
+		return nil, fmt.Errorf("Failed to derive account ID from response")
+	}
+
+	acct := &Account{
+		Id:       id_rsp.String(),
+		Username: gjson.GetBytes(body, "username").String(),
+		Acct:     gjson.GetBytes(body, "acct").String(),
+		URL:      gjson.GetBytes(body, "url").String(),
+	}
+
+	return acct, nil
+}
@@ -0,0 +1,207 @@
+package mastodon
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+
+	"github.com/aaronland/go-broadcaster"
+	"github.com/tidwall/gjson"
+)
+
+// AccountResult describes the outcome of broadcasting a message with one of the additional accounts of a
+// `MastodonBroadcaster`.
+type AccountResult struct {
+	// Account is the name of the account.
+	Account string `json:"account"`
+	// Boost is true if the account boosted the status posted by the primary account rather than posting its own.
+	Boost bool `json:"boost,omitempty"`
+	// Result describes the status, or boost, posted by the account. It is nil if the account failed.
+	Result *Result `json:"result,omitempty"`
+	// Error is the message of the error, if any, that caused the account to fail.
+	Error string `json:"error,omitempty"`
+	// Err is the error, if any, that caused the account to fail.
+	Err error `json:"-"`
+}
+
+// AccountsError is returned when a `MastodonBroadcaster` with additional accounts fails to post a message with its
+// primary account. It wraps the primary account's error and describes the outcome for each additional account.
+type AccountsError struct {
+	// Err is the error returned by the primary account.
+	Err error
+	// Accounts describe the outcome for each additional account.
+	Accounts []*AccountResult
+}
+
+func (e *AccountsError) Error() string {
+
+	ok := 0
+
+	for _, a := range e.Accounts {
+
+		if a.Err == nil {
+			ok += 1
+		}
+	}
+
+	return fmt.Sprintf("%v (%d of %d additional accounts succeeded)", e.Err, ok, len(e.Accounts))
+}
+
+func (e *AccountsError) Unwrap() error {
+	return e.Err
+}
+
+// accountName returns the name of the additional account at position 'idx'.
+func (b *MastodonBroadcaster) accountName(idx int) string {
+
+	name := b.accounts[idx].name
+
+	if name == "" {
+		name = fmt.Sprintf("account-%d", idx+1)
+	}
+
+	return name
+}
+
+// postAccounts broadcasts 'msg' with each of the additional accounts of 'b', after it was posted by the primary
+// account with the outcome described by 'primary' and 'primary_err'. In amplification mode each account boosts
+// the primary account's status instead. Accounts succeed or fail independently of each other. If the primary
+// account failed an `AccountsError` is returned, otherwise 'primary' is returned with the outcome for each
+// account assigned to its `Accounts` property.
+func (b *MastodonBroadcaster) postAccounts(ctx context.Context, msg *broadcaster.Message, opts *MessageOptions, primary *Result, primary_err error) (*Result, error) {
+
+	results := make([]*AccountResult, len(b.accounts))
+
+	for idx, acct := range b.accounts {
+
+		name := b.accountName(idx)
+
+		acct_rsp := &AccountResult{
+			Account: name,
+			Boost:   b.amplify,
+		}
+
+		var rsp *Result
+		var err error
+
+		switch {
+		case b.amplify && primary_err != nil:
+			err = fmt.Errorf("Primary account failed to post status")
+		case b.amplify:
+			rsp, err = acct.boost(ctx, primary)
+		default:
+			rsp, err = acct.PostMessage(ctx, msg, opts)
+		}
+
+		if err != nil {
+
+			err = b.redactor.redactError(err)
+
+			b.logger.Warn("Failed to broadcast message with account", "account", name, "error", err)
+
+			acct_rsp.Err = err
+			acct_rsp.Error = err.Error()
+
+		} else {
+			acct_rsp.Result = rsp
+		}
+
+		results[idx] = acct_rsp
+	}
+
+	if primary_err != nil {
+
+		err := &AccountsError{
+			Err:      primary_err,
+			Accounts: results,
+		}
+
+		return nil, err
+	}
+
+	primary.Account = b.name
+	primary.Accounts = results
+
+	return primary, nil
+}
+
+// boost boosts the status described by 'primary', which was posted by another account, using the account of 'b'.
+// The status is resolved using its URL so the accounts may be on different instances.
+func (b *MastodonBroadcaster) boost(ctx context.Context, primary *Result) (*Result, error) {
+
+	if b.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, b.timeout)
+		defer cancel()
+	}
+
+	if primary.ScheduledAt != "" {
+		return nil, fmt.Errorf("Scheduled statuses can not be boosted")
+	}
+
+	switch primary.Visibility {
+	case "private", "direct":
+		return nil, fmt.Errorf("Statuses with '%s' visibility can not be boosted", primary.Visibility)
+	}
+
+	// Boosts may only be public, unlisted or private
+
+	visibility := b.visibility
+
+	if visibility == "direct" {
+		visibility = "private"
+	}
+
+	args := &url.Values{}
+	args.Set("visibility", visibility)
+
+	if b.dryrun || primary.Dryrun {
+
+		b.logger.Info("Dryrun boost", "status", primary.URL, "args", args)
+
+		rsp := &Result{
+			Id:         "1",
+			Visibility: visibility,
+			Dryrun:     true,
+		}
+
+		return rsp, nil
+	}
+
+	status_url := primary.URL
+
+	if status_url == "" {
+		status_url = primary.URI
+	}
+
+	status_id, err := b.resolveStatusId(ctx, status_url)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to resolve status to boost, %w", withPhase(err, PhaseBoost))
+	}
+
+	api_method := fmt.Sprintf("/api/v1/statuses/%s/reblog", status_id)
+
+	body, err := b.executeJSON(ctx, "POST", api_method, args)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to boost status %s, %w", status_id, withPhase(err, PhaseBoost))
+	}
+
+	id_rsp := gjson.GetBytes(body, "id")
+
+	if !id_rsp.Exists() {
+		return nil, fmt.Errorf("Failed to derive boost ID from response, missing 'id' property")
+	}
+
+	rsp := &Result{
+		Id:         id_rsp.String(),
+		URL:        gjson.GetBytes(body, "reblog.url").String(),
+		URI:        gjson.GetBytes(body, "uri").String(),
+		CreatedAt:  gjson.GetBytes(body, "created_at").String(),
+		Visibility: visibility,
+	}
+
+	b.logger.Info("Mastodon boost successful", "boost ID", rsp.Id, "status ID", status_id)
+	return rsp, nil
+}
@@ -0,0 +1,253 @@
+// Package batch provides methods for implementing a command line tool for broadcasting a series of
+// messages, read from a JSONL or CSV file, to Mastodon.
+package batch
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"time"
+
+	"github.com/aaronland/go-broadcaster"
+	"github.com/aaronland/go-broadcaster-mastodon"
+	"github.com/aaronland/go-broadcaster-mastodon/message"
+	"github.com/sfomuseum/go-flags/flagset"
+)
+
+func Run(ctx context.Context) error {
+	fs := DefaultFlagSet()
+	return RunWithFlagSet(ctx, fs)
+}
+
+func RunWithFlagSet(ctx context.Context, fs *flag.FlagSet) error {
+
+	flagset.Parse(fs)
+
+	if verbose {
+		slog.SetLogLoggerLevel(slog.LevelDebug)
+		slog.Debug("Verbose logging enabled")
+	}
+
+	if broadcaster_uri == "" {
+		return fmt.Errorf("Missing -broadcaster flag")
+	}
+
+	if input == "" {
+		return fmt.Errorf("Missing -input flag")
+	}
+
+	if resume && results == "" {
+		return fmt.Errorf("-resume requires a -results file")
+	}
+
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt)
+	defer stop()
+
+	br_uri := broadcaster_uri
+
+	if dryrun {
+
+		dryrun_uri, err := mastodon.DryrunURI(br_uri, "")
+
+		if err != nil {
+			return err
+		}
+
+		br_uri = dryrun_uri
+	}
+
+	br, err := broadcaster.NewBroadcaster(ctx, br_uri)
+
+	if err != nil {
+		return fmt.Errorf("Failed to create broadcaster, %w", err)
+	}
+
+	mastodon_br, ok := br.(*mastodon.MastodonBroadcaster)
+
+	if !ok {
+		return fmt.Errorf("Broadcaster is not a Mastodon broadcaster")
+	}
+
+	// Wait for any test statuses scheduled for deletion to be deleted.
+	defer mastodon_br.Close(ctx)
+
+	rows, err := readInput()
+
+	if err != nil {
+		return err
+	}
+
+	completed := make(map[string]bool)
+
+	if resume {
+
+		c, err := readCompleted(results)
+
+		if err != nil {
+			return fmt.Errorf("Failed to read results, %w", err)
+		}
+
+		completed = c
+	}
+
+	var results_wr io.Writer = io.Discard
+
+	if results != "" {
+
+		fh, err := os.OpenFile(results, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+
+		if err != nil {
+			return fmt.Errorf("Failed to open %s for writing, %w", results, err)
+		}
+
+		defer fh.Close()
+		results_wr = fh
+	}
+
+	enc := json.NewEncoder(results_wr)
+
+	derive_opts := &message.DeriveOptions{
+		AllowPaths: true,
+		AllowURLs:  true,
+	}
+
+	if input != "-" {
+		derive_opts.Root = filepath.Dir(input)
+	}
+
+	var last_post time.Time
+	failures := 0
+
+	for _, r := range rows {
+
+		logger := slog.Default().With("row", r.Number, "key", r.Key)
+
+		if completed[r.Key] {
+			logger.Debug("Skipping row that has already been broadcast")
+			continue
+		}
+
+		if !last_post.IsZero() {
+
+			wait := interval - time.Since(last_post)
+
+			if wait > 0 {
+
+				logger.Debug("Wait before next post", "duration", wait)
+
+				select {
+				case <-ctx.Done():
+					return ctx.Err()
+				case <-time.After(wait):
+					// pass
+				}
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		default:
+			// pass
+		}
+
+		rsp := &result{
+			Row: r.Number,
+			Key: r.Key,
+		}
+
+		status, err := broadcastRow(ctx, mastodon_br, r, derive_opts)
+
+		// Pacing applies to attempts, not just successes, so that a run of failures
+		// does not hammer the Mastodon API.
+		last_post = time.Now()
+
+		rsp.Time = last_post.Format(time.RFC3339)
+
+		if err != nil {
+			rsp.Error = err.Error()
+			failures += 1
+			logger.Error("Failed to broadcast message", "error", err)
+		} else {
+			rsp.StatusId = status.Id
+			rsp.URL = status.URL
+			rsp.Dryrun = status.Dryrun
+			logger.Info("Broadcast message", "status id", status.Id, "url", status.URL)
+		}
+
+		enc_err := enc.Encode(rsp)
+
+		if enc_err != nil {
+			return fmt.Errorf("Failed to write result for row %d, %w", r.Number, enc_err)
+		}
+
+		if err != nil && !continue_on_error {
+			return fmt.Errorf("Failed to broadcast row %d, %w", r.Number, err)
+		}
+	}
+
+	if failures > 0 {
+		return fmt.Errorf("%d message(s) failed to broadcast", failures)
+	}
+
+	return nil
+}
+
+func readInput() ([]*row, error) {
+
+	input_format := format
+
+	if input_format == "" {
+
+		if input == "-" {
+			return nil, fmt.Errorf("-format is required when reading from STDIN")
+		}
+
+		f, err := deriveFormat(input)
+
+		if err != nil {
+			return nil, err
+		}
+
+		input_format = f
+	}
+
+	var r io.Reader = os.Stdin
+
+	if input != "-" {
+
+		fh, err := os.Open(input)
+
+		if err != nil {
+			return nil, fmt.Errorf("Failed to open %s, %w", input, err)
+		}
+
+		defer fh.Close()
+		r = fh
+	}
+
+	rows, err := readRows(r, input_format)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to read messages, %w", err)
+	}
+
+	return rows, nil
+}
+
+func broadcastRow(ctx context.Context, br *mastodon.MastodonBroadcaster, r *row, derive_opts *message.DeriveOptions) (*mastodon.Result, error) {
+
+	msg, opts, err := r.Message.Derive(ctx, derive_opts)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to derive message, %w", err)
+	}
+
+	return br.PostMessage(ctx, msg, opts)
+}
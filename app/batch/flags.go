@@ -0,0 +1,54 @@
+package batch
+
+import (
+	"flag"
+	"time"
+
+	"github.com/sfomuseum/go-flags/flagset"
+)
+
+// A valid aaronland/go-broadcaster-mastodon URI.
+var broadcaster_uri string
+
+// The path to a JSONL or CSV file containing messages to broadcast.
+var input string
+
+// The format of the input file.
+var format string
+
+// The minimum interval between posts.
+var interval time.Duration
+
+// Continue broadcasting remaining messages if a message fails to broadcast.
+var continue_on_error bool
+
+// The path to a JSONL file to record the results of each broadcast.
+var results string
+
+// Skip messages that have already been broadcast successfully according to the results file.
+var resume bool
+
+var dryrun bool
+
+var verbose bool
+
+func DefaultFlagSet() *flag.FlagSet {
+
+	fs := flagset.NewFlagSet("batch")
+
+	fs.StringVar(&broadcaster_uri, "broadcaster", "", "A valid aaronland/go-broadcaster-mastodon URI.")
+
+	fs.StringVar(&input, "input", "", "The path to a JSONL or CSV file containing messages to broadcast. If \"-\" messages are read from STDIN.")
+	fs.StringVar(&format, "format", "", "The format of the input file: jsonl or csv. If empty the format is derived from the input file's extension.")
+
+	fs.DurationVar(&interval, "interval", time.Minute, "The minimum interval between posts.")
+	fs.BoolVar(&continue_on_error, "continue-on-error", false, "Continue broadcasting remaining messages if a message fails to broadcast. By default processing stops at the first error.")
+
+	fs.StringVar(&results, "results", "", "The path to a JSONL file to append the result of each broadcast to.")
+	fs.BoolVar(&resume, "resume", false, "Skip messages that have already been broadcast successfully according to the -results file.")
+
+	fs.BoolVar(&dryrun, "dryrun", false, "Enable dryrun mode, overriding any ?dryrun= parameter in the broadcaster URI.")
+	fs.BoolVar(&verbose, "verbose", false, "Enable verbose (debug) logging.")
+
+	return fs
+}
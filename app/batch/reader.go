@@ -0,0 +1,299 @@
+package batch
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/aaronland/go-broadcaster-mastodon/message"
+)
+
+// The separator for multi-value CSV columns like "images" and "alt_text".
+const csv_separator = "|"
+
+// row is a message read from an input file along with its key in the results file.
+type row struct {
+	// The (1-based) position of the message in the input file.
+	Number int
+	// The key used to match the message to its results. This is the message ID, if present, or the row number.
+	Key string
+	// The message to broadcast.
+	Message *message.Message
+}
+
+// deriveFormat returns the format of 'path' derived from its extension.
+func deriveFormat(path string) (string, error) {
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".jsonl", ".ndjson":
+		return "jsonl", nil
+	case ".csv":
+		return "csv", nil
+	default:
+		return "", fmt.Errorf("Unable to derive format from %s", path)
+	}
+}
+
+// readRows reads all the messages in 'r' encoded as 'format'.
+func readRows(r io.Reader, format string) ([]*row, error) {
+
+	switch format {
+	case "jsonl":
+		return readJSONL(r)
+	case "csv":
+		return readCSV(r)
+	default:
+		return nil, fmt.Errorf("Unsupported format '%s'", format)
+	}
+}
+
+func readJSONL(r io.Reader) ([]*row, error) {
+
+	rows := make([]*row, 0)
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+
+	line := 0
+
+	for scanner.Scan() {
+
+		line += 1
+
+		ln := strings.TrimSpace(scanner.Text())
+
+		if ln == "" {
+			continue
+		}
+
+		var m *message.Message
+
+		err := json.Unmarshal([]byte(ln), &m)
+
+		if err != nil {
+			return nil, fmt.Errorf("Failed to parse line %d, %w", line, err)
+		}
+
+		if m == nil {
+			return nil, fmt.Errorf("Failed to parse line %d, message is null", line)
+		}
+
+		rows = append(rows, newRow(len(rows)+1, m))
+	}
+
+	err := scanner.Err()
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to read input, %w", err)
+	}
+
+	return rows, nil
+}
+
+func readCSV(r io.Reader) ([]*row, error) {
+
+	csv_r := csv.NewReader(r)
+
+	header, err := csv_r.Read()
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to read CSV header, %w", err)
+	}
+
+	columns := make(map[string]int)
+
+	for idx, col := range header {
+		columns[strings.TrimSpace(col)] = idx
+	}
+
+	if _, ok := columns["body"]; !ok {
+		return nil, fmt.Errorf("CSV header is missing a 'body' column")
+	}
+
+	rows := make([]*row, 0)
+
+	for {
+
+		rec, err := csv_r.Read()
+
+		if err == io.EOF {
+			break
+		}
+
+		if err != nil {
+			return nil, fmt.Errorf("Failed to read CSV row %d, %w", len(rows)+1, err)
+		}
+
+		get := func(col string) string {
+
+			idx, ok := columns[col]
+
+			if !ok || idx >= len(rec) {
+				return ""
+			}
+
+			return strings.TrimSpace(rec[idx])
+		}
+
+		getBool := func(col string) (bool, error) {
+
+			v := get(col)
+
+			if v == "" {
+				return false, nil
+			}
+
+			return strconv.ParseBool(v)
+		}
+
+		m := &message.Message{
+			Id:             get("id"),
+			Title:          get("title"),
+			Body:           get("body"),
+			Visibility:     get("visibility"),
+			ContentWarning: get("content_warning"),
+			Language:       get("language"),
+			InReplyTo:      get("in_reply_to"),
+			Quote:          get("quote"),
+			Schedule:       get("schedule"),
+			PinJob:         get("pin_job"),
+			ContentType:    get("content_type"),
+		}
+
+		for col, idx := range columns {
+
+			k, ok := strings.CutPrefix(col, "data.")
+
+			if !ok || idx >= len(rec) {
+				continue
+			}
+
+			if m.Data == nil {
+				m.Data = make(map[string]any)
+			}
+
+			m.Data[k] = rec[idx]
+		}
+
+		sensitive, err := getBool("sensitive")
+
+		if err != nil {
+			return nil, fmt.Errorf("Invalid 'sensitive' value in CSV row %d, %w", len(rows)+1, err)
+		}
+
+		m.Sensitive = sensitive
+
+		pin, err := getBool("pin")
+
+		if err != nil {
+			return nil, fmt.Errorf("Invalid 'pin' value in CSV row %d, %w", len(rows)+1, err)
+		}
+
+		m.Pin = pin
+
+		images := splitMulti(get("images"))
+		alt_text := splitMulti(get("alt_text"))
+
+		for idx, path := range images {
+
+			im := &message.Image{
+				Path: path,
+			}
+
+			if idx < len(alt_text) {
+				im.AltText = alt_text[idx]
+			}
+
+			m.Images = append(m.Images, im)
+		}
+
+		poll_options := splitMulti(get("poll_options"))
+
+		if len(poll_options) > 0 {
+
+			multiple, err := getBool("poll_multiple")
+
+			if err != nil {
+				return nil, fmt.Errorf("Invalid 'poll_multiple' value in CSV row %d, %w", len(rows)+1, err)
+			}
+
+			hide_totals, err := getBool("poll_hide_totals")
+
+			if err != nil {
+				return nil, fmt.Errorf("Invalid 'poll_hide_totals' value in CSV row %d, %w", len(rows)+1, err)
+			}
+
+			m.Poll = &message.Poll{
+				Options:    poll_options,
+				ExpiresIn:  get("poll_expires_in"),
+				Multiple:   multiple,
+				HideTotals: hide_totals,
+			}
+		}
+
+		rows = append(rows, newRow(len(rows)+1, m))
+	}
+
+	return rows, nil
+}
+
+func newRow(number int, m *message.Message) *row {
+
+	key := m.Id
+
+	if key == "" {
+		key = strconv.Itoa(number)
+	}
+
+	return &row{
+		Number:  number,
+		Key:     key,
+		Message: m,
+	}
+}
+
+// splitMulti splits 'str', the value of a multi-value CSV column, on `csv_separator`. Separators preceded by a
+// backslash, for example in alt text that contains a "|" character, are kept as part of the value.
+func splitMulti(str string) []string {
+
+	values := make([]string, 0)
+
+	if str == "" {
+		return values
+	}
+
+	escaped := `\` + csv_separator
+
+	var buf strings.Builder
+
+	for {
+
+		idx := strings.Index(str, csv_separator)
+
+		if idx == -1 {
+			buf.WriteString(str)
+			break
+		}
+
+		if strings.HasSuffix(str[:idx+1], escaped) {
+			buf.WriteString(str[:idx-1])
+			buf.WriteString(csv_separator)
+			str = str[idx+1:]
+			continue
+		}
+
+		buf.WriteString(str[:idx])
+		values = append(values, strings.TrimSpace(buf.String()))
+
+		buf.Reset()
+		str = str[idx+1:]
+	}
+
+	values = append(values, strings.TrimSpace(buf.String()))
+	return values
+}
@@ -0,0 +1,95 @@
+package batch
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// result records the outcome of broadcasting a single row.
+type result struct {
+	// The (1-based) position of the message in the input file.
+	Row int `json:"row"`
+	// The message ID, if present, or the row number.
+	Key string `json:"key"`
+	// The ID of the status that was posted.
+	StatusId string `json:"status_id,omitempty"`
+	// The URL of the status that was posted.
+	URL string `json:"url,omitempty"`
+	// The error that occurred, if the message failed to broadcast.
+	Error string `json:"error,omitempty"`
+	// Dryrun is true if the status was not actually posted.
+	Dryrun bool `json:"dryrun,omitempty"`
+	// The time the message was processed.
+	Time string `json:"time"`
+}
+
+// readCompleted returns the set of keys for rows that have already been broadcast successfully according
+// to the results file at 'path'.
+func readCompleted(path string) (map[string]bool, error) {
+
+	completed := make(map[string]bool)
+
+	r, err := os.Open(path)
+
+	if os.IsNotExist(err) {
+		return completed, nil
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to open %s, %w", path, err)
+	}
+
+	defer r.Close()
+
+	scanner := bufio.NewScanner(r)
+
+	line := 0
+
+	// A line that can not be parsed is only tolerated if it is the final line, which may have been partially
+	// written by an interrupted run. Anything else means the file is corrupt and rows might be broadcast twice.
+
+	var parse_err error
+
+	for scanner.Scan() {
+
+		line += 1
+
+		ln := strings.TrimSpace(scanner.Text())
+
+		if ln == "" {
+			continue
+		}
+
+		if parse_err != nil {
+			return nil, parse_err
+		}
+
+		var rsp *result
+
+		err := json.Unmarshal([]byte(ln), &rsp)
+
+		if err != nil {
+			parse_err = fmt.Errorf("Failed to parse line %d of %s, %w", line, path, err)
+			continue
+		}
+
+		if rsp == nil {
+			return nil, fmt.Errorf("Failed to parse line %d of %s, result is null", line, path)
+		}
+
+		if rsp.Error == "" && !rsp.Dryrun {
+			completed[rsp.Key] = true
+		}
+	}
+
+	err = scanner.Err()
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to read %s, %w", path, err)
+	}
+
+	return completed, nil
+}
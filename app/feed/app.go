@@ -0,0 +1,386 @@
+// Package feed provides methods for implementing a command line tool for cross-posting new items in
+// an RSS or Atom feed to Mastodon.
+package feed
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/aaronland/go-broadcaster"
+	"github.com/aaronland/go-broadcaster-mastodon"
+	"github.com/aaronland/go-broadcaster-mastodon/feed"
+	"github.com/aaronland/go-broadcaster-mastodon/message"
+	"github.com/sfomuseum/go-flags/flagset"
+)
+
+// The default template used to render feed items.
+const default_template = `{{ .Item.Title }}
+
+{{ .Item.Link }}`
+
+// templateVars are the variables passed to the template used to render feed items.
+type templateVars struct {
+	Feed *feed.Feed
+	Item *feed.Item
+}
+
+func Run(ctx context.Context) error {
+	fs := DefaultFlagSet()
+	return RunWithFlagSet(ctx, fs)
+}
+
+func RunWithFlagSet(ctx context.Context, fs *flag.FlagSet) error {
+
+	flagset.Parse(fs)
+
+	if verbose {
+		slog.SetLogLoggerLevel(slog.LevelDebug)
+		slog.Debug("Verbose logging enabled")
+	}
+
+	if broadcaster_uri == "" {
+		return fmt.Errorf("Missing -broadcaster flag")
+	}
+
+	if feed_uri == "" {
+		return fmt.Errorf("Missing -feed flag")
+	}
+
+	if state_path == "" {
+		return fmt.Errorf("Missing -state flag")
+	}
+
+	if backfill < 0 {
+		return fmt.Errorf("-backfill must not be negative")
+	}
+
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt)
+	defer stop()
+
+	t, err := loadTemplate()
+
+	if err != nil {
+		return err
+	}
+
+	br_uri := broadcaster_uri
+
+	if dryrun {
+
+		dryrun_uri, err := mastodon.DryrunURI(br_uri, "")
+
+		if err != nil {
+			return err
+		}
+
+		br_uri = dryrun_uri
+	}
+
+	br, err := broadcaster.NewBroadcaster(ctx, br_uri)
+
+	if err != nil {
+		return fmt.Errorf("Failed to create broadcaster, %w", err)
+	}
+
+	mastodon_br, ok := br.(*mastodon.MastodonBroadcaster)
+
+	if !ok {
+		return fmt.Errorf("Broadcaster is not a Mastodon broadcaster")
+	}
+
+	// Wait for any test statuses scheduled for deletion to be deleted.
+	defer mastodon_br.Close(ctx)
+
+	f, err := readFeed(ctx, feed_uri)
+
+	if err != nil {
+		return err
+	}
+
+	st, err := readState(state_path)
+
+	if err != nil {
+		return err
+	}
+
+	first_run := len(st.Items) == 0
+
+	items := make([]*feed.Item, 0)
+
+	for _, i := range f.Items {
+
+		if i.Id == "" {
+			slog.Warn("Skipping feed item without an ID or link", "title", i.Title)
+			continue
+		}
+
+		if _, ok := st.Items[i.Id]; ok {
+			continue
+		}
+
+		items = append(items, i)
+	}
+
+	feed.SortByPublished(items)
+
+	if first_run && len(items) > backfill {
+
+		skip := items[:len(items)-backfill]
+		items = items[len(items)-backfill:]
+
+		now := time.Now().Format(time.RFC3339)
+
+		for _, i := range skip {
+			slog.Debug("Skipping item during backfill", "id", i.Id)
+			st.Items[i.Id] = &stateItem{Time: now}
+		}
+
+		if !dryrun {
+
+			err := writeState(state_path, st)
+
+			if err != nil {
+				return err
+			}
+		}
+
+		slog.Info("Limited first run to backfill", "posting", len(items), "skipped", len(skip))
+	}
+
+	derive_opts := &message.DeriveOptions{
+		AllowURLs: true,
+	}
+
+	var last_post time.Time
+
+	for _, i := range items {
+
+		logger := slog.Default().With("item", i.Id)
+
+		if !last_post.IsZero() {
+
+			wait := interval - time.Since(last_post)
+
+			if wait > 0 {
+
+				logger.Debug("Wait before next post", "duration", wait)
+
+				select {
+				case <-ctx.Done():
+					return ctx.Err()
+				case <-time.After(wait):
+					// pass
+				}
+			}
+		}
+
+		var buf strings.Builder
+
+		vars := templateVars{
+			Feed: f,
+			Item: i,
+		}
+
+		err := t.Execute(&buf, vars)
+
+		if err != nil {
+			return fmt.Errorf("Failed to render item %s, %w", i.Id, err)
+		}
+
+		m := &message.Message{
+			Id:         i.Id,
+			Title:      i.Title,
+			Body:       strings.TrimSpace(buf.String()),
+			Visibility: visibility,
+			Language:   language,
+		}
+
+		enc := i.Image()
+
+		if enc != nil {
+			m.Images = []*message.Image{
+				{URL: enc.URL, AltText: enc.Description},
+			}
+		}
+
+		msg, opts, err := m.Derive(ctx, derive_opts)
+
+		if err != nil {
+
+			err = fmt.Errorf("Failed to derive message for item %s, %w", i.Id, err)
+
+			if isTransient(ctx, err) {
+				return err
+			}
+
+			err = skipItem(st, i.Id, err)
+
+			if err != nil {
+				return err
+			}
+
+			continue
+		}
+
+		rsp, err := mastodon_br.PostMessage(ctx, msg, opts)
+
+		last_post = time.Now()
+
+		if err != nil {
+
+			err = fmt.Errorf("Failed to post item %s, %w", i.Id, err)
+
+			if isTransient(ctx, err) {
+				return err
+			}
+
+			err = skipItem(st, i.Id, err)
+
+			if err != nil {
+				return err
+			}
+
+			continue
+		}
+
+		logger.Info("Posted feed item", "status id", rsp.Id, "url", rsp.URL)
+
+		if dryrun {
+			continue
+		}
+
+		st.Items[i.Id] = &stateItem{
+			StatusId: rsp.Id,
+			URL:      rsp.URL,
+			Time:     last_post.Format(time.RFC3339),
+		}
+
+		err = writeState(state_path, st)
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// isTransient returns true if 'err' might be fixed by trying to post an item again, on the next run, rather than
+// being caused by the item itself, for example a status rejected by the instance or the broadcaster's policy. Errors
+// caused by 'ctx' being cancelled, or by a timeout, are always transient.
+func isTransient(ctx context.Context, err error) bool {
+
+	if ctx.Err() != nil || errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+
+	var policy_err *mastodon.PolicyError
+
+	if errors.As(err, &policy_err) {
+		return false
+	}
+
+	return mastodon.IsRetryable(err)
+}
+
+// skipItem records the item with ID 'id' in 'st', and writes the state file, so that an item which can not be posted
+// because of 'reason' does not stop newer items from being posted. The state file is not updated in dryrun mode.
+func skipItem(st *state, id string, reason error) error {
+
+	reason = mastodon.RedactError(reason)
+
+	slog.Error("Skip item that can not be posted", "item", id, "error", reason)
+
+	if dryrun {
+		return nil
+	}
+
+	st.Items[id] = &stateItem{
+		Error: reason.Error(),
+		Time:  time.Now().Format(time.RFC3339),
+	}
+
+	return writeState(state_path, st)
+}
+
+func loadTemplate() (*template.Template, error) {
+
+	body := default_template
+
+	if template_path != "" {
+
+		b, err := os.ReadFile(template_path)
+
+		if err != nil {
+			return nil, fmt.Errorf("Failed to read template, %w", err)
+		}
+
+		body = string(b)
+	}
+
+	t, err := template.New("feed").Parse(body)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to parse template, %w", err)
+	}
+
+	return t, nil
+}
+
+// readFeed reads and parses the feed at 'uri' which may be a local path or an HTTP(S) URL.
+func readFeed(ctx context.Context, uri string) (*feed.Feed, error) {
+
+	var r io.ReadCloser
+
+	if strings.HasPrefix(uri, "https://") || strings.HasPrefix(uri, "http://") {
+
+		req, err := http.NewRequestWithContext(ctx, "GET", uri, nil)
+
+		if err != nil {
+			return nil, fmt.Errorf("Failed to create request for %s, %w", uri, err)
+		}
+
+		rsp, err := http.DefaultClient.Do(req)
+
+		if err != nil {
+			return nil, fmt.Errorf("Failed to fetch %s, %w", uri, err)
+		}
+
+		if rsp.StatusCode != http.StatusOK {
+			rsp.Body.Close()
+			return nil, fmt.Errorf("Failed to fetch %s, %s", uri, rsp.Status)
+		}
+
+		r = rsp.Body
+
+	} else {
+
+		fh, err := os.Open(uri)
+
+		if err != nil {
+			return nil, fmt.Errorf("Failed to open %s, %w", uri, err)
+		}
+
+		r = fh
+	}
+
+	defer r.Close()
+
+	f, err := feed.Parse(r)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to parse %s, %w", uri, err)
+	}
+
+	return f, nil
+}
@@ -0,0 +1,56 @@
+package feed
+
+import (
+	"flag"
+	"time"
+
+	"github.com/sfomuseum/go-flags/flagset"
+)
+
+// A valid aaronland/go-broadcaster-mastodon URI.
+var broadcaster_uri string
+
+// The path or URL of an RSS or Atom feed.
+var feed_uri string
+
+// The path to a JSON file recording the feed items that have already been posted.
+var state_path string
+
+// The path to a text/template file used to render feed items.
+var template_path string
+
+// The minimum interval between posts.
+var interval time.Duration
+
+// The maximum number of items to post the first time a feed is processed.
+var backfill int
+
+var visibility string
+
+var language string
+
+var dryrun bool
+
+var verbose bool
+
+func DefaultFlagSet() *flag.FlagSet {
+
+	fs := flagset.NewFlagSet("feed")
+
+	fs.StringVar(&broadcaster_uri, "broadcaster", "", "A valid aaronland/go-broadcaster-mastodon URI.")
+	fs.StringVar(&feed_uri, "feed", "", "The path or URL of an RSS or Atom feed.")
+	fs.StringVar(&state_path, "state", "", "The path to a JSON file recording the feed items that have already been posted. It will be created if it does not exist.")
+
+	fs.StringVar(&template_path, "template", "", "The path to a Go text/template file used to render feed items. If empty a default template containing the item's title and link is used.")
+
+	fs.DurationVar(&interval, "interval", time.Minute, "The minimum interval between posts.")
+	fs.IntVar(&backfill, "backfill", 1, "The maximum number of (the most recent) items to post the first time a feed is processed. Older items are recorded as seen but not posted.")
+
+	fs.StringVar(&visibility, "visibility", "", "The visibility of statuses. If empty the broadcaster's default visibility is used.")
+	fs.StringVar(&language, "language", "", "The ISO 639 language code of statuses.")
+
+	fs.BoolVar(&dryrun, "dryrun", false, "Enable dryrun mode, overriding any ?dryrun= parameter in the broadcaster URI. State is not updated in dryrun mode.")
+	fs.BoolVar(&verbose, "verbose", false, "Enable verbose (debug) logging.")
+
+	return fs
+}
@@ -0,0 +1,61 @@
+package feed
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/aaronland/go-broadcaster-mastodon"
+)
+
+// state records the feed items that have already been processed.
+type state struct {
+	// Items maps feed item IDs to the record of how they were processed.
+	Items map[string]*stateItem `json:"items"`
+}
+
+type stateItem struct {
+	// The ID of the status the item was posted as. Empty if the item was skipped during backfill or could not be posted.
+	StatusId string `json:"status_id,omitempty"`
+	// The URL of the status the item was posted as.
+	URL string `json:"url,omitempty"`
+	// The reason the item was skipped, if it could not be posted for reasons that retrying will not fix.
+	Error string `json:"error,omitempty"`
+	// The time the item was processed.
+	Time string `json:"time"`
+}
+
+// readState reads the state file at 'path'. If the file does not exist an empty state is returned.
+func readState(path string) (*state, error) {
+
+	s := &state{
+		Items: make(map[string]*stateItem),
+	}
+
+	body, err := os.ReadFile(path)
+
+	if os.IsNotExist(err) {
+		return s, nil
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to read %s, %w", path, err)
+	}
+
+	err = json.Unmarshal(body, s)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to parse %s, %w", path, err)
+	}
+
+	if s.Items == nil {
+		s.Items = make(map[string]*stateItem)
+	}
+
+	return s, nil
+}
+
+// writeState atomically writes 's' to 'path'.
+func writeState(path string, s *state) error {
+	return mastodon.WriteJSONFile(path, s)
+}
@@ -0,0 +1,232 @@
+// Package mirror provides methods for implementing a command line tool for copying existing statuses, including
+// their media, to another Mastodon account.
+package mirror
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"strings"
+	"time"
+
+	"github.com/aaronland/go-broadcaster"
+	"github.com/aaronland/go-broadcaster-mastodon"
+	"github.com/aaronland/go-broadcaster-mastodon/feed"
+	"github.com/aaronland/go-broadcaster-mastodon/message"
+	"github.com/sfomuseum/go-flags/flagset"
+)
+
+func Run(ctx context.Context) error {
+	fs := DefaultFlagSet()
+	return RunWithFlagSet(ctx, fs)
+}
+
+func RunWithFlagSet(ctx context.Context, fs *flag.FlagSet) error {
+
+	flagset.Parse(fs)
+
+	if verbose {
+		slog.SetLogLoggerLevel(slog.LevelDebug)
+		slog.Debug("Verbose logging enabled")
+	}
+
+	if broadcaster_uri == "" {
+		return fmt.Errorf("Missing -broadcaster flag")
+	}
+
+	status_urls := fs.Args()
+
+	if len(status_urls) == 0 {
+		return fmt.Errorf("Missing status URLs to copy")
+	}
+
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt)
+	defer stop()
+
+	br_uri := broadcaster_uri
+
+	if dryrun {
+
+		dryrun_uri, err := mastodon.DryrunURI(br_uri, "")
+
+		if err != nil {
+			return err
+		}
+
+		br_uri = dryrun_uri
+	}
+
+	target_br, err := newMastodonBroadcaster(ctx, br_uri)
+
+	if err != nil {
+		return fmt.Errorf("Failed to create broadcaster, %w", err)
+	}
+
+	// Wait for any test statuses scheduled for deletion to be deleted.
+	defer target_br.Close(ctx)
+
+	source_br := target_br
+
+	if source_uri != "" {
+
+		br, err := newMastodonBroadcaster(ctx, source_uri)
+
+		if err != nil {
+			return fmt.Errorf("Failed to create source broadcaster, %w", err)
+		}
+
+		source_br = br
+	}
+
+	ledger := make(map[string]*ledgerEntry)
+
+	if ledger_path != "" {
+
+		l, err := readLedger(ledger_path)
+
+		if err != nil {
+			return err
+		}
+
+		ledger = l
+	}
+
+	derive_opts := &message.DeriveOptions{
+		AllowURLs: true,
+	}
+
+	for _, status_url := range status_urls {
+
+		logger := slog.Default().With("status", status_url)
+
+		e, exists := ledger[status_url]
+
+		if exists && !force {
+			logger.Info("Status has already been copied", "id", e.Id, "url", e.URL)
+			continue
+		}
+
+		st, err := source_br.GetStatus(ctx, status_url)
+
+		if err != nil {
+			return fmt.Errorf("Failed to retrieve status %s, %w", status_url, err)
+		}
+
+		m := deriveMessage(st)
+
+		msg, opts, err := m.Derive(ctx, derive_opts)
+
+		if err != nil {
+			return fmt.Errorf("Failed to derive message for status %s, %w", status_url, err)
+		}
+
+		rsp, err := target_br.PostMessage(ctx, msg, opts)
+
+		if err != nil {
+			return fmt.Errorf("Failed to copy status %s, %w", status_url, err)
+		}
+
+		logger.Info("Copied status", "id", rsp.Id, "url", rsp.URL)
+
+		if dryrun || ledger_path == "" {
+			continue
+		}
+
+		e = &ledgerEntry{
+			SourceURL: status_url,
+			SourceId:  st.Id,
+			Id:        rsp.Id,
+			URL:       rsp.URL,
+			Time:      time.Now().Format(time.RFC3339),
+		}
+
+		err = appendLedger(ledger_path, e)
+
+		if err != nil {
+			return err
+		}
+
+		ledger[status_url] = e
+	}
+
+	return nil
+}
+
+// newMastodonBroadcaster returns a new `mastodon.MastodonBroadcaster` instance for 'uri'.
+func newMastodonBroadcaster(ctx context.Context, uri string) (*mastodon.MastodonBroadcaster, error) {
+
+	br, err := broadcaster.NewBroadcaster(ctx, uri)
+
+	if err != nil {
+		return nil, err
+	}
+
+	mastodon_br, ok := br.(*mastodon.MastodonBroadcaster)
+
+	if !ok {
+		return nil, fmt.Errorf("Broadcaster is not a Mastodon broadcaster")
+	}
+
+	return mastodon_br, nil
+}
+
+// deriveMessage returns a new `message.Message` for copying 'st', preserving its visibility, content warning,
+// language and sensitive flag. If the -visibility flag is set the more restrictive of it and the visibility of 'st'
+// is used. Image attachments are copied with their alt text; other kinds of media are skipped.
+func deriveMessage(st *mastodon.Status) *message.Message {
+
+	body := st.Text
+
+	if body == "" {
+		body = feed.StripHTML(st.Content)
+	}
+
+	if via {
+
+		source_url := st.URL
+
+		if source_url == "" {
+			source_url = st.URI
+		}
+
+		body = fmt.Sprintf("%s\n\nvia %s", strings.TrimSpace(body), source_url)
+	}
+
+	// Copies are never more visible than the original status, which may have been posted to followers only
+
+	copy_visibility := st.Visibility
+
+	if visibility != "" {
+		copy_visibility = mastodon.RestrictiveVisibility(visibility, st.Visibility)
+	}
+
+	m := &message.Message{
+		Id:             st.URL,
+		Body:           body,
+		Visibility:     copy_visibility,
+		ContentWarning: st.SpoilerText,
+		Sensitive:      st.Sensitive,
+		Language:       st.Language,
+		Images:         make([]*message.Image, 0),
+	}
+
+	for _, media := range st.Media {
+
+		if media.Type != "image" {
+			slog.Warn("Skip media attachment, only images can be copied", "id", media.Id, "type", media.Type)
+			continue
+		}
+
+		im := &message.Image{
+			URL:     media.URL,
+			AltText: media.Description,
+		}
+
+		m.Images = append(m.Images, im)
+	}
+
+	return m
+}
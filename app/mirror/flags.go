@@ -0,0 +1,47 @@
+package mirror
+
+import (
+	"flag"
+
+	"github.com/sfomuseum/go-flags/flagset"
+)
+
+// A valid aaronland/go-broadcaster-mastodon URI for the account statuses are copied to.
+var broadcaster_uri string
+
+// An optional aaronland/go-broadcaster-mastodon URI for the account statuses are retrieved with.
+var source_uri string
+
+// The path to a JSON Lines file recording the statuses that have been copied.
+var ledger_path string
+
+// Append a "via" link to the original status.
+var via bool
+
+// Copy statuses that have already been recorded in the ledger.
+var force bool
+
+var visibility string
+
+var dryrun bool
+
+var verbose bool
+
+func DefaultFlagSet() *flag.FlagSet {
+
+	fs := flagset.NewFlagSet("mirror")
+
+	fs.StringVar(&broadcaster_uri, "broadcaster", "", "A valid aaronland/go-broadcaster-mastodon URI for the account statuses are copied to.")
+	fs.StringVar(&source_uri, "source", "", "An optional aaronland/go-broadcaster-mastodon URI for the account statuses are retrieved with. If empty statuses are retrieved, and resolved if necessary, using the -broadcaster account.")
+	fs.StringVar(&ledger_path, "ledger", "", "The path to a JSON Lines file recording the mapping between original statuses and their copies. It will be created if it does not exist. Statuses already recorded in the ledger are skipped.")
+
+	fs.BoolVar(&via, "via", false, "Append a \"via\" link to the original status.")
+	fs.BoolVar(&force, "force", false, "Copy statuses even if they have already been recorded in the ledger.")
+
+	fs.StringVar(&visibility, "visibility", "", "The visibility of copied statuses. If empty the visibility of the original status is used. Copies are never more visible than the original status.")
+
+	fs.BoolVar(&dryrun, "dryrun", false, "Enable dryrun mode, overriding any ?dryrun= parameter in the broadcaster URI. The ledger is not updated in dryrun mode.")
+	fs.BoolVar(&verbose, "verbose", false, "Enable verbose (debug) logging.")
+
+	return fs
+}
@@ -0,0 +1,106 @@
+package mirror
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+// ledgerEntry records the copy of a status.
+type ledgerEntry struct {
+	// The URL of the original status.
+	SourceURL string `json:"source_url"`
+	// The ID of the original status, relative to the instance it was retrieved from.
+	SourceId string `json:"source_id"`
+	// The ID of the copy.
+	Id string `json:"id"`
+	// The URL of the copy.
+	URL string `json:"url,omitempty"`
+	// The time the status was copied.
+	Time string `json:"time"`
+}
+
+// readLedger reads the ledger at 'path' and returns its entries keyed by the URL of the original status. If the
+// file does not exist an empty ledger is returned.
+func readLedger(path string) (map[string]*ledgerEntry, error) {
+
+	entries := make(map[string]*ledgerEntry)
+
+	r, err := os.Open(path)
+
+	if os.IsNotExist(err) {
+		return entries, nil
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to open %s, %w", path, err)
+	}
+
+	defer r.Close()
+
+	scanner := bufio.NewScanner(r)
+	line := 0
+
+	for scanner.Scan() {
+
+		line += 1
+
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+
+		var e *ledgerEntry
+
+		err := json.Unmarshal(scanner.Bytes(), &e)
+
+		if err != nil {
+			return nil, fmt.Errorf("Failed to parse line %d of %s, %w", line, path, err)
+		}
+
+		if e == nil {
+			return nil, fmt.Errorf("Failed to parse line %d of %s, entry is null", line, path)
+		}
+
+		entries[e.SourceURL] = e
+	}
+
+	err = scanner.Err()
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to read %s, %w", path, err)
+	}
+
+	return entries, nil
+}
+
+// appendLedger appends 'e' to the ledger at 'path', creating it if necessary.
+func appendLedger(path string, e *ledgerEntry) error {
+
+	body, err := json.Marshal(e)
+
+	if err != nil {
+		return fmt.Errorf("Failed to encode ledger entry, %w", err)
+	}
+
+	wr, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+
+	if err != nil {
+		return fmt.Errorf("Failed to open %s for writing, %w", path, err)
+	}
+
+	_, err = wr.Write(append(body, '\n'))
+
+	if err != nil {
+		wr.Close()
+		return fmt.Errorf("Failed to write ledger entry, %w", err)
+	}
+
+	err = wr.Close()
+
+	if err != nil {
+		return fmt.Errorf("Failed to close %s, %w", path, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,208 @@
+// Package post provides methods for implementing a command line tool for posting messages, with
+// Mastodon-specific options, to Mastodon.
+package post
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"image"
+	"log/slog"
+	"os"
+	"strings"
+
+	"github.com/aaronland/go-broadcaster"
+	"github.com/aaronland/go-broadcaster-mastodon"
+	"github.com/aaronland/go-broadcaster-mastodon/message"
+	"github.com/sfomuseum/go-flags/flagset"
+)
+
+func Run(ctx context.Context) error {
+	fs := DefaultFlagSet()
+	return RunWithFlagSet(ctx, fs)
+}
+
+func RunWithFlagSet(ctx context.Context, fs *flag.FlagSet) error {
+
+	flagset.Parse(fs)
+
+	set_flags := make(map[string]bool)
+
+	fs.Visit(func(fl *flag.Flag) {
+		set_flags[fl.Name] = true
+	})
+
+	if verbose {
+		slog.SetLogLoggerLevel(slog.LevelDebug)
+		slog.Debug("Verbose logging enabled")
+	}
+
+	br_uri, err := deriveBroadcasterURI(broadcaster_uri)
+
+	if err != nil {
+		return err
+	}
+
+	br, err := broadcaster.NewBroadcaster(ctx, br_uri)
+
+	if err != nil {
+		return fmt.Errorf("Failed to create broadcaster, %w", err)
+	}
+
+	mastodon_br, ok := br.(*mastodon.MastodonBroadcaster)
+
+	if !ok {
+		return fmt.Errorf("Broadcaster is not a Mastodon broadcaster")
+	}
+
+	// Wait for any test statuses scheduled for deletion to be deleted.
+	defer mastodon_br.Close(ctx)
+
+	if body_file != "" && markdown_file != "" {
+		return fmt.Errorf("-body-file and -markdown flags are mutually exclusive")
+	}
+
+	if body_file != "" {
+
+		b, err := readFile(body_file)
+
+		if err != nil {
+			return fmt.Errorf("Failed to read body, %w", err)
+		}
+
+		body = strings.TrimSpace(string(b))
+	}
+
+	if markdown_file != "" {
+
+		err := applyMarkdown(markdown_file, set_flags)
+
+		if err != nil {
+			return fmt.Errorf("Failed to apply Markdown document, %w", err)
+		}
+	}
+
+	msg := &broadcaster.Message{
+		Title: title,
+		Body:  body,
+	}
+
+	count_images := len(image_paths)
+
+	if count_images > 0 {
+
+		msg.Images = make([]image.Image, count_images)
+
+		for idx, path := range image_paths {
+
+			im, err := readImage(path)
+
+			if err != nil {
+				return err
+			}
+
+			msg.Images[idx] = im
+		}
+	}
+
+	opts := &mastodon.MessageOptions{
+		InReplyTo:    in_reply_to,
+		Quote:        quote,
+		Visibility:   visibility,
+		SpoilerText:  content_warning,
+		Sensitive:    sensitive,
+		Language:     language,
+		Descriptions: alt_text,
+		Pin:          pin,
+		PinJob:       pin_job,
+		ContentType:  content_type,
+	}
+
+	if len(data) > 0 {
+
+		opts.Data = make(map[string]any)
+
+		for _, kv := range data {
+
+			k, v, ok := strings.Cut(kv, "=")
+
+			if !ok || k == "" {
+				return fmt.Errorf("Invalid -data flag '%s', expected key=value", kv)
+			}
+
+			opts.Data[k] = v
+		}
+	}
+
+	if schedule != "" {
+
+		t, err := message.ParseSchedule(schedule)
+
+		if err != nil {
+			return fmt.Errorf("Failed to parse -schedule flag, %w", err)
+		}
+
+		opts.ScheduledAt = t
+	}
+
+	if len(poll_options) > 0 {
+
+		opts.Poll = &mastodon.PollOptions{
+			Options:    poll_options,
+			ExpiresIn:  poll_expires,
+			Multiple:   poll_multiple,
+			HideTotals: poll_hide_totals,
+		}
+	}
+
+	rsp, err := mastodon_br.PostMessage(ctx, msg, opts)
+
+	if err != nil {
+		return fmt.Errorf("Failed to post message, %w", err)
+	}
+
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+
+	err = enc.Encode(rsp)
+
+	if err != nil {
+		return fmt.Errorf("Failed to encode result, %w", err)
+	}
+
+	return nil
+}
+
+// deriveBroadcasterURI applies the -dryrun and -dryrun-output flags to 'uri'.
+func deriveBroadcasterURI(uri string) (string, error) {
+
+	if uri == "" {
+		return "", fmt.Errorf("Missing -broadcaster flag")
+	}
+
+	if !dryrun && dryrun_output == "" {
+		return uri, nil
+	}
+
+	return mastodon.DryrunURI(uri, dryrun_output)
+}
+
+func readImage(path string) (image.Image, error) {
+
+	r, err := os.Open(path)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to open image %s, %w", path, err)
+	}
+
+	defer r.Close()
+
+	im, _, err := image.Decode(r)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to decode image %s, %w", path, err)
+	}
+
+	return im, nil
+}
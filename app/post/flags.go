@@ -0,0 +1,110 @@
+package post
+
+import (
+	"flag"
+	"time"
+
+	"github.com/sfomuseum/go-flags/flagset"
+	"github.com/sfomuseum/go-flags/multi"
+)
+
+// A valid aaronland/go-broadcaster-mastodon URI.
+var broadcaster_uri string
+
+// The title of the message to broadcast.
+var title string
+
+// The body of the message to broadcast.
+var body string
+
+// The path to a file containing the body of the message to broadcast. If "-" the body is read from STDIN.
+var body_file string
+
+// The path to a Markdown document, with optional YAML front matter, defining the message to broadcast. If "-" the document is read from STDIN.
+var markdown_file string
+
+// Zero or more paths to images to include with the message to broadcast.
+var image_paths multi.MultiString
+
+// Zero or more alt text descriptions for images, in the same order as -image flags.
+var alt_text multi.MultiString
+
+// Zero or more key=value pairs to pass to the broadcaster's status template.
+var data multi.MultiString
+
+var content_type string
+
+var visibility string
+
+var content_warning string
+
+var sensitive bool
+
+var language string
+
+var in_reply_to string
+
+var quote string
+
+var schedule string
+
+var pin bool
+
+var pin_job string
+
+var poll_options multi.MultiString
+
+var poll_expires time.Duration
+
+var poll_multiple bool
+
+var poll_hide_totals bool
+
+var dryrun bool
+
+var dryrun_output string
+
+var verbose bool
+
+func DefaultFlagSet() *flag.FlagSet {
+
+	fs := flagset.NewFlagSet("post")
+
+	fs.StringVar(&broadcaster_uri, "broadcaster", "", "A valid aaronland/go-broadcaster-mastodon URI.")
+
+	fs.StringVar(&title, "title", "", "The title of the message to broadcast.")
+	fs.StringVar(&body, "body", "", "The body of the message to broadcast.")
+	fs.StringVar(&body_file, "body-file", "", "The path to a file containing the body of the message to broadcast. If \"-\" the body is read from STDIN.")
+	fs.StringVar(&content_type, "content-type", "", "The content type of the body, for example \"text/markdown\". Markdown is converted in to plain text for instances that do not support it. If empty the broadcaster's default content type is used.")
+	fs.StringVar(&markdown_file, "markdown", "", "The path to a Markdown document, with optional YAML front matter, defining the message to broadcast. If \"-\" the document is read from STDIN. Flags that are explicitly set take precedence over front matter properties.")
+
+	fs.Var(&image_paths, "image", "Zero or more paths to images to include with the message to broadcast.")
+	fs.Var(&alt_text, "alt-text", "Zero or more alt text descriptions for images, in the same order as the -image flags.")
+
+	fs.Var(&data, "data", "Zero or more key=value pairs to pass to the broadcaster's status template, if defined.")
+
+	fs.StringVar(&visibility, "visibility", "", "The visibility of the status: public, unlisted, private or direct. If empty the broadcaster's default visibility is used.")
+	fs.StringVar(&content_warning, "content-warning", "", "An optional content warning to display in front of the status.")
+	fs.BoolVar(&sensitive, "sensitive", false, "Mark any images as sensitive.")
+	fs.StringVar(&language, "language", "", "The ISO 639 language code of the status.")
+
+	fs.StringVar(&in_reply_to, "in-reply-to", "", "The ID, or URL, of a status to reply to.")
+	fs.StringVar(&quote, "quote", "", "The ID, or URL, of a status to quote.")
+
+	fs.StringVar(&schedule, "schedule", "", "Schedule the status to be published later. Valid options are an RFC3339 timestamp or a duration (for example \"2h30m\") relative to now.")
+
+	fs.BoolVar(&pin, "pin", false, "Pin the status to the profile of the account it is posted with.")
+	fs.StringVar(&pin_job, "pin-job", "", "The name of the job the status is pinned by. If the broadcaster rotates pins the status previously pinned by the same job is unpinned. If empty the broadcaster's default job is used.")
+
+	fs.Var(&poll_options, "poll-option", "Zero or more poll options. Polls require at least two options and can not be combined with images.")
+	fs.DurationVar(&poll_expires, "poll-expires", 24*time.Hour, "The duration after which the poll closes.")
+	fs.BoolVar(&poll_multiple, "poll-multiple", false, "Allow more than one poll option to be chosen.")
+	fs.BoolVar(&poll_hide_totals, "poll-hide-totals", false, "Hide poll vote counts until the poll closes.")
+
+	fs.BoolVar(&dryrun, "dryrun", false, "Enable dryrun mode, overriding any ?dryrun= parameter in the broadcaster URI.")
+	fs.StringVar(&dryrun_output, "dryrun-output", "", "An optional directory to write dryrun requests to. Implies -dryrun.")
+
+	fs.BoolVar(&verbose, "verbose", false, "Enable verbose (debug) logging.")
+
+	return fs
+}
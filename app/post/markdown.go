@@ -0,0 +1,118 @@
+package post
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/aaronland/go-broadcaster-mastodon"
+	"github.com/aaronland/go-broadcaster-mastodon/markdown"
+)
+
+// applyMarkdown parses the Markdown document at 'path' and assigns its body and front matter properties
+// to any flags that are not present in 'set_flags'.
+func applyMarkdown(path string, set_flags map[string]bool) error {
+
+	b, err := readFile(path)
+
+	if err != nil {
+		return err
+	}
+
+	doc, err := markdown.Parse(bytes.NewReader(b))
+
+	if err != nil {
+		return fmt.Errorf("Failed to parse %s, %w", path, err)
+	}
+
+	fm := doc.FrontMatter
+
+	if !set_flags["body"] {
+
+		body = doc.Body
+
+		// The Markdown is posted as-is, and converted in to plain text by the broadcaster
+		// for instances that do not support Markdown statuses
+
+		if !set_flags["content-type"] || content_type == mastodon.ContentTypeMarkdown {
+			body = doc.Source
+			content_type = mastodon.ContentTypeMarkdown
+		}
+	}
+
+	if !set_flags["title"] && fm.Title != "" {
+		title = fm.Title
+	}
+
+	if !set_flags["content-warning"] && fm.ContentWarning != "" {
+		content_warning = fm.ContentWarning
+	}
+
+	if !set_flags["visibility"] && fm.Visibility != "" {
+		visibility = fm.Visibility
+	}
+
+	if !set_flags["language"] && fm.Language != "" {
+		language = fm.Language
+	}
+
+	if !set_flags["sensitive"] && fm.Sensitive {
+		sensitive = true
+	}
+
+	if !set_flags["schedule"] && fm.Schedule != "" {
+		schedule = fm.Schedule
+	}
+
+	if !set_flags["pin"] && fm.Pin {
+		pin = true
+	}
+
+	if !set_flags["pin-job"] && fm.PinJob != "" {
+		pin_job = fm.PinJob
+	}
+
+	if !set_flags["image"] && len(fm.Images) > 0 {
+
+		root := "."
+
+		if path != "-" {
+			root = filepath.Dir(path)
+		}
+
+		image_paths = make([]string, len(fm.Images))
+
+		if !set_flags["alt-text"] {
+			alt_text = make([]string, len(fm.Images))
+		}
+
+		for idx, im := range fm.Images {
+
+			im_path := im.Path
+
+			if !filepath.IsAbs(im_path) {
+				im_path = filepath.Join(root, im_path)
+			}
+
+			image_paths[idx] = im_path
+
+			if !set_flags["alt-text"] {
+				alt_text[idx] = im.AltText
+			}
+		}
+	}
+
+	return nil
+}
+
+// readFile returns the contents of 'path' or STDIN if 'path' is "-".
+func readFile(path string) ([]byte, error) {
+
+	if path == "-" {
+		return io.ReadAll(os.Stdin)
+	}
+
+	return os.ReadFile(path)
+}
@@ -0,0 +1,163 @@
+// Package profiles provides methods for implementing a command line tool for validating a profiles configuration
+// file and listing the profiles it defines.
+package profiles
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/aaronland/go-broadcaster-mastodon"
+	"github.com/sfomuseum/go-flags/flagset"
+)
+
+func Run(ctx context.Context) error {
+	fs := DefaultFlagSet()
+	return RunWithFlagSet(ctx, fs)
+}
+
+func RunWithFlagSet(ctx context.Context, fs *flag.FlagSet) error {
+
+	flagset.Parse(fs)
+
+	if verbose {
+		slog.SetLogLoggerLevel(slog.LevelDebug)
+		slog.Debug("Verbose logging enabled")
+	}
+
+	uri := config_uri
+
+	if uri == "" {
+
+		path, err := mastodon.DefaultConfigPath()
+
+		if err != nil {
+			return err
+		}
+
+		uri = path
+	}
+
+	slog.Debug("Load profiles", "config", uri)
+
+	cfg, err := mastodon.LoadConfig(ctx, uri)
+
+	if err != nil {
+		return fmt.Errorf("Failed to load config, %w", err)
+	}
+
+	names := fs.Args()
+
+	if len(names) == 0 {
+		names = cfg.ProfileNames()
+	}
+
+	wr := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+
+	header := []string{"NAME", "VISIBILITY", "LANGUAGE", "TESTING", "TAGS", "STATUS"}
+
+	if verify {
+		header = append(header, "ACCOUNT")
+	}
+
+	fmt.Fprintln(wr, strings.Join(header, "\t"))
+
+	invalid := 0
+
+	for _, name := range names {
+
+		row := []string{name, "-", "-", "-", "-", "ok"}
+
+		p, err := cfg.Profile(name)
+
+		if err == nil {
+
+			if p.Visibility != "" {
+				row[1] = p.Visibility
+			}
+
+			if p.Language != "" {
+				row[2] = p.Language
+			}
+
+			if p.Testing != nil && p.Testing.Enabled {
+				row[3] = "yes"
+			}
+
+			if len(p.Tags) > 0 {
+				row[4] = strings.Join(p.Tags, ",")
+			}
+
+			err = cfg.Validate(ctx, name)
+		}
+
+		account := "-"
+
+		if err == nil && verify {
+
+			acct, verify_err := verifyProfile(ctx, uri, name)
+
+			if verify_err != nil {
+				err = verify_err
+			} else {
+				account = acct
+			}
+		}
+
+		if err != nil {
+			slog.Error("Invalid profile", "profile", name, "error", err)
+			row[5] = "invalid"
+			invalid += 1
+		}
+
+		if verify {
+			row = append(row, account)
+		}
+
+		fmt.Fprintln(wr, strings.Join(row, "\t"))
+	}
+
+	err = wr.Flush()
+
+	if err != nil {
+		return fmt.Errorf("Failed to write profiles, %w", err)
+	}
+
+	if invalid > 0 {
+		return fmt.Errorf("%d of %d profiles are invalid", invalid, len(names))
+	}
+
+	return nil
+}
+
+// verifyProfile creates a new broadcaster for the profile 'name' defined in the config file 'config_uri' and returns
+// the account its credentials belong to.
+func verifyProfile(ctx context.Context, config_uri string, name string) (string, error) {
+
+	q := url.Values{}
+	q.Set("config", config_uri)
+
+	br_uri := fmt.Sprintf("mastodon://profile/%s?%s", url.PathEscape(name), q.Encode())
+
+	br, err := mastodon.NewMastodonBroadcaster(ctx, br_uri)
+
+	if err != nil {
+		return "", fmt.Errorf("Failed to create broadcaster, %w", err)
+	}
+
+	mastodon_br := br.(*mastodon.MastodonBroadcaster)
+	defer mastodon_br.Close(ctx)
+
+	acct, err := mastodon_br.VerifyCredentials(ctx)
+
+	if err != nil {
+		return "", err
+	}
+
+	return acct.Acct, nil
+}
@@ -0,0 +1,27 @@
+package profiles
+
+import (
+	"flag"
+
+	"github.com/sfomuseum/go-flags/flagset"
+)
+
+// A sfomuseum/runtimevar URI, or a local path, for the profiles configuration file.
+var config_uri string
+
+// Resolve each profile's credentials and verify them with the Mastodon instance.
+var verify bool
+
+// Enable verbose (debug) logging.
+var verbose bool
+
+func DefaultFlagSet() *flag.FlagSet {
+
+	fs := flagset.NewFlagSet("profiles")
+
+	fs.StringVar(&config_uri, "config", "", "A sfomuseum/runtimevar URI, or a local path, for the profiles configuration file. If empty the value of the MASTODON_BROADCASTER_CONFIG environment variable or the default config.yml file in the user's configuration directory is used.")
+	fs.BoolVar(&verify, "verify", false, "Resolve each profile's credentials and verify them with the Mastodon instance.")
+	fs.BoolVar(&verbose, "verbose", false, "Enable verbose (debug) logging.")
+
+	return fs
+}
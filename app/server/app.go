@@ -0,0 +1,140 @@
+// Package server provides methods for implementing an HTTP server that broadcasts messages to Mastodon
+// on behalf of other applications.
+package server
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/aaronland/go-broadcaster"
+	"github.com/aaronland/go-broadcaster-mastodon"
+	"github.com/sfomuseum/go-flags/flagset"
+	"github.com/sfomuseum/runtimevar"
+)
+
+func Run(ctx context.Context) error {
+	fs := DefaultFlagSet()
+	return RunWithFlagSet(ctx, fs)
+}
+
+func RunWithFlagSet(ctx context.Context, fs *flag.FlagSet) error {
+
+	flagset.Parse(fs)
+
+	err := flagset.SetFlagsFromEnvVars(fs, "BROADCASTER")
+
+	if err != nil {
+		return fmt.Errorf("Failed to set flags from environment variables, %w", err)
+	}
+
+	if verbose {
+		slog.SetLogLoggerLevel(slog.LevelDebug)
+		slog.Debug("Verbose logging enabled")
+	}
+
+	if broadcaster_uri == "" {
+		return fmt.Errorf("Missing -broadcaster flag")
+	}
+
+	if len(token_uris) == 0 && !insecure {
+		return fmt.Errorf("One or more -token flags are required unless -insecure is enabled")
+	}
+
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	br, err := broadcaster.NewBroadcaster(ctx, broadcaster_uri)
+
+	if err != nil {
+		return fmt.Errorf("Failed to create broadcaster, %w", err)
+	}
+
+	mastodon_br, ok := br.(*mastodon.MastodonBroadcaster)
+
+	if !ok {
+		return fmt.Errorf("Broadcaster is not a Mastodon broadcaster")
+	}
+
+	tokens := make([]string, len(token_uris))
+
+	for idx, uri := range token_uris {
+
+		rt_ctx, rt_cancel := context.WithTimeout(ctx, 5*time.Second)
+		t, err := runtimevar.StringVar(rt_ctx, uri)
+		rt_cancel()
+
+		if err != nil {
+			return fmt.Errorf("Failed to derive token at offset %d, %w", idx, err)
+		}
+
+		t = strings.TrimSpace(t)
+
+		if t == "" {
+			return fmt.Errorf("Token at offset %d is empty", idx)
+		}
+
+		tokens[idx] = t
+	}
+
+	broadcast_opts := &broadcastHandlerOptions{
+		Broadcaster:    mastodon_br,
+		MaxBodySize:    max_body_size,
+		AllowImageURLs: allow_image_urls,
+	}
+
+	broadcast_handler := broadcastHandler(broadcast_opts)
+	broadcast_handler = authHandler(broadcast_handler, tokens)
+
+	health_handler := healthHandler(mastodon_br)
+
+	mux := http.NewServeMux()
+	mux.Handle("POST /broadcast", broadcast_handler)
+	mux.Handle("GET /health", health_handler)
+
+	s := &http.Server{
+		Addr:              address,
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	shutdown_done := make(chan struct{})
+
+	go func() {
+
+		defer close(shutdown_done)
+
+		<-ctx.Done()
+
+		shutdown_ctx, shutdown_cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer shutdown_cancel()
+
+		slog.Info("Shutting down server")
+		s.Shutdown(shutdown_ctx)
+
+		// Delete any test statuses scheduled for deletion now rather than waiting.
+		close_ctx, close_cancel := context.WithCancel(context.Background())
+		close_cancel()
+
+		mastodon_br.Close(close_ctx)
+	}()
+
+	slog.Info("Listening for requests", "address", address)
+
+	err = s.ListenAndServe()
+
+	if err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return fmt.Errorf("Failed to serve requests, %w", err)
+	}
+
+	<-shutdown_done
+	return nil
+}
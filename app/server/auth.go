@@ -0,0 +1,45 @@
+package server
+
+import (
+	"crypto/subtle"
+	"log/slog"
+	"net/http"
+	"strings"
+)
+
+// authHandler wraps 'next' requiring that requests present one of 'tokens' in an "Authorization: Bearer"
+// header. If 'tokens' is empty all requests are allowed.
+func authHandler(next http.Handler, tokens []string) http.Handler {
+
+	fn := func(rsp http.ResponseWriter, req *http.Request) {
+
+		if len(tokens) == 0 {
+			next.ServeHTTP(rsp, req)
+			return
+		}
+
+		auth := req.Header.Get("Authorization")
+		presented, ok := strings.CutPrefix(auth, "Bearer ")
+
+		if !ok || presented == "" {
+			rsp.Header().Set("WWW-Authenticate", "Bearer")
+			writeError(rsp, "Missing bearer token", http.StatusUnauthorized)
+			return
+		}
+
+		for _, t := range tokens {
+
+			if subtle.ConstantTimeCompare([]byte(presented), []byte(t)) == 1 {
+				next.ServeHTTP(rsp, req)
+				return
+			}
+		}
+
+		slog.Warn("Invalid bearer token", "remote addr", req.RemoteAddr)
+
+		rsp.Header().Set("WWW-Authenticate", "Bearer error=\"invalid_token\"")
+		writeError(rsp, "Invalid bearer token", http.StatusUnauthorized)
+	}
+
+	return http.HandlerFunc(fn)
+}
@@ -0,0 +1,187 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image"
+	"log/slog"
+	"math"
+	"mime"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/aaronland/go-broadcaster-mastodon"
+	"github.com/aaronland/go-broadcaster-mastodon/message"
+)
+
+type broadcastHandlerOptions struct {
+	Broadcaster    *mastodon.MastodonBroadcaster
+	MaxBodySize    int64
+	AllowImageURLs bool
+}
+
+// broadcastHandler returns an `http.Handler` that broadcasts messages encoded as either a JSON-encoded
+// `message.Message` or a multipart form and responds with a JSON-encoded `mastodon.Result`.
+func broadcastHandler(opts *broadcastHandlerOptions) http.Handler {
+
+	derive_opts := &message.DeriveOptions{
+		AllowURLs: opts.AllowImageURLs,
+	}
+
+	fn := func(rsp http.ResponseWriter, req *http.Request) {
+
+		ctx := req.Context()
+		req.Body = http.MaxBytesReader(rsp, req.Body, opts.MaxBodySize)
+
+		content_type, _, err := mime.ParseMediaType(req.Header.Get("Content-Type"))
+
+		if err != nil {
+			writeError(rsp, "Invalid content type", http.StatusUnsupportedMediaType)
+			return
+		}
+
+		var m *message.Message
+		var multipart_images []image.Image
+		var multipart_descriptions []string
+
+		switch content_type {
+		case "application/json":
+
+			dec := json.NewDecoder(req.Body)
+			dec.DisallowUnknownFields()
+
+			err := dec.Decode(&m)
+
+			if err != nil {
+				writeError(rsp, fmt.Sprintf("Failed to decode message, %v", err), requestErrorStatus(err))
+				return
+			}
+
+		case "multipart/form-data":
+
+			mm, images, descriptions, err := readMultipart(req, opts.MaxBodySize)
+
+			if err != nil {
+				writeError(rsp, fmt.Sprintf("Failed to read form, %v", err), requestErrorStatus(err))
+				return
+			}
+
+			m = mm
+			multipart_images = images
+			multipart_descriptions = descriptions
+
+		default:
+			writeError(rsp, "Unsupported content type", http.StatusUnsupportedMediaType)
+			return
+		}
+
+		if m == nil {
+			writeError(rsp, "Empty message", http.StatusBadRequest)
+			return
+		}
+
+		msg, msg_opts, err := m.Derive(ctx, derive_opts)
+
+		if err != nil {
+			writeError(rsp, fmt.Sprintf("Invalid message, %v", err), http.StatusBadRequest)
+			return
+		}
+
+		if len(multipart_images) > 0 {
+			msg.Images = multipart_images
+			msg_opts.Descriptions = multipart_descriptions
+		}
+
+		result, err := opts.Broadcaster.PostMessage(ctx, msg, msg_opts)
+
+		if err != nil {
+			slog.Error("Failed to broadcast message", "id", m.Id, "error", err)
+			writeError(rsp, fmt.Sprintf("Failed to broadcast message, %v", err), broadcastErrorStatus(rsp, err))
+			return
+		}
+
+		slog.Info("Broadcast message", "id", m.Id, "status id", result.Id)
+		writeJSON(rsp, result, http.StatusOK)
+	}
+
+	return http.HandlerFunc(fn)
+}
+
+// requestErrorStatus returns the HTTP status code for an error reading a request body.
+func requestErrorStatus(err error) int {
+
+	var max_err *http.MaxBytesError
+
+	if errors.As(err, &max_err) {
+		return http.StatusRequestEntityTooLarge
+	}
+
+	return http.StatusBadRequest
+}
+
+// broadcastErrorStatus returns the HTTP status code for an error broadcasting a message. Errors returned by, or
+// reaching, the Mastodon API return a 502 status; errors caused by the message itself, including those detected
+// before it is posted, return a 422 or 400 status. If 'err' is a rate limit error with a known reset time a
+// Retry-After header is added to 'rsp'.
+func broadcastErrorStatus(rsp http.ResponseWriter, err error) int {
+
+	var rate_err *mastodon.RateLimitError
+
+	if errors.As(err, &rate_err) {
+
+		if !rate_err.RateLimitReset.IsZero() {
+			retry := int(math.Ceil(time.Until(rate_err.RateLimitReset).Seconds()))
+			rsp.Header().Set("Retry-After", strconv.Itoa(max(retry, 0)))
+		}
+
+		return http.StatusTooManyRequests
+	}
+
+	var validation_err *mastodon.ValidationError
+	var media_err *mastodon.MediaRejectedError
+	var policy_err *mastodon.PolicyError
+	var mention_err *mastodon.MentionError
+	var length_err *mastodon.LengthError
+
+	var api_err *mastodon.APIError
+	var url_err *url.Error
+	var net_err net.Error
+
+	switch {
+	case errors.As(err, &validation_err), errors.As(err, &media_err), errors.As(err, &policy_err), errors.As(err, &mention_err), errors.As(err, &length_err):
+		return http.StatusUnprocessableEntity
+	case errors.As(err, &api_err), errors.As(err, &url_err), errors.As(err, &net_err):
+		return http.StatusBadGateway
+	default:
+		// Errors that did not come from, or while trying to reach, the Mastodon API are local validation
+		// errors, for example an invalid visibility or an image that can not be decoded
+		return http.StatusBadRequest
+	}
+}
+
+func writeJSON(rsp http.ResponseWriter, v any, status int) {
+
+	rsp.Header().Set("Content-Type", "application/json")
+	rsp.WriteHeader(status)
+
+	enc := json.NewEncoder(rsp)
+	err := enc.Encode(v)
+
+	if err != nil {
+		slog.Error("Failed to encode response", "error", err)
+	}
+}
+
+func writeError(rsp http.ResponseWriter, msg string, status int) {
+
+	body := map[string]string{
+		"error":  msg,
+		"status": strconv.Itoa(status),
+	}
+
+	writeJSON(rsp, body, status)
+}
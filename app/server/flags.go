@@ -0,0 +1,46 @@
+package server
+
+import (
+	"flag"
+
+	"github.com/sfomuseum/go-flags/flagset"
+	"github.com/sfomuseum/go-flags/multi"
+)
+
+// A valid aaronland/go-broadcaster-mastodon URI.
+var broadcaster_uri string
+
+// The address to listen for requests on.
+var address string
+
+// Zero or more sfomuseum/runtimevar URIs resolving to bearer tokens that callers must present.
+var token_uris multi.MultiString
+
+// Allow requests without a bearer token.
+var insecure bool
+
+// The maximum size, in bytes, of a request body.
+var max_body_size int64
+
+// Allow images to be fetched from URLs specified in JSON requests.
+var allow_image_urls bool
+
+var verbose bool
+
+func DefaultFlagSet() *flag.FlagSet {
+
+	fs := flagset.NewFlagSet("server")
+
+	fs.StringVar(&broadcaster_uri, "broadcaster", "", "A valid aaronland/go-broadcaster-mastodon URI.")
+	fs.StringVar(&address, "address", "localhost:8080", "The address to listen for requests on.")
+
+	fs.Var(&token_uris, "token", "One or more sfomuseum/runtimevar URIs resolving to bearer tokens that callers must present in an \"Authorization: Bearer {TOKEN}\" header.")
+	fs.BoolVar(&insecure, "insecure", false, "Allow requests without a bearer token. This is only meant for local development.")
+
+	fs.Int64Var(&max_body_size, "max-body-size", 32*1024*1024, "The maximum size, in bytes, of a request body.")
+	fs.BoolVar(&allow_image_urls, "allow-image-urls", false, "Allow images to be fetched from URLs specified in JSON requests.")
+
+	fs.BoolVar(&verbose, "verbose", false, "Enable verbose (debug) logging.")
+
+	return fs
+}
@@ -0,0 +1,35 @@
+package server
+
+import (
+	"log/slog"
+	"net/http"
+
+	"github.com/aaronland/go-broadcaster-mastodon"
+)
+
+// healthHandler returns an `http.Handler` that verifies the credentials used by 'br' and responds
+// with the account they belong to.
+func healthHandler(br *mastodon.MastodonBroadcaster) http.Handler {
+
+	fn := func(rsp http.ResponseWriter, req *http.Request) {
+
+		ctx := req.Context()
+
+		acct, err := br.VerifyCredentials(ctx)
+
+		if err != nil {
+			slog.Error("Health check failed", "error", err)
+			writeError(rsp, "Failed to verify credentials", http.StatusServiceUnavailable)
+			return
+		}
+
+		body := map[string]string{
+			"status":  "ok",
+			"account": acct.Acct,
+		}
+
+		writeJSON(rsp, body, http.StatusOK)
+	}
+
+	return http.HandlerFunc(fn)
+}
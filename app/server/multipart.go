@@ -0,0 +1,130 @@
+package server
+
+import (
+	"fmt"
+	"image"
+	"net/http"
+	"strconv"
+
+	"github.com/aaronland/go-broadcaster-mastodon/message"
+)
+
+// readMultipart derives a `message.Message` from the fields in a multipart form, using the same names
+// as the JSON encoding of `message.Message`, along with any images uploaded as "image" files. Alt text
+// for uploaded images is read from repeated "alt_text" fields, in the same order as the images.
+func readMultipart(req *http.Request, max_memory int64) (*message.Message, []image.Image, []string, error) {
+
+	err := req.ParseMultipartForm(max_memory)
+
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	form := req.MultipartForm
+
+	get := func(k string) string {
+
+		v, ok := form.Value[k]
+
+		if !ok || len(v) == 0 {
+			return ""
+		}
+
+		return v[0]
+	}
+
+	getBool := func(k string) (bool, error) {
+
+		v := get(k)
+
+		if v == "" {
+			return false, nil
+		}
+
+		return strconv.ParseBool(v)
+	}
+
+	m := &message.Message{
+		Id:             get("id"),
+		Title:          get("title"),
+		Body:           get("body"),
+		Visibility:     get("visibility"),
+		ContentWarning: get("content_warning"),
+		Language:       get("language"),
+		InReplyTo:      get("in_reply_to"),
+		Quote:          get("quote"),
+		Schedule:       get("schedule"),
+		PinJob:         get("pin_job"),
+		ContentType:    get("content_type"),
+	}
+
+	sensitive, err := getBool("sensitive")
+
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("Invalid 'sensitive' field, %w", err)
+	}
+
+	m.Sensitive = sensitive
+
+	pin, err := getBool("pin")
+
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("Invalid 'pin' field, %w", err)
+	}
+
+	m.Pin = pin
+
+	if options, ok := form.Value["poll_options"]; ok && len(options) > 0 {
+
+		multiple, err := getBool("poll_multiple")
+
+		if err != nil {
+			return nil, nil, nil, fmt.Errorf("Invalid 'poll_multiple' field, %w", err)
+		}
+
+		hide_totals, err := getBool("poll_hide_totals")
+
+		if err != nil {
+			return nil, nil, nil, fmt.Errorf("Invalid 'poll_hide_totals' field, %w", err)
+		}
+
+		m.Poll = &message.Poll{
+			Options:    options,
+			ExpiresIn:  get("poll_expires_in"),
+			Multiple:   multiple,
+			HideTotals: hide_totals,
+		}
+	}
+
+	files := form.File["image"]
+	images := make([]image.Image, len(files))
+
+	for idx, fh := range files {
+
+		r, err := fh.Open()
+
+		if err != nil {
+			return nil, nil, nil, fmt.Errorf("Failed to open image %d, %w", idx, err)
+		}
+
+		im, _, err := image.Decode(r)
+		r.Close()
+
+		if err != nil {
+			return nil, nil, nil, fmt.Errorf("Failed to decode image %d, %w", idx, err)
+		}
+
+		images[idx] = im
+	}
+
+	alt_text := form.Value["alt_text"]
+
+	if len(alt_text) > len(images) {
+		return nil, nil, nil, fmt.Errorf("More alt_text fields (%d) than images (%d)", len(alt_text), len(images))
+	}
+
+	descriptions := make([]string, len(images))
+	copy(descriptions, alt_text)
+
+	return m, images, descriptions, nil
+}
@@ -0,0 +1,246 @@
+// Package stats provides methods for implementing a command line tool for collecting engagement statistics
+// (favourites, boosts, replies and poll results) for statuses that have been broadcast.
+package stats
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"os/signal"
+	"time"
+
+	"github.com/aaronland/go-broadcaster"
+	"github.com/aaronland/go-broadcaster-mastodon"
+	"github.com/sfomuseum/go-flags/flagset"
+)
+
+// The amount of time to wait for a rate limit to reset if the time it resets is not known.
+const default_rate_limit_wait = time.Minute
+
+func Run(ctx context.Context) error {
+	fs := DefaultFlagSet()
+	return RunWithFlagSet(ctx, fs)
+}
+
+func RunWithFlagSet(ctx context.Context, fs *flag.FlagSet) error {
+
+	flagset.Parse(fs)
+
+	if verbose {
+		slog.SetLogLoggerLevel(slog.LevelDebug)
+		slog.Debug("Verbose logging enabled")
+	}
+
+	if broadcaster_uri == "" {
+		return fmt.Errorf("Missing -broadcaster flag")
+	}
+
+	if max_rate_limit_waits < 0 {
+		return fmt.Errorf("-max-rate-limit-waits must not be negative")
+	}
+
+	ids := make([]string, 0)
+	seen := make(map[string]bool)
+
+	for _, path := range ledgers {
+
+		ledger_ids, err := mastodon.ReadLedger(path)
+
+		if err != nil {
+			return err
+		}
+
+		ids = append(ids, ledger_ids...)
+	}
+
+	ids = append(ids, fs.Args()...)
+
+	// Remove duplicates, for example statuses that were broadcast again after a failure, preserving order
+
+	unique_ids := make([]string, 0, len(ids))
+
+	for _, id := range ids {
+
+		if seen[id] {
+			continue
+		}
+
+		seen[id] = true
+		unique_ids = append(unique_ids, id)
+	}
+
+	if len(unique_ids) == 0 {
+		return fmt.Errorf("No status IDs to collect statistics for")
+	}
+
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt)
+	defer stop()
+
+	br, err := broadcaster.NewBroadcaster(ctx, broadcaster_uri)
+
+	if err != nil {
+		return fmt.Errorf("Failed to create broadcaster, %w", err)
+	}
+
+	mastodon_br, ok := br.(*mastodon.MastodonBroadcaster)
+
+	if !ok {
+		return fmt.Errorf("Broadcaster is not a Mastodon broadcaster")
+	}
+
+	out_format := format
+	var wr io.Writer = os.Stdout
+	header := true
+
+	if output != "-" {
+
+		if out_format == "" {
+			out_format = deriveFormat(output)
+		}
+
+		// Statistics are appended so that repeated runs produce a time series
+
+		fh, err := os.OpenFile(output, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+
+		if err != nil {
+			return fmt.Errorf("Failed to open %s for writing, %w", output, err)
+		}
+
+		defer fh.Close()
+
+		info, err := fh.Stat()
+
+		if err != nil {
+			return fmt.Errorf("Failed to stat %s, %w", output, err)
+		}
+
+		header = info.Size() == 0
+		wr = fh
+	}
+
+	if out_format == "" {
+		out_format = "jsonl"
+	}
+
+	rec_wr, err := newRecordWriter(wr, out_format, header)
+
+	if err != nil {
+		return err
+	}
+
+	var last_fetch time.Time
+	failures := 0
+
+	for _, id := range unique_ids {
+
+		logger := slog.Default().With("status", id)
+
+		if !last_fetch.IsZero() {
+
+			wait := interval - time.Since(last_fetch)
+
+			if wait > 0 {
+
+				select {
+				case <-ctx.Done():
+					return ctx.Err()
+				case <-time.After(wait):
+					// pass
+				}
+			}
+		}
+
+		e, err := getEngagement(ctx, mastodon_br, id)
+
+		last_fetch = time.Now()
+
+		rec := &record{
+			Time:       last_fetch.UTC().Format(time.RFC3339),
+			Engagement: e,
+		}
+
+		if err != nil {
+
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+
+			failures += 1
+			logger.Error("Failed to retrieve statistics", "error", err)
+
+			rec.Engagement = &mastodon.Engagement{
+				StatusId: id,
+			}
+
+			rec.Error = err.Error()
+
+		} else {
+			logger.Debug("Retrieved statistics", "favourites", e.Favourites, "boosts", e.Boosts, "replies", e.Replies)
+		}
+
+		err = rec_wr.Write(rec)
+
+		if err != nil {
+			return fmt.Errorf("Failed to write statistics for status %s, %w", id, err)
+		}
+	}
+
+	err = rec_wr.Flush()
+
+	if err != nil {
+		return fmt.Errorf("Failed to write statistics, %w", err)
+	}
+
+	if failures > 0 {
+		return fmt.Errorf("Failed to retrieve statistics for %d of %d statuses", failures, len(unique_ids))
+	}
+
+	return nil
+}
+
+// getEngagement returns the engagement statistics for the status 'id' using 'br'. If the request is rejected by a
+// rate limit it is retried after the rate limit resets, up to -max-rate-limit-waits times.
+func getEngagement(ctx context.Context, br *mastodon.MastodonBroadcaster, id string) (*mastodon.Engagement, error) {
+
+	waits := 0
+
+	for {
+
+		e, err := br.GetEngagement(ctx, id, include_accounts)
+
+		if err == nil {
+			return e, nil
+		}
+
+		var rate_err *mastodon.RateLimitError
+
+		if !errors.As(err, &rate_err) || waits >= max_rate_limit_waits {
+			return nil, err
+		}
+
+		waits += 1
+
+		wait := default_rate_limit_wait
+
+		if !rate_err.RateLimitReset.IsZero() {
+			wait = time.Until(rate_err.RateLimitReset)
+		}
+
+		if wait < time.Second {
+			wait = time.Second
+		}
+
+		slog.Warn("Rate limit exceeded, waiting for it to reset", "status", id, "wait", wait)
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(wait):
+			// pass
+		}
+	}
+}
@@ -0,0 +1,51 @@
+package stats
+
+import (
+	"flag"
+	"time"
+
+	"github.com/sfomuseum/go-flags/flagset"
+	"github.com/sfomuseum/go-flags/multi"
+)
+
+// A valid aaronland/go-broadcaster-mastodon URI for the account statistics are retrieved with.
+var broadcaster_uri string
+
+// Zero or more JSONL ledger files to read status IDs from.
+var ledgers multi.MultiString
+
+// The path of the file to append statistics to.
+var output string
+
+// The format of the output file.
+var format string
+
+// List the accounts that favourited and boosted each status.
+var include_accounts bool
+
+// The minimum interval between statuses.
+var interval time.Duration
+
+// The maximum number of times to wait for a rate limit to reset before giving up on a status.
+var max_rate_limit_waits int
+
+var verbose bool
+
+func DefaultFlagSet() *flag.FlagSet {
+
+	fs := flagset.NewFlagSet("stats")
+
+	fs.StringVar(&broadcaster_uri, "broadcaster", "", "A valid aaronland/go-broadcaster-mastodon URI for the account statistics are retrieved with.")
+	fs.Var(&ledgers, "ledger", "Zero or more JSONL files to read status IDs from, for example the -results file written by the batch tool or the -ledger file written by the mirror tool.")
+
+	fs.StringVar(&output, "output", "-", "The path of the file to append statistics to. If \"-\" statistics are written to STDOUT.")
+	fs.StringVar(&format, "format", "", "The format of the output: csv or jsonl. If empty the format is derived from the output file's extension, defaulting to jsonl.")
+
+	fs.BoolVar(&include_accounts, "accounts", false, "List the accounts that favourited and boosted each status.")
+	fs.DurationVar(&interval, "interval", time.Second, "The minimum interval between retrieving statistics for each status.")
+	fs.IntVar(&max_rate_limit_waits, "max-rate-limit-waits", 3, "The maximum number of times to wait for a rate limit to reset before giving up on a status.")
+
+	fs.BoolVar(&verbose, "verbose", false, "Enable verbose (debug) logging.")
+
+	return fs
+}
@@ -0,0 +1,167 @@
+package stats
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/aaronland/go-broadcaster-mastodon"
+)
+
+// The separator for multi-value CSV columns like "poll_options" and "favourited_by".
+const csv_separator = "|"
+
+// The columns of CSV output.
+var csv_header = []string{
+	"time",
+	"status_id",
+	"url",
+	"created_at",
+	"favourites",
+	"boosts",
+	"replies",
+	"poll_votes",
+	"poll_voters",
+	"poll_expired",
+	"poll_options",
+	"favourited_by",
+	"boosted_by",
+	"error",
+}
+
+// record is the engagement statistics for a status at a point in time.
+type record struct {
+	// The time the statistics were retrieved.
+	Time string `json:"time"`
+	*mastodon.Engagement
+	// The error, if any, that prevented the statistics from being retrieved.
+	Error string `json:"error,omitempty"`
+}
+
+// recordWriter writes records to an output file.
+type recordWriter interface {
+	Write(*record) error
+	Flush() error
+}
+
+// deriveFormat returns the output format for 'path' derived from its extension.
+func deriveFormat(path string) string {
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".csv":
+		return "csv"
+	default:
+		return "jsonl"
+	}
+}
+
+// newRecordWriter returns a new `recordWriter` that writes records encoded as 'format' to 'wr'. If 'header' is
+// true CSV output starts with a header row.
+func newRecordWriter(wr io.Writer, format string, header bool) (recordWriter, error) {
+
+	switch format {
+	case "jsonl":
+
+		w := &jsonlWriter{
+			encoder: json.NewEncoder(wr),
+		}
+
+		return w, nil
+
+	case "csv":
+
+		w := &csvWriter{
+			writer: csv.NewWriter(wr),
+		}
+
+		if header {
+
+			err := w.writer.Write(csv_header)
+
+			if err != nil {
+				return nil, fmt.Errorf("Failed to write CSV header, %w", err)
+			}
+		}
+
+		return w, nil
+
+	default:
+		return nil, fmt.Errorf("Unsupported format '%s'", format)
+	}
+}
+
+type jsonlWriter struct {
+	encoder *json.Encoder
+}
+
+func (w *jsonlWriter) Write(r *record) error {
+	return w.encoder.Encode(r)
+}
+
+func (w *jsonlWriter) Flush() error {
+	return nil
+}
+
+type csvWriter struct {
+	writer *csv.Writer
+}
+
+func (w *csvWriter) Write(r *record) error {
+
+	e := r.Engagement
+
+	if e == nil {
+		e = &mastodon.Engagement{}
+	}
+
+	row := []string{
+		r.Time,
+		e.StatusId,
+		e.URL,
+		e.CreatedAt,
+		strconv.FormatInt(e.Favourites, 10),
+		strconv.FormatInt(e.Boosts, 10),
+		strconv.FormatInt(e.Replies, 10),
+		"",
+		"",
+		"",
+		"",
+		strings.Join(e.FavouritedBy, csv_separator),
+		strings.Join(e.BoostedBy, csv_separator),
+		r.Error,
+	}
+
+	if e.Poll != nil {
+
+		options := make([]string, len(e.Poll.Options))
+
+		for idx, o := range e.Poll.Options {
+			options[idx] = fmt.Sprintf("%s=%d", o.Title, o.Votes)
+		}
+
+		row[7] = strconv.FormatInt(e.Poll.Votes, 10)
+		row[8] = strconv.FormatInt(e.Poll.Voters, 10)
+		row[9] = strconv.FormatBool(e.Poll.Expired)
+		row[10] = strings.Join(options, csv_separator)
+	}
+
+	err := w.writer.Write(row)
+
+	if err != nil {
+		return err
+	}
+
+	// Flush after each record so that statistics are not lost if the tool is interrupted
+
+	w.writer.Flush()
+	return w.writer.Error()
+}
+
+func (w *csvWriter) Flush() error {
+	w.writer.Flush()
+	return w.writer.Error()
+}
@@ -0,0 +1,178 @@
+// Package subscribe provides methods for implementing a long-running application that broadcasts messages,
+// received from a gocloud.dev/pubsub subscription, to Mastodon.
+package subscribe
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"os"
+	"os/signal"
+	"slices"
+	"strings"
+	"syscall"
+
+	"github.com/aaronland/go-broadcaster"
+	"github.com/aaronland/go-broadcaster-mastodon"
+	"github.com/aaronland/go-broadcaster-mastodon/message"
+	"gocloud.dev/pubsub"
+	_ "gocloud.dev/pubsub/mempubsub"
+)
+
+func Run(ctx context.Context) error {
+	fs := DefaultFlagSet()
+	return RunWithFlagSet(ctx, fs)
+}
+
+func RunWithFlagSet(ctx context.Context, fs *flag.FlagSet) error {
+
+	opts, err := RunOptionsFromFlagSet(fs)
+
+	if err != nil {
+		return fmt.Errorf("Failed to derive run options, %w", err)
+	}
+
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	return RunWithOptions(ctx, opts)
+}
+
+// RunWithOptions receives messages from the subscription defined in 'opts' and broadcasts them until
+// 'ctx' is cancelled or the subscription fails.
+func RunWithOptions(ctx context.Context, opts *RunOptions) error {
+
+	if opts.Verbose {
+		slog.SetLogLoggerLevel(slog.LevelDebug)
+		slog.Debug("Verbose logging enabled")
+	}
+
+	if opts.BroadcasterURI == "" {
+		return fmt.Errorf("Missing broadcaster URI")
+	}
+
+	if opts.SubscriptionURI == "" {
+		return fmt.Errorf("Missing subscription URI")
+	}
+
+	if opts.MaxAttempts < 1 {
+		return fmt.Errorf("Max attempts must be greater than zero")
+	}
+
+	// Fail before connecting to Mastodon if the driver for a URI was not compiled in, see driver_*.go
+
+	mux := pubsub.DefaultURLMux()
+
+	err := ensureScheme(opts.SubscriptionURI, mux.ValidSubscriptionScheme, mux.SubscriptionSchemes())
+
+	if err != nil {
+		return fmt.Errorf("Invalid subscription URI, %w", err)
+	}
+
+	if opts.DeadLetterTopicURI != "" {
+
+		err := ensureScheme(opts.DeadLetterTopicURI, mux.ValidTopicScheme, mux.TopicSchemes())
+
+		if err != nil {
+			return fmt.Errorf("Invalid dead letter topic URI, %w", err)
+		}
+	}
+
+	br_uri := opts.BroadcasterURI
+
+	if opts.Dryrun {
+
+		dryrun_uri, err := mastodon.DryrunURI(br_uri, "")
+
+		if err != nil {
+			return err
+		}
+
+		br_uri = dryrun_uri
+	}
+
+	br, err := broadcaster.NewBroadcaster(ctx, br_uri)
+
+	if err != nil {
+		return fmt.Errorf("Failed to create broadcaster, %w", err)
+	}
+
+	mastodon_br, ok := br.(*mastodon.MastodonBroadcaster)
+
+	if !ok {
+		return fmt.Errorf("Broadcaster is not a Mastodon broadcaster")
+	}
+
+	// Wait for any test statuses scheduled for deletion to be deleted.
+	defer mastodon_br.Close(ctx)
+
+	sub, err := pubsub.OpenSubscription(ctx, opts.SubscriptionURI)
+
+	if err != nil {
+		return fmt.Errorf("Failed to open subscription, %w", err)
+	}
+
+	defer sub.Shutdown(context.Background())
+
+	c := &consumer{
+		broadcaster:  mastodon_br,
+		max_attempts: opts.MaxAttempts,
+		retry_delay:  opts.RetryDelay,
+		attempts:     make(map[string]int),
+		derive_opts: &message.DeriveOptions{
+			AllowURLs:  opts.AllowImageURLs,
+			AllowPaths: opts.AllowImagePaths,
+		},
+	}
+
+	if opts.DeadLetterTopicURI != "" {
+
+		topic, err := pubsub.OpenTopic(ctx, opts.DeadLetterTopicURI)
+
+		if err != nil {
+			return fmt.Errorf("Failed to open dead letter topic, %w", err)
+		}
+
+		defer topic.Shutdown(context.Background())
+		c.dead_letter = topic
+	}
+
+	slog.Info("Waiting for messages", "subscription", opts.SubscriptionURI)
+
+	for {
+
+		msg, err := sub.Receive(ctx)
+
+		if err != nil {
+
+			if errors.Is(err, context.Canceled) || ctx.Err() != nil {
+				return nil
+			}
+
+			return fmt.Errorf("Failed to receive message, %w", err)
+		}
+
+		c.handle(ctx, msg)
+	}
+}
+
+// ensureScheme returns an error if the scheme of 'uri' is not one for which 'valid' returns true. 'schemes' are the
+// valid schemes, reported in the error.
+func ensureScheme(uri string, valid func(string) bool, schemes []string) error {
+
+	u, err := url.Parse(uri)
+
+	if err != nil {
+		return fmt.Errorf("Failed to parse URI, %w", err)
+	}
+
+	if !valid(u.Scheme) {
+		slices.Sort(schemes)
+		return fmt.Errorf("Unsupported scheme '%s', this build supports '%s'. Other gocloud.dev/pubsub drivers are enabled using build tags", u.Scheme, strings.Join(schemes, "', '"))
+	}
+
+	return nil
+}
@@ -0,0 +1,182 @@
+package subscribe
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/aaronland/go-broadcaster-mastodon"
+	"github.com/aaronland/go-broadcaster-mastodon/message"
+	"gocloud.dev/pubsub"
+)
+
+// consumer broadcasts messages received from a subscription, acknowledging them only once they have been
+// broadcast successfully.
+type consumer struct {
+	broadcaster  *mastodon.MastodonBroadcaster
+	dead_letter  *pubsub.Topic
+	max_attempts int
+	retry_delay  time.Duration
+	derive_opts  *message.DeriveOptions
+	// Delivery attempts are tracked in memory, keyed by message ID, since gocloud.dev/pubsub does
+	// not expose delivery counts for all drivers. Counts are reset when the application restarts.
+	attempts map[string]int
+	mu       sync.Mutex
+}
+
+// handle broadcasts 'msg', acknowledging it on success. Messages which can not be decoded or derived are
+// dead-lettered immediately, as are messages which fail to broadcast for reasons that retrying will not fix (see
+// `mastodon.IsRetryable`); other messages which fail to broadcast are returned to the subscription until
+// they have been attempted `max_attempts` times after which they are dead-lettered. Messages which fail to
+// broadcast because 'ctx' was cancelled are always returned to the subscription.
+func (c *consumer) handle(ctx context.Context, msg *pubsub.Message) {
+
+	key := messageKey(msg)
+	logger := slog.Default().With("message", key)
+
+	var m *message.Message
+
+	err := json.Unmarshal(msg.Body, &m)
+
+	if err == nil && m == nil {
+		err = fmt.Errorf("Empty message")
+	}
+
+	if err != nil {
+		logger.Error("Failed to decode message", "error", err)
+		c.deadLetter(ctx, msg, key, 1, fmt.Errorf("Failed to decode message, %w", err))
+		return
+	}
+
+	bm, opts, err := m.Derive(ctx, c.derive_opts)
+
+	if err != nil {
+		logger.Error("Failed to derive message", "id", m.Id, "error", err)
+		c.deadLetter(ctx, msg, key, 1, fmt.Errorf("Failed to derive message, %w", err))
+		return
+	}
+
+	// Redelivered messages are posted with the same idempotency key so that a status created by an attempt that
+	// appeared to fail, for example by timing out, is not posted again.
+
+	opts.IdempotencyKey = fmt.Sprintf("%x", sha256.Sum256([]byte(key)))
+
+	attempt := c.recordAttempt(key)
+
+	rsp, err := c.broadcaster.PostMessage(ctx, bm, opts)
+
+	if err == nil {
+		logger.Info("Broadcast message", "id", m.Id, "status id", rsp.Id, "attempt", attempt)
+		c.forget(key)
+		msg.Ack()
+		return
+	}
+
+	logger.Error("Failed to broadcast message", "id", m.Id, "attempt", attempt, "error", err)
+
+	// The application is shutting down so the failure says nothing about the message; return it to the
+	// subscription rather than dead-lettering it.
+
+	if ctx.Err() != nil {
+
+		logger.Warn("Returning message to subscription after shutdown", "id", m.Id)
+
+		if msg.Nackable() {
+			msg.Nack()
+		}
+
+		return
+	}
+
+	if attempt >= c.max_attempts || !mastodon.IsRetryable(err) {
+		c.deadLetter(ctx, msg, key, attempt, err)
+		return
+	}
+
+	if !msg.Nackable() {
+		// Let the ack deadline expire so the message is redelivered.
+		return
+	}
+
+	select {
+	case <-ctx.Done():
+	case <-time.After(c.retry_delay):
+	}
+
+	msg.Nack()
+}
+
+// deadLetter publishes 'msg' to the dead letter topic, if defined, and then acknowledges it. If the
+// message can not be published to the dead letter topic it is returned to the subscription.
+func (c *consumer) deadLetter(ctx context.Context, msg *pubsub.Message, key string, attempts int, reason error) {
+
+	c.forget(key)
+
+	if c.dead_letter == nil {
+		slog.Error("Dropping message that can not be broadcast", "message", key, "attempts", attempts, "error", reason)
+		msg.Ack()
+		return
+	}
+
+	metadata := make(map[string]string)
+
+	for k, v := range msg.Metadata {
+		metadata[k] = v
+	}
+
+	metadata["broadcast_error"] = reason.Error()
+	metadata["broadcast_attempts"] = strconv.Itoa(attempts)
+
+	dl_msg := &pubsub.Message{
+		Body:     msg.Body,
+		Metadata: metadata,
+	}
+
+	err := c.dead_letter.Send(ctx, dl_msg)
+
+	if err != nil {
+
+		slog.Error("Failed to publish message to dead letter topic", "message", key, "error", err)
+
+		if msg.Nackable() {
+			msg.Nack()
+		}
+
+		return
+	}
+
+	slog.Warn("Published message to dead letter topic", "message", key, "attempts", attempts)
+	msg.Ack()
+}
+
+func (c *consumer) recordAttempt(key string) int {
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.attempts[key] += 1
+	return c.attempts[key]
+}
+
+func (c *consumer) forget(key string) {
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	delete(c.attempts, key)
+}
+
+// messageKey returns the driver-specific ID for 'msg' or, if absent, a hash of its body.
+func messageKey(msg *pubsub.Message) string {
+
+	if msg.LoggableID != "" {
+		return msg.LoggableID
+	}
+
+	return fmt.Sprintf("%x", sha256.Sum256(msg.Body))
+}
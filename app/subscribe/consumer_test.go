@@ -0,0 +1,332 @@
+package subscribe
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/aaronland/go-broadcaster-mastodon"
+	"github.com/aaronland/go-broadcaster-mastodon/message"
+	"gocloud.dev/pubsub"
+	_ "gocloud.dev/pubsub/mempubsub"
+)
+
+// consumerTest is a `consumer` reading from, and dead-lettering to, `mem://` topics, whose broadcaster posts to
+// a test server that responds to requests to post a status with a fixed HTTP status.
+type consumerTest struct {
+	consumer    *consumer
+	topic       *pubsub.Topic
+	sub         *pubsub.Subscription
+	dead_letter *pubsub.Subscription
+	posts       *atomic.Int32
+	// keys are the Idempotency-Key headers of requests to post a status, in the order they were received.
+	keys []string
+	mu   sync.Mutex
+}
+
+func newConsumerTest(t *testing.T, status int, max_attempts int) *consumerTest {
+
+	ctx := context.Background()
+
+	posts := new(atomic.Int32)
+
+	ct := &consumerTest{
+		posts: posts,
+	}
+
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(rsp http.ResponseWriter, req *http.Request) {
+
+		if req.Method != http.MethodPost || req.URL.Path != "/api/v1/statuses" {
+			http.NotFound(rsp, req)
+			return
+		}
+
+		posts.Add(1)
+
+		ct.mu.Lock()
+		ct.keys = append(ct.keys, req.Header.Get("Idempotency-Key"))
+		ct.mu.Unlock()
+
+		rsp.Header().Set("Content-Type", "application/json")
+		rsp.WriteHeader(status)
+
+		if status != http.StatusOK {
+			fmt.Fprintf(rsp, `{"error":"%s"}`, http.StatusText(status))
+			return
+		}
+
+		fmt.Fprintf(rsp, `{"id":"%d","url":"https://example.social/@test/%d"}`, posts.Load(), posts.Load())
+	}))
+
+	t.Cleanup(srv.Close)
+
+	br, err := mastodon.NewMastodonBroadcasterWithOptions(ctx, &mastodon.Options{
+		Host:        strings.TrimPrefix(srv.URL, "https://"),
+		AccessToken: "consumer-test-token",
+		HTTPClient:  srv.Client(),
+	})
+
+	if err != nil {
+		t.Fatalf("Failed to create broadcaster, %v", err)
+	}
+
+	t.Cleanup(func() {
+		br.Close(ctx)
+	})
+
+	topic_uri := fmt.Sprintf("mem://%s", strings.ReplaceAll(t.Name(), "/", "-"))
+	dead_letter_uri := topic_uri + "-dead-letter"
+
+	topic := openTopic(t, topic_uri)
+	sub := openSubscription(t, topic_uri)
+
+	dead_letter_topic := openTopic(t, dead_letter_uri)
+	dead_letter_sub := openSubscription(t, dead_letter_uri)
+
+	c := &consumer{
+		broadcaster:  br,
+		dead_letter:  dead_letter_topic,
+		max_attempts: max_attempts,
+		attempts:     make(map[string]int),
+		derive_opts:  &message.DeriveOptions{},
+	}
+
+	ct.consumer = c
+	ct.topic = topic
+	ct.sub = sub
+	ct.dead_letter = dead_letter_sub
+
+	return ct
+}
+
+func openTopic(t *testing.T, uri string) *pubsub.Topic {
+
+	topic, err := pubsub.OpenTopic(context.Background(), uri)
+
+	if err != nil {
+		t.Fatalf("Failed to open topic %s, %v", uri, err)
+	}
+
+	t.Cleanup(func() {
+		topic.Shutdown(context.Background())
+	})
+
+	return topic
+}
+
+func openSubscription(t *testing.T, uri string) *pubsub.Subscription {
+
+	sub, err := pubsub.OpenSubscription(context.Background(), uri)
+
+	if err != nil {
+		t.Fatalf("Failed to open subscription %s, %v", uri, err)
+	}
+
+	t.Cleanup(func() {
+		sub.Shutdown(context.Background())
+	})
+
+	return sub
+}
+
+// send publishes 'body' to the topic read by the consumer.
+func (ct *consumerTest) send(t *testing.T, body string) {
+
+	err := ct.topic.Send(context.Background(), &pubsub.Message{Body: []byte(body)})
+
+	if err != nil {
+		t.Fatalf("Failed to send message, %v", err)
+	}
+}
+
+// handleNext receives the next message from the consumer's subscription and handles it.
+func (ct *consumerTest) handleNext(t *testing.T) {
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	msg, err := ct.sub.Receive(ctx)
+
+	if err != nil {
+		t.Fatalf("Failed to receive message, %v", err)
+	}
+
+	ct.consumer.handle(ctx, msg)
+}
+
+// expectEmpty fails the test if a message is received from 'sub'.
+func expectEmpty(t *testing.T, sub *pubsub.Subscription, label string) {
+
+	ctx, cancel := context.WithTimeout(context.Background(), 250*time.Millisecond)
+	defer cancel()
+
+	msg, err := sub.Receive(ctx)
+
+	if err == nil {
+		msg.Ack()
+		t.Fatalf("Expected no messages on %s subscription, received '%s'", label, msg.Body)
+	}
+
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Failed to receive from %s subscription, %v", label, err)
+	}
+}
+
+// expectDeadLetter receives the next dead-lettered message and fails the test if its attempts do not equal 'attempts'.
+func (ct *consumerTest) expectDeadLetter(t *testing.T, attempts int) *pubsub.Message {
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	msg, err := ct.dead_letter.Receive(ctx)
+
+	if err != nil {
+		t.Fatalf("Expected a dead-lettered message, %v", err)
+	}
+
+	msg.Ack()
+
+	if msg.Metadata["broadcast_attempts"] != fmt.Sprintf("%d", attempts) {
+		t.Fatalf("Expected %d broadcast attempts, got '%s'", attempts, msg.Metadata["broadcast_attempts"])
+	}
+
+	if msg.Metadata["broadcast_error"] == "" {
+		t.Fatalf("Expected broadcast_error metadata for dead-lettered message")
+	}
+
+	return msg
+}
+
+func TestConsumerAck(t *testing.T) {
+
+	ct := newConsumerTest(t, http.StatusOK, 3)
+
+	ct.send(t, `{"body":"Hello world"}`)
+	ct.handleNext(t)
+
+	if ct.posts.Load() != 1 {
+		t.Fatalf("Expected 1 post, got %d", ct.posts.Load())
+	}
+
+	expectEmpty(t, ct.sub, "message")
+	expectEmpty(t, ct.dead_letter, "dead letter")
+}
+
+func TestConsumerRetry(t *testing.T) {
+
+	ct := newConsumerTest(t, http.StatusServiceUnavailable, 2)
+
+	ct.send(t, `{"body":"Hello world"}`)
+
+	// The first attempt fails with a server error and is returned to the subscription
+
+	ct.handleNext(t)
+
+	if ct.posts.Load() != 1 {
+		t.Fatalf("Expected 1 post, got %d", ct.posts.Load())
+	}
+
+	expectEmpty(t, ct.dead_letter, "dead letter")
+
+	// The second attempt reaches max attempts and is dead-lettered
+
+	ct.handleNext(t)
+
+	if ct.posts.Load() != 2 {
+		t.Fatalf("Expected 2 posts, got %d", ct.posts.Load())
+	}
+
+	ct.expectDeadLetter(t, 2)
+	expectEmpty(t, ct.sub, "message")
+
+	// Both attempts are posted with the same idempotency key
+
+	if ct.keys[0] == "" || ct.keys[0] != ct.keys[1] {
+		t.Fatalf("Expected attempts to share an idempotency key, got %v", ct.keys)
+	}
+}
+
+func TestConsumerShutdown(t *testing.T) {
+
+	ct := newConsumerTest(t, http.StatusOK, 1)
+
+	// Without a dead letter topic, messages which are dead-lettered are dropped
+
+	ct.consumer.dead_letter = nil
+
+	ct.send(t, `{"body":"Hello world"}`)
+
+	recv_ctx, recv_cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer recv_cancel()
+
+	msg, err := ct.sub.Receive(recv_ctx)
+
+	if err != nil {
+		t.Fatalf("Failed to receive message, %v", err)
+	}
+
+	// Handle the message as if the application had been asked to shut down mid-broadcast
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	ct.consumer.handle(ctx, msg)
+
+	if ct.posts.Load() != 0 {
+		t.Fatalf("Expected 0 posts, got %d", ct.posts.Load())
+	}
+
+	// The message is returned to the subscription and broadcast once the application is running again
+
+	ct.handleNext(t)
+
+	if ct.posts.Load() != 1 {
+		t.Fatalf("Expected 1 post, got %d", ct.posts.Load())
+	}
+}
+
+func TestConsumerDeadLetter(t *testing.T) {
+
+	tests := []struct {
+		name   string
+		status int
+		body   string
+		posts  int32
+	}{
+		{name: "undecodable", status: http.StatusOK, body: `{"body":`, posts: 0},
+		{name: "null", status: http.StatusOK, body: `null`, posts: 0},
+		{name: "invalid", status: http.StatusOK, body: `{"body":"Hello world","images":[{"path":"/tmp/test.jpg"}]}`, posts: 0},
+		{name: "rejected", status: http.StatusUnprocessableEntity, body: `{"body":"Hello world"}`, posts: 1},
+		{name: "unauthorized", status: http.StatusUnauthorized, body: `{"body":"Hello world"}`, posts: 1},
+	}
+
+	for _, tt := range tests {
+
+		t.Run(tt.name, func(t *testing.T) {
+
+			ct := newConsumerTest(t, tt.status, 5)
+
+			ct.send(t, tt.body)
+			ct.handleNext(t)
+
+			if ct.posts.Load() != tt.posts {
+				t.Fatalf("Expected %d posts, got %d", tt.posts, ct.posts.Load())
+			}
+
+			msg := ct.expectDeadLetter(t, 1)
+
+			if string(msg.Body) != tt.body {
+				t.Fatalf("Expected dead-lettered body '%s', got '%s'", tt.body, msg.Body)
+			}
+
+			expectEmpty(t, ct.sub, "message")
+		})
+	}
+}
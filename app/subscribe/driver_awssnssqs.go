@@ -0,0 +1,7 @@
+//go:build awssnssqs
+
+package subscribe
+
+import (
+	_ "gocloud.dev/pubsub/awssnssqs"
+)
@@ -0,0 +1,7 @@
+//go:build gcppubsub
+
+package subscribe
+
+import (
+	_ "gocloud.dev/pubsub/gcppubsub"
+)
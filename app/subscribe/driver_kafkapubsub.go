@@ -0,0 +1,7 @@
+//go:build kafkapubsub
+
+package subscribe
+
+import (
+	_ "gocloud.dev/pubsub/kafkapubsub"
+)
@@ -0,0 +1,7 @@
+//go:build natspubsub
+
+package subscribe
+
+import (
+	_ "gocloud.dev/pubsub/natspubsub"
+)
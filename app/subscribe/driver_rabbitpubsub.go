@@ -0,0 +1,7 @@
+//go:build rabbitpubsub
+
+package subscribe
+
+import (
+	_ "gocloud.dev/pubsub/rabbitpubsub"
+)
@@ -0,0 +1,51 @@
+package subscribe
+
+import (
+	"flag"
+	"time"
+
+	"github.com/sfomuseum/go-flags/flagset"
+)
+
+// A valid aaronland/go-broadcaster-mastodon URI.
+var broadcaster_uri string
+
+// A valid gocloud.dev/pubsub subscription URI.
+var subscription_uri string
+
+// An optional gocloud.dev/pubsub topic URI to publish messages that can not be broadcast to.
+var dead_letter_topic_uri string
+
+// The maximum number of attempts to broadcast a message before it is dead-lettered.
+var max_attempts int
+
+// The amount of time to wait before a failed message is returned to the subscription.
+var retry_delay time.Duration
+
+var allow_image_urls bool
+
+var allow_image_paths bool
+
+var dryrun bool
+
+var verbose bool
+
+func DefaultFlagSet() *flag.FlagSet {
+
+	fs := flagset.NewFlagSet("subscribe")
+
+	fs.StringVar(&broadcaster_uri, "broadcaster", "", "A valid aaronland/go-broadcaster-mastodon URI.")
+	fs.StringVar(&subscription_uri, "subscription-uri", "", "A valid gocloud.dev/pubsub subscription URI.")
+	fs.StringVar(&dead_letter_topic_uri, "dead-letter-topic-uri", "", "An optional gocloud.dev/pubsub topic URI to publish messages that can not be broadcast to. If empty such messages are logged and acknowledged.")
+
+	fs.IntVar(&max_attempts, "max-attempts", 5, "The maximum number of attempts to broadcast a message before it is dead-lettered.")
+	fs.DurationVar(&retry_delay, "retry-delay", 10*time.Second, "The amount of time to wait before a failed message is returned to the subscription.")
+
+	fs.BoolVar(&allow_image_urls, "allow-image-urls", false, "Allow images to be fetched from URLs specified in messages.")
+	fs.BoolVar(&allow_image_paths, "allow-image-paths", false, "Allow images to be read from local paths specified in messages.")
+
+	fs.BoolVar(&dryrun, "dryrun", false, "Enable dryrun mode, overriding any ?dryrun= parameter in the broadcaster URI.")
+	fs.BoolVar(&verbose, "verbose", false, "Enable verbose (debug) logging.")
+
+	return fs
+}
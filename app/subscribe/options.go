@@ -0,0 +1,56 @@
+package subscribe
+
+import (
+	"flag"
+	"time"
+
+	"github.com/sfomuseum/go-flags/flagset"
+)
+
+// RunOptions defines the configuration for running the subscribe application.
+type RunOptions struct {
+	// A valid aaronland/go-broadcaster-mastodon URI.
+	BroadcasterURI string
+	// A valid gocloud.dev/pubsub subscription URI.
+	SubscriptionURI string
+	// An optional gocloud.dev/pubsub topic URI to publish messages that can not be broadcast to.
+	DeadLetterTopicURI string
+	// The maximum number of attempts to broadcast a message before it is dead-lettered.
+	MaxAttempts int
+	// The amount of time to wait before a failed message is returned to the subscription.
+	RetryDelay time.Duration
+	// Allow images to be fetched from URLs specified in messages.
+	AllowImageURLs bool
+	// Allow images to be read from local paths specified in messages.
+	AllowImagePaths bool
+	// Enable dryrun mode.
+	Dryrun bool
+	// Enable verbose (debug) logging.
+	Verbose bool
+}
+
+// RunOptionsFromFlagSet derives a `RunOptions` instance from 'fs'.
+func RunOptionsFromFlagSet(fs *flag.FlagSet) (*RunOptions, error) {
+
+	flagset.Parse(fs)
+
+	err := flagset.SetFlagsFromEnvVars(fs, "BROADCASTER")
+
+	if err != nil {
+		return nil, err
+	}
+
+	opts := &RunOptions{
+		BroadcasterURI:     broadcaster_uri,
+		SubscriptionURI:    subscription_uri,
+		DeadLetterTopicURI: dead_letter_topic_uri,
+		MaxAttempts:        max_attempts,
+		RetryDelay:         retry_delay,
+		AllowImageURLs:     allow_image_urls,
+		AllowImagePaths:    allow_image_paths,
+		Dryrun:             dryrun,
+		Verbose:            verbose,
+	}
+
+	return opts, nil
+}
@@ -0,0 +1,258 @@
+// Package uri provides methods for implementing a command line tool for building correctly escaped mastodon://
+// broadcaster URIs and for explaining existing ones.
+package uri
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/aaronland/go-broadcaster-mastodon"
+	"github.com/sfomuseum/go-flags/flagset"
+)
+
+func Run(ctx context.Context) error {
+	fs := DefaultFlagSet()
+	return RunWithFlagSet(ctx, fs)
+}
+
+func RunWithFlagSet(ctx context.Context, fs *flag.FlagSet) error {
+
+	flagset.Parse(fs)
+
+	if explain != "" {
+		return explainURI(os.Stdout, explain)
+	}
+
+	uri, err := buildURI()
+
+	if err != nil {
+		return err
+	}
+
+	fmt.Println(uri)
+	return nil
+}
+
+// buildURI returns a new mastodon:// URI derived from the command line flags.
+func buildURI() (string, error) {
+
+	q := url.Values{}
+
+	for _, p := range params {
+
+		k, v, ok := strings.Cut(p, "=")
+
+		if !ok || k == "" {
+			return "", fmt.Errorf("Invalid -param flag '%s', expected key=value", p)
+		}
+
+		_, known := parameters[k]
+
+		if !known {
+			slog.Warn("Unknown broadcaster URI parameter", "parameter", k)
+		}
+
+		q.Add(k, v)
+	}
+
+	if profile != "" {
+
+		if host != "" || tokenSources() > 0 {
+			return "", fmt.Errorf("-profile can not be combined with -host or -token flags")
+		}
+
+		u := &url.URL{
+			Scheme:   "mastodon",
+			Host:     "profile",
+			Path:     "/" + profile,
+			RawQuery: q.Encode(),
+		}
+
+		return u.String(), nil
+	}
+
+	if host == "" {
+		return "", fmt.Errorf("Missing -host flag")
+	}
+
+	if tokenSources() != 1 {
+		return "", fmt.Errorf("Exactly one of -token, -token-file, -token-env or -token-parameter is required")
+	}
+
+	var token_uri string
+
+	switch {
+	case token != "":
+
+		token_q := url.Values{}
+		token_q.Set("val", token)
+
+		token_uri = "constant://?" + token_q.Encode()
+
+	case token_file != "":
+
+		// Relative paths would be parsed as the host of the file:// URI
+
+		token_path, err := filepath.Abs(token_file)
+
+		if err != nil {
+			return "", fmt.Errorf("Failed to derive absolute path for -token-file flag, %w", err)
+		}
+
+		token_q := url.Values{}
+		token_q.Set("decoder", "string")
+
+		token_u := &url.URL{
+			Scheme:   "file",
+			Path:     token_path,
+			RawQuery: token_q.Encode(),
+		}
+
+		token_uri = token_u.String()
+
+	case token_env != "":
+		token_uri = "env://" + token_env
+
+	case token_parameter != "":
+
+		token_q := url.Values{}
+		token_q.Set("region", aws_region)
+		token_q.Set("decoder", "string")
+
+		if aws_credentials != "" {
+			token_q.Set("credentials", aws_credentials)
+		}
+
+		token_u := &url.URL{
+			Scheme:   "awsparamstore",
+			Host:     token_parameter,
+			RawQuery: token_q.Encode(),
+		}
+
+		token_uri = token_u.String()
+	}
+
+	q.Set("host", host)
+	q.Set("token", token_uri)
+
+	return "mastodon://?" + q.Encode(), nil
+}
+
+// tokenSources returns the number of token source flags that have been set.
+func tokenSources() int {
+
+	count := 0
+
+	for _, v := range []string{token, token_file, token_env, token_parameter} {
+
+		if v != "" {
+			count += 1
+		}
+	}
+
+	return count
+}
+
+// explainURI writes a description of each part of 'uri', with any access tokens redacted, to 'wr'.
+func explainURI(wr io.Writer, uri string) error {
+
+	u, err := url.Parse(uri)
+
+	if err != nil {
+		return fmt.Errorf("Failed to parse URI, %w", err)
+	}
+
+	if u.Scheme != "mastodon" {
+		return fmt.Errorf("Invalid scheme '%s', expected 'mastodon'", u.Scheme)
+	}
+
+	tw := tabwriter.NewWriter(wr, 0, 4, 2, ' ', 0)
+
+	fmt.Fprintf(tw, "URI\t%s\n", mastodon.RedactURI(uri))
+
+	if u.Host == "profile" {
+		fmt.Fprintf(tw, "Profile\t%s\n", strings.Trim(u.Path, "/"))
+	}
+
+	q := u.Query()
+
+	keys := make([]string, 0, len(q))
+
+	for k := range q {
+		keys = append(keys, k)
+	}
+
+	sort.Strings(keys)
+
+	for _, k := range keys {
+
+		desc, known := parameters[k]
+
+		if !known {
+			desc = "Unknown parameter."
+		}
+
+		for _, v := range q[k] {
+
+			switch k {
+			case "account":
+				fmt.Fprintf(tw, "%s\t%s\n", k, mastodon.RedactURI(v))
+				fmt.Fprintf(tw, "\t%s\n", desc)
+			case "credentials", "testing_credentials", "token":
+				fmt.Fprintf(tw, "%s\t%s\n", k, mastodon.RedactSecretURI(v))
+				fmt.Fprintf(tw, "\t%s\n", desc)
+				fmt.Fprintf(tw, "\t%s\n", describeSecret(v))
+			default:
+				fmt.Fprintf(tw, "%s\t%s\n", k, v)
+				fmt.Fprintf(tw, "\t%s\n", desc)
+			}
+		}
+	}
+
+	return tw.Flush()
+}
+
+// describeSecret returns a description of where the secret referenced by 'uri', a sfomuseum/runtimevar
+// (or env://) URI, is read from.
+func describeSecret(uri string) string {
+
+	u, err := url.Parse(uri)
+
+	if err != nil {
+		return "Invalid URI."
+	}
+
+	q := u.Query()
+
+	switch u.Scheme {
+	case "constant":
+
+		client_u, err := url.Parse(q.Get("val"))
+
+		if err == nil && client_u.Scheme != "" && client_u.Host != "" {
+			return fmt.Sprintf("A constant %s client URI for %s.", client_u.Scheme, client_u.Host)
+		}
+
+		return "A constant value."
+
+	case "file":
+		return fmt.Sprintf("Read from the file %s.", u.Path)
+	case "env":
+		return fmt.Sprintf("Read from the environment variable %s.", u.Host)
+	case "awsparamstore":
+		return fmt.Sprintf("Read from the AWS Parameter Store parameter %s in %s.", u.Host, q.Get("region"))
+	case "":
+		return "A literal value."
+	default:
+		return fmt.Sprintf("Read from a %s runtimevar.", u.Scheme)
+	}
+}
@@ -0,0 +1,114 @@
+package uri
+
+import (
+	"net/url"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// resetFlags restores the package-level flag values to their defaults once the test has finished.
+func resetFlags(t *testing.T) {
+
+	t.Cleanup(func() {
+		host = ""
+		token = ""
+		token_file = ""
+		token_env = ""
+		token_parameter = ""
+		aws_region = "us-east-1"
+		aws_credentials = ""
+		profile = ""
+		params = nil
+	})
+}
+
+func TestBuildAndExplainURI(t *testing.T) {
+
+	abs_path, err := filepath.Abs("token.txt")
+
+	if err != nil {
+		t.Fatalf("Failed to derive absolute path, %v", err)
+	}
+
+	tests := []struct {
+		name string
+		set  func()
+		// token is the expected value of the token parameter of the URI
+		token string
+		// explained is text expected in the explanation of the URI
+		explained string
+	}{
+		{
+			name:      "token",
+			set:       func() { token = "s3cr3t" },
+			token:     "constant://?val=s3cr3t",
+			explained: "A constant value.",
+		},
+		{
+			name:      "token file",
+			set:       func() { token_file = "token.txt" },
+			token:     "file://" + abs_path + "?decoder=string",
+			explained: "Read from the file " + abs_path + ".",
+		},
+		{
+			name:      "token env",
+			set:       func() { token_env = "MASTODON_TOKEN" },
+			token:     "env://MASTODON_TOKEN",
+			explained: "Read from the environment variable MASTODON_TOKEN.",
+		},
+		{
+			name:      "token parameter",
+			set:       func() { token_parameter = "mastodon-token"; aws_region = "us-west-2" },
+			token:     "awsparamstore://mastodon-token?decoder=string&region=us-west-2",
+			explained: "Read from the AWS Parameter Store parameter mastodon-token in us-west-2.",
+		},
+	}
+
+	for _, tt := range tests {
+
+		t.Run(tt.name, func(t *testing.T) {
+
+			resetFlags(t)
+
+			host = "example.social"
+			tt.set()
+
+			uri, err := buildURI()
+
+			if err != nil {
+				t.Fatalf("Failed to build URI, %v", err)
+			}
+
+			u, err := url.Parse(uri)
+
+			if err != nil {
+				t.Fatalf("Failed to parse URI %s, %v", uri, err)
+			}
+
+			if u.Query().Get("host") != "example.social" {
+				t.Fatalf("Expected host 'example.social', got '%s'", u.Query().Get("host"))
+			}
+
+			if u.Query().Get("token") != tt.token {
+				t.Fatalf("Expected token '%s', got '%s'", tt.token, u.Query().Get("token"))
+			}
+
+			var buf strings.Builder
+
+			err = explainURI(&buf, uri)
+
+			if err != nil {
+				t.Fatalf("Failed to explain URI %s, %v", uri, err)
+			}
+
+			if !strings.Contains(buf.String(), tt.explained) {
+				t.Fatalf("Expected explanation to contain '%s', got:\n%s", tt.explained, buf.String())
+			}
+
+			if strings.Contains(buf.String(), "s3cr3t") {
+				t.Fatalf("Expected explanation to redact access token, got:\n%s", buf.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,56 @@
+package uri
+
+import (
+	"flag"
+
+	"github.com/sfomuseum/go-flags/flagset"
+	"github.com/sfomuseum/go-flags/multi"
+)
+
+// The hostname of the Mastodon instance to post to.
+var host string
+
+// An access token to include in the URI as a constant.
+var token string
+
+// The path to a file containing the access token.
+var token_file string
+
+// The name of an environment variable containing the access token.
+var token_env string
+
+// The name of an AWS Parameter Store parameter containing the access token.
+var token_parameter string
+
+// The AWS region of the -token-parameter parameter.
+var aws_region string
+
+// An aaronland/go-aws-auth credentials string used to read the -token-parameter parameter.
+var aws_credentials string
+
+// The name of a profile to reference rather than a host and access token.
+var profile string
+
+// Zero or more key=value broadcaster URI parameters.
+var params multi.MultiString
+
+// A mastodon:// URI to decode and explain rather than building a new one.
+var explain string
+
+func DefaultFlagSet() *flag.FlagSet {
+
+	fs := flagset.NewFlagSet("uri")
+
+	fs.StringVar(&host, "host", "", "The hostname of the Mastodon instance to post to.")
+	fs.StringVar(&token, "token", "", "An access token to include in the URI as a constant. Consider using -token-file, -token-env or -token-parameter instead.")
+	fs.StringVar(&token_file, "token-file", "", "The path to a file containing the access token. Relative paths are made absolute.")
+	fs.StringVar(&token_env, "token-env", "", "The name of an environment variable containing the access token.")
+	fs.StringVar(&token_parameter, "token-parameter", "", "The name of an AWS Parameter Store parameter containing the access token.")
+	fs.StringVar(&aws_region, "aws-region", "us-east-1", "The AWS region of the -token-parameter parameter.")
+	fs.StringVar(&aws_credentials, "aws-credentials", "", "An optional aaronland/go-aws-auth credentials string used to read the -token-parameter parameter.")
+	fs.StringVar(&profile, "profile", "", "The name of a profile to reference rather than a host and access token.")
+	fs.Var(&params, "param", "Zero or more key=value broadcaster URI parameters, for example -param visibility=unlisted.")
+	fs.StringVar(&explain, "explain", "", "A mastodon:// URI to decode and explain, with any access tokens redacted, rather than building a new one.")
+
+	return fs
+}
@@ -0,0 +1,44 @@
+package uri
+
+// parameters maps the known broadcaster URI parameters to their descriptions.
+var parameters = map[string]string{
+	"account":                   "A mastodon:// URI for an additional account that messages are also broadcast with.",
+	"amplify":                   "If true additional accounts boost the status posted by the primary account instead of posting their own.",
+	"ca_bundle":                 "A file of PEM-encoded certificates to trust when connecting to the Mastodon instance.",
+	"config":                    "The profiles configuration file.",
+	"content_type":              "The default content type of message bodies, for example text/markdown.",
+	"credentials":               "A runtimevar URI which resolves to an aaronland/go-mastodon-api client URI.",
+	"dryrun":                    "If true messages are logged but not posted.",
+	"dryrun_output":             "A directory to write the requests for messages posted in dryrun mode to.",
+	"flavour":                   "The server software of the instance: mastodon, gotosocial, akkoma or pleroma.",
+	"host":                      "The hostname of the Mastodon instance to post to.",
+	"language":                  "The default ISO 639 language code for statuses.",
+	"max_images":                "The maximum number of images a message may have.",
+	"name":                      "A name for the account, used to identify it in the results of broadcasters with additional accounts.",
+	"overflow":                  "How to handle statuses that exceed the instance's maximum length.",
+	"pin":                       "If true every status is pinned to the profile of the account after it is posted.",
+	"pin_job":                   "The name of the job statuses are pinned by.",
+	"pin_rotate":                "If true the status previously pinned by the same job is unpinned.",
+	"pin_state":                 "A JSON file recording the status most recently pinned by each job.",
+	"policy":                    "A content policy file.",
+	"proxy":                     "The URL of an HTTP proxy to send requests through.",
+	"quality":                   "The JPEG quality to encode images with.",
+	"request_timeout":           "The maximum amount of time a single request may take.",
+	"require_alt_text":          "If true messages with images that are missing alt text are not posted.",
+	"require_direct_recipients": "If true direct statuses are not posted unless every mentioned account resolves.",
+	"retries":                   "The number of times a failed request is retried.",
+	"retry_delay":               "The delay before the first retry.",
+	"sensitive":                 "If true media attached to every status is marked as sensitive.",
+	"tags":                      "Default hashtags to append to every status.",
+	"template":                  "A Go text/template used to render statuses.",
+	"testing":                   "If true messages are handled according to the testing policy.",
+	"testing_credentials":       "A runtimevar URI for the credentials of a sandbox account to post test messages with.",
+	"testing_delete_after":      "The amount of time after which test statuses are deleted.",
+	"testing_prefix":            "A Go text/template used to render a prefix for test statuses.",
+	"testing_visibility":        "The visibility that all test statuses are posted with.",
+	"timeout":                   "The maximum amount of time broadcasting a single message may take.",
+	"token":                     "A runtimevar (or env://) URI which resolves to the access token used to post to the host.",
+	"user_agent":                "The value of the User-Agent header sent with requests.",
+	"validate_mentions":         "Resolve every account mentioned in a status before posting.",
+	"visibility":                "The default visibility for statuses.",
+}
@@ -0,0 +1,328 @@
+// Package watch provides methods for implementing a long-running command line tool for forwarding the replies to
+// statuses broadcast by a Mastodon account to other aaronland/go-broadcaster targets.
+package watch
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"time"
+
+	"github.com/aaronland/go-broadcaster"
+	"github.com/aaronland/go-broadcaster-mastodon"
+	"github.com/sfomuseum/go-flags/flagset"
+)
+
+// target is a broadcaster that notifications are forwarded to.
+type target struct {
+	// uri is the redacted URI of the broadcaster, used for logging.
+	uri string
+	br  broadcaster.Broadcaster
+}
+
+// watcher polls a Mastodon account for new notifications and forwards them to one or more targets.
+type watcher struct {
+	br      *mastodon.MastodonBroadcaster
+	account *mastodon.Account
+	targets []*target
+	state   *state
+	// broadcasts are the IDs of the statuses recorded in the -ledger files, if any. Only replies to these
+	// statuses are forwarded.
+	broadcasts map[string]bool
+}
+
+func Run(ctx context.Context) error {
+	fs := DefaultFlagSet()
+	return RunWithFlagSet(ctx, fs)
+}
+
+func RunWithFlagSet(ctx context.Context, fs *flag.FlagSet) error {
+
+	flagset.Parse(fs)
+
+	if verbose {
+		slog.SetLogLoggerLevel(slog.LevelDebug)
+		slog.Debug("Verbose logging enabled")
+	}
+
+	if broadcaster_uri == "" {
+		return fmt.Errorf("Missing -broadcaster flag")
+	}
+
+	if len(targets) == 0 {
+		return fmt.Errorf("Missing -target flag")
+	}
+
+	if state_path == "" {
+		return fmt.Errorf("Missing -state flag")
+	}
+
+	if backfill < 0 {
+		return fmt.Errorf("-backfill must not be negative")
+	}
+
+	if !once && poll <= 0 {
+		return fmt.Errorf("-poll must be greater than zero")
+	}
+
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt)
+	defer stop()
+
+	br, err := broadcaster.NewBroadcaster(ctx, broadcaster_uri)
+
+	if err != nil {
+		return fmt.Errorf("Failed to create broadcaster, %w", err)
+	}
+
+	mastodon_br, ok := br.(*mastodon.MastodonBroadcaster)
+
+	if !ok {
+		return fmt.Errorf("Broadcaster is not a Mastodon broadcaster")
+	}
+
+	defer mastodon_br.Close(ctx)
+
+	acct, err := mastodon_br.VerifyCredentials(ctx)
+
+	if err != nil {
+		return err
+	}
+
+	w := &watcher{
+		br:      mastodon_br,
+		account: acct,
+		targets: make([]*target, 0),
+	}
+
+	for _, uri := range targets {
+
+		target_uri := mastodon.RedactURI(uri)
+
+		target_br, err := broadcaster.NewBroadcaster(ctx, uri)
+
+		if err != nil {
+			return fmt.Errorf("Failed to create target broadcaster %s, %w", target_uri, mastodon.RedactError(err))
+		}
+
+		w.targets = append(w.targets, &target{
+			uri: target_uri,
+			br:  target_br,
+		})
+	}
+
+	s, err := readState(state_path)
+
+	if err != nil {
+		return err
+	}
+
+	w.state = s
+
+	slog.Info("Watching notifications", "account", acct.Acct, "targets", len(w.targets))
+
+	for {
+
+		err := w.poll(ctx)
+
+		if err != nil {
+
+			if ctx.Err() != nil {
+				return nil
+			}
+
+			if once {
+				return err
+			}
+
+			// Transient failures, like rate limits or an unavailable instance, are retried on the next poll
+
+			slog.Error("Failed to process notifications", "error", err)
+		}
+
+		if once {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(poll):
+			// pass
+		}
+	}
+}
+
+// poll retrieves the notifications received since the last time it was called and forwards the replies to
+// broadcast statuses to each target. State is written after each notification is forwarded so if forwarding fails
+// the notification, and any newer ones, are retried the next time poll is called.
+func (w *watcher) poll(ctx context.Context) error {
+
+	first_run := w.state.Cursor == ""
+
+	opts := &mastodon.NotificationsOptions{
+		Types: []string{mastodon.NotificationMention},
+		MinId: w.state.Cursor,
+	}
+
+	// Ledgers are read each time since they may be appended to, by other tools, while the account is watched
+
+	if len(ledgers) > 0 {
+
+		broadcasts, err := readBroadcasts(ledgers)
+
+		if err != nil {
+			return err
+		}
+
+		w.broadcasts = broadcasts
+	}
+
+	notifications, err := w.br.GetNotifications(ctx, opts)
+
+	if err != nil {
+		return err
+	}
+
+	slog.Debug("Retrieved notifications", "count", len(notifications), "cursor", w.state.Cursor)
+
+	// Don't flood the targets with an account's entire history the first time it is watched
+
+	skip := make(map[string]bool)
+
+	if first_run && len(notifications) > backfill {
+
+		for _, n := range notifications[:len(notifications)-backfill] {
+			skip[n.Id] = true
+		}
+
+		slog.Info("Limited first run to backfill", "forwarding", backfill, "skipped", len(skip))
+	}
+
+	forwarded := 0
+
+	for _, n := range notifications {
+
+		logger := slog.Default().With("notification", n.Id, "account", n.Account)
+
+		_, seen := w.state.Seen[n.Id]
+
+		switch {
+		case seen:
+			logger.Debug("Skipping notification that has already been forwarded")
+		case skip[n.Id]:
+			logger.Debug("Skipping notification during backfill")
+		case !w.include(n):
+			logger.Debug("Skipping notification")
+		default:
+
+			err := w.forward(ctx, n)
+
+			if err != nil {
+				return fmt.Errorf("Failed to forward notification %s, %w", n.Id, err)
+			}
+
+			forwarded += 1
+		}
+
+		if dryrun {
+			continue
+		}
+
+		w.state.Seen[n.Id] = time.Now().UTC().Format(time.RFC3339)
+
+		if mastodon.CompareIds(n.Id, w.state.Cursor) > 0 {
+			w.state.Cursor = n.Id
+		}
+
+		err := writeState(state_path, w.state)
+
+		if err != nil {
+			return err
+		}
+	}
+
+	if forwarded > 0 {
+		slog.Info("Forwarded notifications", "count", forwarded)
+	}
+
+	return nil
+}
+
+// include returns true if the notification 'n' should be forwarded.
+func (w *watcher) include(n *mastodon.Notification) bool {
+
+	if n.Type != mastodon.NotificationMention || n.Status == nil {
+		return false
+	}
+
+	// Self-mentions, for example the replies posted when a status is split in to a thread
+
+	if n.Status.Account == w.account.Acct {
+		return false
+	}
+
+	// Only replies to the statuses posted by this tool are forwarded, not every mention of the account
+
+	if n.Status.InReplyToId == "" {
+		return false
+	}
+
+	if w.broadcasts != nil {
+		return w.broadcasts[n.Status.InReplyToId]
+	}
+
+	return n.Status.InReplyToAccountId == w.account.Id
+}
+
+// readBroadcasts returns the IDs of the statuses recorded in the ledger files 'paths'.
+func readBroadcasts(paths []string) (map[string]bool, error) {
+
+	broadcasts := make(map[string]bool)
+
+	for _, path := range paths {
+
+		ids, err := mastodon.ReadLedger(path)
+
+		if err != nil {
+			return nil, err
+		}
+
+		for _, id := range ids {
+			broadcasts[id] = true
+		}
+	}
+
+	return broadcasts, nil
+}
+
+// forward broadcasts a message derived from the notification 'n' to each target. Targets are not rolled back
+// if a later target fails so a notification may be forwarded to the same target more than once.
+func (w *watcher) forward(ctx context.Context, n *mastodon.Notification) error {
+
+	msg := deriveMessage(n)
+
+	if dryrun {
+		slog.Info("Forward notification", "notification", n.Id, "title", msg.Title, "body", msg.Body, "dryrun", true)
+		return nil
+	}
+
+	errs := make([]error, 0)
+
+	for _, t := range w.targets {
+
+		id, err := t.br.BroadcastMessage(ctx, msg)
+
+		if err != nil {
+			errs = append(errs, fmt.Errorf("Failed to broadcast to %s, %w", t.uri, mastodon.RedactError(err)))
+			continue
+		}
+
+		slog.Debug("Forwarded notification", "notification", n.Id, "target", t.uri, "id", id)
+	}
+
+	return errors.Join(errs...)
+}
@@ -0,0 +1,53 @@
+package watch
+
+import (
+	"flag"
+	"time"
+
+	"github.com/sfomuseum/go-flags/flagset"
+	"github.com/sfomuseum/go-flags/multi"
+)
+
+// A valid aaronland/go-broadcaster-mastodon URI for the account whose notifications are watched.
+var broadcaster_uri string
+
+// One or more aaronland/go-broadcaster URIs to forward replies to.
+var targets multi.MultiString
+
+// The path to a JSON file recording the notifications that have already been forwarded.
+var state_path string
+
+// The interval between polling for new notifications.
+var poll time.Duration
+
+// The maximum number of (the most recent) notifications to forward the first time the account is watched.
+var backfill int
+
+// Zero or more JSONL ledger files recording the statuses broadcast by the account being watched.
+var ledgers multi.MultiString
+
+// Poll for new notifications once and exit.
+var once bool
+
+var dryrun bool
+
+var verbose bool
+
+func DefaultFlagSet() *flag.FlagSet {
+
+	fs := flagset.NewFlagSet("watch")
+
+	fs.StringVar(&broadcaster_uri, "broadcaster", "", "A valid aaronland/go-broadcaster-mastodon URI for the account whose notifications are watched.")
+	fs.Var(&targets, "target", "One or more valid aaronland/go-broadcaster URIs to forward replies to, for example log:// or another mastodon:// URI.")
+	fs.StringVar(&state_path, "state", "", "The path to a JSON file recording the notifications that have already been forwarded. It will be created if it does not exist.")
+
+	fs.DurationVar(&poll, "poll", time.Minute, "The interval between polling for new notifications.")
+	fs.IntVar(&backfill, "backfill", 0, "The maximum number of (the most recent) notifications to forward the first time an account is watched. Older notifications are recorded as seen but not forwarded.")
+	fs.Var(&ledgers, "ledger", "Zero or more JSONL files recording the statuses that have been broadcast, for example the -results file written by the batch tool or the -ledger file written by the mirror tool. If set only replies to those statuses are forwarded, otherwise replies to any status posted by the account being watched are forwarded.")
+	fs.BoolVar(&once, "once", false, "Poll for new notifications once and exit, for example when run from cron.")
+
+	fs.BoolVar(&dryrun, "dryrun", false, "Log the messages that would be forwarded rather than forwarding them. State is not updated in dryrun mode.")
+	fs.BoolVar(&verbose, "verbose", false, "Enable verbose (debug) logging.")
+
+	return fs
+}
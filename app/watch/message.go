@@ -0,0 +1,79 @@
+package watch
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode"
+
+	"github.com/aaronland/go-broadcaster"
+	"github.com/aaronland/go-broadcaster-mastodon"
+	"github.com/aaronland/go-broadcaster-mastodon/feed"
+)
+
+// Mentions are "@" followed by a username, at the start of the text or after a character that can not be part of a
+// word, URL or mention, following the same rules Mastodon uses to recognize mentions.
+var re_mention = regexp.MustCompile(`(^|[^\p{L}\p{N}_/@])@([\p{L}\p{N}_])`)
+
+// zero_width_space is inserted after the "@" of a mention so that it is displayed unchanged but not recognized.
+const zero_width_space = "\u200B"
+
+// deriveMessage returns a new `broadcaster.Message` describing the reply associated with the notification 'n'.
+func deriveMessage(n *mastodon.Notification) *broadcaster.Message {
+
+	st := n.Status
+
+	// The account is not prefixed with "@", leading mentions are removed from the text and any other mentions are
+	// neutralized so that forwarding to another Mastodon account does not mention, and notify, anyone (including
+	// the account being watched)
+
+	title := fmt.Sprintf("Reply from %s", n.Account)
+
+	text := neutralizeMentions(trimMentions(feed.StripHTML(st.Content)))
+
+	parts := make([]string, 0)
+
+	if st.SpoilerText != "" {
+		parts = append(parts, fmt.Sprintf("CW: %s", neutralizeMentions(st.SpoilerText)))
+	}
+
+	if text != "" {
+		parts = append(parts, text)
+	}
+
+	parts = append(parts, st.URL)
+
+	msg := &broadcaster.Message{
+		Title: title,
+		Body:  strings.Join(parts, "\n\n"),
+	}
+
+	return msg
+}
+
+// trimMentions removes the leading mentions, like the ones Mastodon adds to replies, from 'text'. Only the leading
+// words are inspected so the remaining text keeps its line breaks.
+func trimMentions(text string) string {
+
+	rest := strings.TrimLeftFunc(text, unicode.IsSpace)
+
+	for strings.HasPrefix(rest, "@") {
+
+		end := strings.IndexFunc(rest, unicode.IsSpace)
+
+		if end == -1 {
+			return ""
+		}
+
+		rest = strings.TrimLeftFunc(rest[end:], unicode.IsSpace)
+	}
+
+	return strings.TrimSpace(rest)
+}
+
+// neutralizeMentions inserts a zero width space after the "@" of each "@user" or "@user@domain" mention in 'text'
+// so that posting it does not mention, and notify, the accounts. Email addresses are left as-is since Mastodon
+// does not recognize them as mentions.
+func neutralizeMentions(text string) string {
+	return re_mention.ReplaceAllString(text, "${1}@"+zero_width_space+"${2}")
+}
@@ -0,0 +1,78 @@
+package watch
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"slices"
+
+	"github.com/aaronland/go-broadcaster-mastodon"
+)
+
+// The maximum number of forwarded notification IDs to remember.
+const max_seen = 1000
+
+// state records the notifications that have already been forwarded.
+type state struct {
+	// Cursor is the ID of the most recent notification that has been processed.
+	Cursor string `json:"cursor,omitempty"`
+	// Seen maps the IDs of the most recently processed notifications to the time they were processed.
+	Seen map[string]string `json:"seen"`
+}
+
+// readState reads the state file at 'path'. If the file does not exist an empty state is returned.
+func readState(path string) (*state, error) {
+
+	s := &state{
+		Seen: make(map[string]string),
+	}
+
+	body, err := os.ReadFile(path)
+
+	if os.IsNotExist(err) {
+		return s, nil
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to read %s, %w", path, err)
+	}
+
+	err = json.Unmarshal(body, s)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to parse %s, %w", path, err)
+	}
+
+	if s.Seen == nil {
+		s.Seen = make(map[string]string)
+	}
+
+	return s, nil
+}
+
+// prune removes all but the 'max_seen' most recent notification IDs from 's'.
+func (s *state) prune() {
+
+	if len(s.Seen) <= max_seen {
+		return
+	}
+
+	ids := make([]string, 0, len(s.Seen))
+
+	for id := range s.Seen {
+		ids = append(ids, id)
+	}
+
+	slices.SortFunc(ids, mastodon.CompareIds)
+
+	for _, id := range ids[:len(ids)-max_seen] {
+		delete(s.Seen, id)
+	}
+}
+
+// writeState atomically writes 's' to 'path'.
+func writeState(path string, s *state) error {
+
+	s.prune()
+	return mastodon.WriteJSONFile(path, s)
+}
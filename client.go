@@ -0,0 +1,229 @@
+package mastodon
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+)
+
+// OAuth2Client implements the aaronland/go-mastodon-api `client.Client` interface using OAuth2 access tokens for
+// authentication and authorization. Unlike the `client.OAuth2Client` implementation in aaronland/go-mastodon-api, failed API calls return typed
+// errors (see `APIError`) that include the error message in the response body, any 2XX response is considered
+// successful and arguments passed to `UploadMedia` are included with the upload. `NewMastodonBroadcaster` uses
+// this implementation for "oauth2://" client URIs.
+type OAuth2Client struct {
+	http_client  *http.Client
+	api_endpoint *url.URL
+	access_token string
+	user_agent   string
+}
+
+// NewOAuth2Client returns a new `OAuth2Client` instance configured by 'uri' which is expected to take
+// the form of:
+//
+//	oauth2://:{OAUTH2_ACCESS_TOKEN}@{MASTODON_HOST}
+func NewOAuth2Client(ctx context.Context, uri string) (*OAuth2Client, error) {
+
+	u, err := url.Parse(uri)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to parse URI, %w", err)
+	}
+
+	if u.Host == "" {
+		return nil, fmt.Errorf("Missing Mastodon host")
+	}
+
+	api_endpoint, err := url.Parse(fmt.Sprintf("https://%s", u.Host))
+
+	if err != nil {
+		return nil, fmt.Errorf("Invalid Mastodon host, %w", err)
+	}
+
+	cl := &OAuth2Client{
+		http_client:  &http.Client{},
+		api_endpoint: api_endpoint,
+	}
+
+	token, ok := u.User.Password()
+
+	if ok {
+		cl.access_token = token
+	}
+
+	return cl, nil
+}
+
+// SetHTTPClient assigns 'http_client' to 'cl' to be used for all subsequent API calls.
+func (cl *OAuth2Client) SetHTTPClient(http_client *http.Client) {
+	cl.http_client = http_client
+}
+
+// SetUserAgent assigns 'user_agent' as the value of the User-Agent header for all subsequent API calls.
+func (cl *OAuth2Client) SetUserAgent(user_agent string) {
+	cl.user_agent = user_agent
+}
+
+// ExecuteMethod will execute a Mastodon API method where 'api_method' is expected to be the
+// relative URI for a given Mastodon API method.
+func (cl *OAuth2Client) ExecuteMethod(ctx context.Context, http_method string, api_method string, args *url.Values) (io.ReadSeekCloser, error) {
+
+	req_endpoint := cl.requestEndpoint(api_method)
+
+	if args != nil {
+		req_endpoint.RawQuery = args.Encode()
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http_method, req_endpoint.String(), nil)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to create API request, %w", err)
+	}
+
+	return cl.call(req, api_method)
+}
+
+// UploadMedia will upload the contents of 'r' as a media element, with any additional parameters
+// (for example "description") defined in 'args', using the Mastodon API.
+func (cl *OAuth2Client) UploadMedia(ctx context.Context, r io.Reader, args *url.Values) (io.ReadSeekCloser, error) {
+	return cl.uploadMedia(ctx, media_v1, r, args)
+}
+
+// uploadMedia uploads the contents of 'r' as a media element, with any additional parameters defined in 'args',
+// using the media API method 'api_method'.
+func (cl *OAuth2Client) uploadMedia(ctx context.Context, api_method string, r io.Reader, args *url.Values) (io.ReadSeekCloser, error) {
+
+	var buf bytes.Buffer
+	mw := multipart.NewWriter(&buf)
+
+	if args != nil {
+
+		for k, values := range *args {
+
+			for _, v := range values {
+
+				err := mw.WriteField(k, v)
+
+				if err != nil {
+					return nil, fmt.Errorf("Failed to write form field '%s', %w", k, err)
+				}
+			}
+		}
+	}
+
+	file, err := mw.CreateFormFile("file", "upload")
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to create form file, %w", err)
+	}
+
+	_, err = io.Copy(file, r)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to copy media to form file, %w", err)
+	}
+
+	err = mw.Close()
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to close form file, %w", err)
+	}
+
+	req_endpoint := cl.requestEndpoint(api_method)
+
+	req, err := http.NewRequestWithContext(ctx, "POST", req_endpoint.String(), bytes.NewReader(buf.Bytes()))
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to create upload request, %w", err)
+	}
+
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+
+	return cl.call(req, api_method)
+}
+
+func (cl *OAuth2Client) call(req *http.Request, api_method string) (io.ReadSeekCloser, error) {
+
+	if cl.access_token != "" {
+		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", cl.access_token))
+	}
+
+	if cl.user_agent != "" {
+		req.Header.Set("User-Agent", cl.user_agent)
+	}
+
+	key := idempotencyKey(req.Context())
+
+	if key != "" {
+		req.Header.Set("Idempotency-Key", key)
+	}
+
+	rsp, err := cl.http_client.Do(req)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to do request, %w", err)
+	}
+
+	defer rsp.Body.Close()
+
+	body, err := io.ReadAll(rsp.Body)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to read response body, %w", err)
+	}
+
+	if rsp.StatusCode < 200 || rsp.StatusCode > 299 {
+		return nil, newAPIError(rsp, body, req.Method, api_method)
+	}
+
+	r := &readSeekCloser{
+		Reader: bytes.NewReader(body),
+		header: rsp.Header,
+	}
+
+	return r, nil
+}
+
+// idempotencyContextKey is the type of the context key for the idempotency key of a request.
+type idempotencyContextKey struct{}
+
+// withIdempotencyKey returns a copy of 'ctx' that causes API calls made with it by an `OAuth2Client` to be sent
+// with 'key' as the value of the Idempotency-Key header.
+func withIdempotencyKey(ctx context.Context, key string) context.Context {
+	return context.WithValue(ctx, idempotencyContextKey{}, key)
+}
+
+// idempotencyKey returns the idempotency key assigned to 'ctx' by `withIdempotencyKey`, or an empty string.
+func idempotencyKey(ctx context.Context) string {
+
+	key, _ := ctx.Value(idempotencyContextKey{}).(string)
+	return key
+}
+
+func (cl *OAuth2Client) requestEndpoint(api_method string) *url.URL {
+
+	req_endpoint := *cl.api_endpoint
+	req_endpoint.Path = api_method
+
+	return &req_endpoint
+}
+
+// readSeekCloser wraps a `bytes.Reader` so that it implements the `io.ReadSeekCloser` interface. It also records
+// the headers of the response the body was read from.
+type readSeekCloser struct {
+	*bytes.Reader
+	header http.Header
+}
+
+func (r *readSeekCloser) Close() error {
+	return nil
+}
+
+// Header returns the headers of the response the body of 'r' was read from.
+func (r *readSeekCloser) Header() http.Header {
+	return r.header
+}
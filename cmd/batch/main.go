@@ -0,0 +1,22 @@
+package main
+
+import (
+	"context"
+	"log"
+
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+
+	"github.com/aaronland/go-broadcaster-mastodon/app/batch"
+)
+
+func main() {
+
+	ctx := context.Background()
+	err := batch.Run(ctx)
+
+	if err != nil {
+		log.Fatalf("Failed to run batch application, %v", err)
+	}
+}
@@ -0,0 +1,22 @@
+package main
+
+import (
+	"context"
+	"log"
+
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+
+	"github.com/aaronland/go-broadcaster-mastodon/app/feed"
+)
+
+func main() {
+
+	ctx := context.Background()
+	err := feed.Run(ctx)
+
+	if err != nil {
+		log.Fatalf("Failed to run feed application, %v", err)
+	}
+}
@@ -0,0 +1,18 @@
+package main
+
+import (
+	"context"
+	"log"
+
+	"github.com/aaronland/go-broadcaster-mastodon/app/profiles"
+)
+
+func main() {
+
+	ctx := context.Background()
+	err := profiles.Run(ctx)
+
+	if err != nil {
+		log.Fatalf("Failed to run profiles application, %v", err)
+	}
+}
@@ -0,0 +1,18 @@
+package main
+
+import (
+	"context"
+	"log"
+
+	"github.com/aaronland/go-broadcaster-mastodon/app/stats"
+)
+
+func main() {
+
+	ctx := context.Background()
+	err := stats.Run(ctx)
+
+	if err != nil {
+		log.Fatalf("Failed to run stats application, %v", err)
+	}
+}
@@ -0,0 +1,22 @@
+package main
+
+import (
+	"context"
+	"log"
+
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+
+	"github.com/aaronland/go-broadcaster-mastodon/app/subscribe"
+)
+
+func main() {
+
+	ctx := context.Background()
+	err := subscribe.Run(ctx)
+
+	if err != nil {
+		log.Fatalf("Failed to run subscribe application, %v", err)
+	}
+}
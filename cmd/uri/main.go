@@ -0,0 +1,18 @@
+package main
+
+import (
+	"context"
+	"log"
+
+	"github.com/aaronland/go-broadcaster-mastodon/app/uri"
+)
+
+func main() {
+
+	ctx := context.Background()
+	err := uri.Run(ctx)
+
+	if err != nil {
+		log.Fatalf("Failed to run uri application, %v", err)
+	}
+}
@@ -0,0 +1,18 @@
+package main
+
+import (
+	"context"
+	"log"
+
+	"github.com/aaronland/go-broadcaster-mastodon/app/watch"
+)
+
+func main() {
+
+	ctx := context.Background()
+	err := watch.Run(ctx)
+
+	if err != nil {
+		log.Fatalf("Failed to run watch application, %v", err)
+	}
+}
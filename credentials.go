@@ -0,0 +1,44 @@
+package mastodon
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"os"
+	"time"
+
+	"github.com/sfomuseum/runtimevar"
+)
+
+// resolveSecret returns the value of 'uri' which is expected to be a sfomuseum/runtimevar URI or an "env://{NAME}"
+// URI whose value is read from the environment variable {NAME}.
+func resolveSecret(ctx context.Context, uri string) (string, error) {
+
+	u, err := url.Parse(uri)
+
+	if err != nil {
+		return "", fmt.Errorf("Failed to parse URI, %w", err)
+	}
+
+	if u.Scheme == "env" {
+
+		name := u.Host
+
+		if name == "" {
+			name = u.Opaque
+		}
+
+		v, ok := os.LookupEnv(name)
+
+		if !ok || v == "" {
+			return "", fmt.Errorf("Environment variable '%s' is not set", name)
+		}
+
+		return v, nil
+	}
+
+	rt_ctx, rt_cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer rt_cancel()
+
+	return runtimevar.StringVar(rt_ctx, uri)
+}
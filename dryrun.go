@@ -0,0 +1,89 @@
+package mastodon
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// DryrunURI returns a copy of 'uri', a `mastodon://` URI, with its ?dryrun= parameter set to "true" and, if
+// 'output' is not empty, its ?dryrun_output= parameter set to 'output'. It is used to apply the -dryrun flags of
+// the tools in this package to their broadcaster URIs.
+func DryrunURI(uri string, output string) (string, error) {
+
+	u, err := url.Parse(uri)
+
+	if err != nil {
+		return "", fmt.Errorf("Failed to parse broadcaster URI, %w", RedactError(err))
+	}
+
+	q := u.Query()
+	q.Set("dryrun", "true")
+
+	if output != "" {
+		q.Set("dryrun_output", output)
+	}
+
+	u.RawQuery = q.Encode()
+	return formatURI(u, uri), nil
+}
+
+// dryrunRecord is the data written to disk for each message broadcast in dryrun mode.
+type dryrunRecord struct {
+	Created      string     `json:"created"`
+	Method       string     `json:"method"`
+	Path         string     `json:"path"`
+	Args         url.Values `json:"args"`
+	Descriptions []string   `json:"descriptions,omitempty"`
+}
+
+// writeDryrun writes the arguments that would have been used to post a status to a new JSON file in 'root'
+// and returns the path of that file.
+func writeDryrun(root string, args *url.Values, descriptions []string) (string, error) {
+
+	err := os.MkdirAll(root, 0755)
+
+	if err != nil {
+		return "", fmt.Errorf("Failed to create %s, %w", root, err)
+	}
+
+	now := time.Now()
+
+	rec := &dryrunRecord{
+		Created:      now.Format(time.RFC3339),
+		Method:       "POST",
+		Path:         "/api/v1/statuses",
+		Args:         *args,
+		Descriptions: descriptions,
+	}
+
+	fname := fmt.Sprintf("%d.json", now.UnixNano())
+	path := filepath.Join(root, fname)
+
+	wr, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_EXCL, 0644)
+
+	if err != nil {
+		return "", fmt.Errorf("Failed to open %s for writing, %w", path, err)
+	}
+
+	enc := json.NewEncoder(wr)
+	enc.SetIndent("", "  ")
+
+	err = enc.Encode(rec)
+
+	if err != nil {
+		wr.Close()
+		return "", fmt.Errorf("Failed to encode dryrun record, %w", err)
+	}
+
+	err = wr.Close()
+
+	if err != nil {
+		return "", fmt.Errorf("Failed to close %s, %w", path, err)
+	}
+
+	return path, nil
+}
@@ -0,0 +1,203 @@
+package mastodon
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/tidwall/gjson"
+)
+
+// The maximum number of accounts to request per page when listing the accounts that engaged with a status.
+const engagement_page_size = 80
+
+// Engagement contains engagement statistics for a status.
+type Engagement struct {
+	// StatusId is the ID of the status, relative to the instance it was retrieved from.
+	StatusId string `json:"status_id"`
+	// URL is the web URL of the status.
+	URL string `json:"url,omitempty"`
+	// CreatedAt is the time the status was created.
+	CreatedAt string `json:"created_at,omitempty"`
+	// Favourites is the number of times the status has been favourited.
+	Favourites int64 `json:"favourites"`
+	// Boosts is the number of times the status has been boosted.
+	Boosts int64 `json:"boosts"`
+	// Replies is the number of replies to the status.
+	Replies int64 `json:"replies"`
+	// Poll contains the results of the status's poll, if any.
+	Poll *PollResults `json:"poll,omitempty"`
+	// FavouritedBy are the accounts that favourited the status, if requested.
+	FavouritedBy []string `json:"favourited_by,omitempty"`
+	// BoostedBy are the accounts that boosted the status, if requested.
+	BoostedBy []string `json:"boosted_by,omitempty"`
+}
+
+// PollResults contains the results of a poll.
+type PollResults struct {
+	// Id is the ID of the poll.
+	Id string `json:"id"`
+	// ExpiresAt is the time the poll closes.
+	ExpiresAt string `json:"expires_at,omitempty"`
+	// Expired is true if the poll has closed.
+	Expired bool `json:"expired"`
+	// Votes is the total number of votes.
+	Votes int64 `json:"votes"`
+	// Voters is the number of accounts that have voted, for polls allowing multiple choices.
+	Voters int64 `json:"voters,omitempty"`
+	// Options are the results for each option.
+	Options []*PollOptionResults `json:"options"`
+}
+
+// PollOptionResults contains the results for one of the options of a poll.
+type PollOptionResults struct {
+	// Title is the text of the option.
+	Title string `json:"title"`
+	// Votes is the number of votes for the option. It is zero if the poll hides its totals until it closes.
+	Votes int64 `json:"votes"`
+}
+
+// GetEngagement returns the `Engagement` statistics for 'str' which may be a status ID or the URL of a status on
+// any Mastodon instance, using the account of 'b'. If 'include_accounts' is true the accounts that favourited and
+// boosted the status are also listed, paginating through all of the results.
+func (b *MastodonBroadcaster) GetEngagement(ctx context.Context, str string, include_accounts bool) (*Engagement, error) {
+
+	body, err := b.fetchStatus(ctx, str)
+
+	if err != nil {
+		return nil, err
+	}
+
+	e := &Engagement{
+		StatusId:   gjson.GetBytes(body, "id").String(),
+		URL:        gjson.GetBytes(body, "url").String(),
+		CreatedAt:  gjson.GetBytes(body, "created_at").String(),
+		Favourites: gjson.GetBytes(body, "favourites_count").Int(),
+		Boosts:     gjson.GetBytes(body, "reblogs_count").Int(),
+		Replies:    gjson.GetBytes(body, "replies_count").Int(),
+	}
+
+	poll_rsp := gjson.GetBytes(body, "poll")
+
+	if poll_rsp.IsObject() {
+
+		poll := &PollResults{
+			Id:        poll_rsp.Get("id").String(),
+			ExpiresAt: poll_rsp.Get("expires_at").String(),
+			Expired:   poll_rsp.Get("expired").Bool(),
+			Votes:     poll_rsp.Get("votes_count").Int(),
+			Voters:    poll_rsp.Get("voters_count").Int(),
+			Options:   make([]*PollOptionResults, 0),
+		}
+
+		for _, o := range poll_rsp.Get("options").Array() {
+
+			opt := &PollOptionResults{
+				Title: o.Get("title").String(),
+				Votes: o.Get("votes_count").Int(),
+			}
+
+			poll.Options = append(poll.Options, opt)
+		}
+
+		e.Poll = poll
+	}
+
+	if !include_accounts {
+		return e, nil
+	}
+
+	favourited_by, err := b.listAccounts(ctx, fmt.Sprintf("/api/v1/statuses/%s/favourited_by", e.StatusId))
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to list accounts that favourited status %s, %w", e.StatusId, withPhase(err, PhaseFetch))
+	}
+
+	boosted_by, err := b.listAccounts(ctx, fmt.Sprintf("/api/v1/statuses/%s/reblogged_by", e.StatusId))
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to list accounts that boosted status %s, %w", e.StatusId, withPhase(err, PhaseFetch))
+	}
+
+	e.FavouritedBy = favourited_by
+	e.BoostedBy = boosted_by
+
+	return e, nil
+}
+
+// listAccounts returns the Webfinger account URIs of the accounts returned by 'api_method', following the "next"
+// links in the Link header of each response until there are no more results. Only the first page is returned for
+// clients whose response headers are not available (see `executeJSONWithHeader`).
+func (b *MastodonBroadcaster) listAccounts(ctx context.Context, api_method string) ([]string, error) {
+
+	accounts := make([]string, 0)
+
+	args := &url.Values{}
+	args.Set("limit", fmt.Sprintf("%d", engagement_page_size))
+
+	for {
+
+		body, header, err := b.executeJSONWithHeader(ctx, "GET", api_method, args)
+
+		if err != nil {
+			return nil, err
+		}
+
+		page := gjson.ParseBytes(body).Array()
+
+		for _, a := range page {
+			accounts = append(accounts, a.Get("acct").String())
+		}
+
+		next := nextPage(header)
+
+		if next == nil || len(page) == 0 {
+			break
+		}
+
+		b.logger.Debug("Fetch next page of accounts", "method", api_method, "count", len(accounts))
+		args = next
+	}
+
+	return accounts, nil
+}
+
+// nextPage returns the query parameters of the "next" link in the Link header of 'header', or nil if there is none.
+func nextPage(header http.Header) *url.Values {
+
+	for _, link := range strings.Split(header.Get("Link"), ",") {
+
+		parts := strings.Split(link, ";")
+
+		if len(parts) < 2 {
+			continue
+		}
+
+		is_next := false
+
+		for _, p := range parts[1:] {
+
+			if strings.TrimSpace(p) == `rel="next"` {
+				is_next = true
+				break
+			}
+		}
+
+		if !is_next {
+			continue
+		}
+
+		u, err := url.Parse(strings.Trim(strings.TrimSpace(parts[0]), "<>"))
+
+		if err != nil {
+			return nil
+		}
+
+		q := u.Query()
+		return &q
+	}
+
+	return nil
+}
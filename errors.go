@@ -0,0 +1,268 @@
+package mastodon
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Request phases reported by `APIError`.
+const (
+	// PhaseVerifyCredentials is the phase where the broadcaster's own account is retrieved.
+	PhaseVerifyCredentials string = "verify_credentials"
+	// PhaseReply is the phase where the status being replied to is resolved.
+	PhaseReply string = "reply"
+	// PhaseQuote is the phase where the status being quoted is resolved.
+	PhaseQuote string = "quote"
+	// PhaseMentions is the phase where mentioned accounts are resolved.
+	PhaseMentions string = "mentions"
+	// PhaseUpload is the phase where media is uploaded.
+	PhaseUpload string = "upload"
+	// PhaseDescribe is the phase where alt text is assigned to uploaded media.
+	PhaseDescribe string = "describe"
+	// PhasePost is the phase where a status is posted.
+	PhasePost string = "post"
+	// PhaseThread is the phase where the replies for a status that was split in to a thread are posted.
+	PhaseThread string = "thread"
+	// PhaseDelete is the phase where a test status is deleted.
+	PhaseDelete string = "delete"
+	// PhaseBoost is the phase where an additional account boosts the status posted by the primary account.
+	PhaseBoost string = "boost"
+	// PhaseFetch is the phase where an existing status is retrieved.
+	PhaseFetch string = "fetch"
+	// PhaseNotifications is the phase where the notifications for the broadcaster's account are retrieved.
+	PhaseNotifications string = "notifications"
+	// PhasePin is the phase where a status is pinned to, or unpinned from, the broadcaster's profile.
+	PhasePin string = "pin"
+)
+
+// APIError is an error returned by the Mastodon API. It is wrapped by one of `RateLimitError`, `UnauthorizedError`,
+// `ForbiddenError`, `ValidationError`, `MediaRejectedError` or `ServerError` depending on the HTTP status of the
+// response and the API method that was called. Other failed responses are returned as a bare `APIError`.
+type APIError struct {
+	// StatusCode is the HTTP status code of the response.
+	StatusCode int
+	// Status is the HTTP status of the response.
+	Status string
+	// Message is the value of the `error` property in the response body, followed by the value of the
+	// `error_description` property if present.
+	Message string
+	// Method is the HTTP method of the request.
+	Method string
+	// Path is the API method (the path of the request URL).
+	Path string
+	// Phase is the phase of broadcasting a message the request was made during, for example "upload" or "post".
+	Phase string
+	// RateLimitReset is the time the rate limit for the API method resets, if known.
+	RateLimitReset time.Time
+	// RateLimitRemaining is the number of requests remaining in the current rate limit window, or -1 if unknown.
+	RateLimitRemaining int
+	// Body is the body of the response.
+	Body []byte
+}
+
+func (e *APIError) Error() string {
+
+	msg := fmt.Sprintf("API call %s %s failed with status '%s'", e.Method, e.Path, e.Status)
+
+	if e.Phase != "" {
+		msg = fmt.Sprintf("%s during %s", msg, e.Phase)
+	}
+
+	if e.Message != "" {
+		msg = fmt.Sprintf("%s: %s", msg, e.Message)
+	}
+
+	return msg
+}
+
+// RateLimitError is returned when a request is rejected because the rate limit for an API method, or the
+// account or IP address making the request, has been exceeded. Requests should not be retried before
+// `RateLimitReset`.
+type RateLimitError struct {
+	*APIError
+}
+
+func (e *RateLimitError) Unwrap() error {
+	return e.APIError
+}
+
+// UnauthorizedError is returned when the access token used to make a request is missing, invalid or revoked.
+type UnauthorizedError struct {
+	*APIError
+}
+
+func (e *UnauthorizedError) Unwrap() error {
+	return e.APIError
+}
+
+// ForbiddenError is returned when the access token used to make a request does not have the scope required
+// by the API method or the account is not allowed to perform the action.
+type ForbiddenError struct {
+	*APIError
+}
+
+func (e *ForbiddenError) Unwrap() error {
+	return e.APIError
+}
+
+// ValidationError is returned when a request is rejected because its parameters are invalid, for example a
+// status that exceeds the instance's character limit.
+type ValidationError struct {
+	*APIError
+}
+
+func (e *ValidationError) Unwrap() error {
+	return e.APIError
+}
+
+// MediaRejectedError is returned when a media upload is rejected, for example because the file type is not
+// supported or the file is too large.
+type MediaRejectedError struct {
+	*APIError
+}
+
+func (e *MediaRejectedError) Unwrap() error {
+	return e.APIError
+}
+
+// ServerError is returned when the Mastodon instance fails to handle a request. Requests may be retried.
+type ServerError struct {
+	*APIError
+}
+
+func (e *ServerError) Unwrap() error {
+	return e.APIError
+}
+
+// newAPIError returns a typed error for the failed response 'rsp', whose body is 'body', to the request
+// 'http_method' 'api_method'.
+func newAPIError(rsp *http.Response, body []byte, http_method string, api_method string) error {
+
+	api_err := &APIError{
+		StatusCode:         rsp.StatusCode,
+		Status:             rsp.Status,
+		Method:             http_method,
+		Path:               api_method,
+		RateLimitRemaining: -1,
+		Body:               body,
+	}
+
+	var details struct {
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}
+
+	if json.Unmarshal(body, &details) == nil {
+
+		api_err.Message = details.Error
+
+		if details.ErrorDescription != "" && details.ErrorDescription != details.Error {
+			api_err.Message = strings.TrimSpace(fmt.Sprintf("%s %s", details.Error, details.ErrorDescription))
+		}
+	}
+
+	str_remaining := rsp.Header.Get("X-RateLimit-Remaining")
+
+	if str_remaining != "" {
+
+		remaining, err := strconv.Atoi(str_remaining)
+
+		if err == nil {
+			api_err.RateLimitRemaining = remaining
+		}
+	}
+
+	str_reset := rsp.Header.Get("X-RateLimit-Reset")
+
+	if str_reset != "" {
+
+		reset, err := time.Parse(time.RFC3339Nano, str_reset)
+
+		if err == nil {
+			api_err.RateLimitReset = reset
+		}
+	}
+
+	if api_err.RateLimitReset.IsZero() && rsp.Header.Get("Retry-After") != "" {
+
+		secs, err := strconv.Atoi(rsp.Header.Get("Retry-After"))
+
+		if err == nil {
+			api_err.RateLimitReset = time.Now().Add(time.Duration(secs) * time.Second)
+		}
+	}
+
+	is_media := strings.HasPrefix(api_method, "/api/v1/media") || strings.HasPrefix(api_method, "/api/v2/media")
+
+	switch {
+	case rsp.StatusCode == http.StatusTooManyRequests:
+		return &RateLimitError{api_err}
+	case rsp.StatusCode == http.StatusUnauthorized:
+		return &UnauthorizedError{api_err}
+	case rsp.StatusCode == http.StatusForbidden:
+		return &ForbiddenError{api_err}
+	case is_media && (rsp.StatusCode == http.StatusUnprocessableEntity || rsp.StatusCode == http.StatusRequestEntityTooLarge || rsp.StatusCode == http.StatusUnsupportedMediaType):
+		return &MediaRejectedError{api_err}
+	case rsp.StatusCode == http.StatusUnprocessableEntity:
+		return &ValidationError{api_err}
+	case rsp.StatusCode >= 500:
+		return &ServerError{api_err}
+	default:
+		return api_err
+	}
+}
+
+// IsRetryable returns true if broadcasting a message that failed with 'err' might succeed if retried later. Rate
+// limit errors, server errors and network errors reaching the Mastodon API are considered retryable. API errors
+// caused by the message itself or the credentials used to post it, errors returned by content policy, mention or
+// length checks and any other error, for example an invalid message option, are not.
+func IsRetryable(err error) bool {
+
+	if err == nil || errors.Is(err, context.Canceled) {
+		return false
+	}
+
+	var rate_err *RateLimitError
+	var server_err *ServerError
+
+	if errors.As(err, &rate_err) || errors.As(err, &server_err) {
+		return true
+	}
+
+	var api_err *APIError
+
+	if errors.As(err, &api_err) {
+		return false
+	}
+
+	var url_err *url.Error
+	var net_err net.Error
+
+	switch {
+	case errors.As(err, &url_err), errors.As(err, &net_err), errors.Is(err, io.ErrUnexpectedEOF):
+		return true
+	default:
+		return false
+	}
+}
+
+// withPhase assigns 'phase' to the `APIError` wrapped by 'err', if present, and returns 'err'.
+func withPhase(err error, phase string) error {
+
+	var api_err *APIError
+
+	if errors.As(err, &api_err) && api_err.Phase == "" {
+		api_err.Phase = phase
+	}
+
+	return err
+}
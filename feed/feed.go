@@ -0,0 +1,365 @@
+// Package feed provides methods for parsing RSS and Atom feeds in to a common representation.
+package feed
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"html"
+	"io"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Feed is a common representation of an RSS or Atom feed.
+type Feed struct {
+	// Title is the title of the feed.
+	Title string
+	// Link is the URL of the website associated with the feed.
+	Link string
+	// Items are the items in the feed in the order they appear.
+	Items []*Item
+}
+
+// Item is a common representation of an RSS item or an Atom entry.
+type Item struct {
+	// Id is the unique identifier of the item. This is the RSS guid or Atom id, falling back to the item's link.
+	Id string
+	// Title is the title of the item.
+	Title string
+	// Link is the URL of the item.
+	Link string
+	// Summary is the plain text summary of the item with any HTML markup removed.
+	Summary string
+	// Published is the time the item was published, if known.
+	Published time.Time
+	// Categories are the categories, or tags, assigned to the item.
+	Categories []string
+	// Enclosures are the media files attached to the item.
+	Enclosures []*Enclosure
+}
+
+// Enclosure is a media file attached to a feed item.
+type Enclosure struct {
+	// URL is the location of the media file.
+	URL string
+	// Type is the MIME type of the media file.
+	Type string
+	// Description is the description of the media file, from a `media:description` element, if present.
+	Description string
+}
+
+// Image returns the first enclosure for 'i' with an image MIME type, or nil if there isn't one.
+func (i *Item) Image() *Enclosure {
+
+	for _, e := range i.Enclosures {
+
+		if strings.HasPrefix(e.Type, "image/") {
+			return e
+		}
+	}
+
+	return nil
+}
+
+type rssDocument struct {
+	XMLName xml.Name `xml:"rss"`
+	Channel struct {
+		Title string     `xml:"title"`
+		Link  string     `xml:"link"`
+		Items []*rssItem `xml:"item"`
+	} `xml:"channel"`
+}
+
+type rssItem struct {
+	Title       string   `xml:"title"`
+	Link        string   `xml:"link"`
+	GUID        string   `xml:"guid"`
+	Description string   `xml:"description"`
+	PubDate     string   `xml:"pubDate"`
+	Categories  []string `xml:"category"`
+	Enclosures  []struct {
+		URL  string `xml:"url,attr"`
+		Type string `xml:"type,attr"`
+	} `xml:"enclosure"`
+	MediaContent []struct {
+		URL         string `xml:"url,attr"`
+		Type        string `xml:"type,attr"`
+		Medium      string `xml:"medium,attr"`
+		Description string `xml:"http://search.yahoo.com/mrss/ description"`
+	} `xml:"http://search.yahoo.com/mrss/ content"`
+}
+
+type atomDocument struct {
+	XMLName xml.Name     `xml:"http://www.w3.org/2005/Atom feed"`
+	Title   string       `xml:"title"`
+	Links   []*atomLink  `xml:"link"`
+	Entries []*atomEntry `xml:"entry"`
+}
+
+type atomLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr"`
+	Type string `xml:"type,attr"`
+}
+
+type atomEntry struct {
+	Id         string      `xml:"id"`
+	Title      string      `xml:"title"`
+	Links      []*atomLink `xml:"link"`
+	Summary    string      `xml:"summary"`
+	Content    string      `xml:"content"`
+	Published  string      `xml:"published"`
+	Updated    string      `xml:"updated"`
+	Categories []struct {
+		Term string `xml:"term,attr"`
+	} `xml:"category"`
+}
+
+// Parse reads an RSS or Atom feed from 'r' and returns a `Feed` instance.
+func Parse(r io.Reader) (*Feed, error) {
+
+	body, err := io.ReadAll(r)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to read feed, %w", err)
+	}
+
+	root, err := rootElement(body)
+
+	if err != nil {
+		return nil, err
+	}
+
+	switch root {
+	case "rss":
+		return parseRSS(body)
+	case "feed":
+		return parseAtom(body)
+	default:
+		return nil, fmt.Errorf("Unsupported feed type '%s'", root)
+	}
+}
+
+// SortByPublished sorts 'items' from oldest to newest. Items without a publication date retain their relative order
+// and are sorted before items with a publication date.
+func SortByPublished(items []*Item) {
+
+	sort.SliceStable(items, func(i, j int) bool {
+		return items[i].Published.Before(items[j].Published)
+	})
+}
+
+func rootElement(body []byte) (string, error) {
+
+	dec := xml.NewDecoder(bytes.NewReader(body))
+
+	for {
+
+		tok, err := dec.Token()
+
+		if err != nil {
+			return "", fmt.Errorf("Failed to find root element, %w", err)
+		}
+
+		el, ok := tok.(xml.StartElement)
+
+		if ok {
+			return el.Name.Local, nil
+		}
+	}
+}
+
+func parseRSS(body []byte) (*Feed, error) {
+
+	var doc rssDocument
+
+	err := xml.Unmarshal(body, &doc)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to parse RSS feed, %w", err)
+	}
+
+	f := &Feed{
+		Title: strings.TrimSpace(doc.Channel.Title),
+		Link:  strings.TrimSpace(doc.Channel.Link),
+		Items: make([]*Item, 0),
+	}
+
+	for _, rss_i := range doc.Channel.Items {
+
+		i := &Item{
+			Id:         strings.TrimSpace(rss_i.GUID),
+			Title:      strings.TrimSpace(rss_i.Title),
+			Link:       strings.TrimSpace(rss_i.Link),
+			Summary:    StripHTML(rss_i.Description),
+			Published:  parseTime(rss_i.PubDate),
+			Categories: trimAll(rss_i.Categories),
+			Enclosures: make([]*Enclosure, 0),
+		}
+
+		for _, e := range rss_i.Enclosures {
+			i.Enclosures = append(i.Enclosures, &Enclosure{URL: e.URL, Type: e.Type})
+		}
+
+		for _, m := range rss_i.MediaContent {
+
+			t := m.Type
+
+			if t == "" && m.Medium == "image" {
+				t = "image/*"
+			}
+
+			i.Enclosures = append(i.Enclosures, &Enclosure{URL: m.URL, Type: t, Description: strings.TrimSpace(m.Description)})
+		}
+
+		if i.Id == "" {
+			i.Id = i.Link
+		}
+
+		f.Items = append(f.Items, i)
+	}
+
+	return f, nil
+}
+
+func parseAtom(body []byte) (*Feed, error) {
+
+	var doc atomDocument
+
+	err := xml.Unmarshal(body, &doc)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to parse Atom feed, %w", err)
+	}
+
+	f := &Feed{
+		Title: strings.TrimSpace(doc.Title),
+		Link:  alternateLink(doc.Links),
+		Items: make([]*Item, 0),
+	}
+
+	for _, e := range doc.Entries {
+
+		summary := e.Summary
+
+		if summary == "" {
+			summary = e.Content
+		}
+
+		published := parseTime(e.Published)
+
+		if published.IsZero() {
+			published = parseTime(e.Updated)
+		}
+
+		i := &Item{
+			Id:         strings.TrimSpace(e.Id),
+			Title:      strings.TrimSpace(e.Title),
+			Link:       alternateLink(e.Links),
+			Summary:    StripHTML(summary),
+			Published:  published,
+			Categories: make([]string, 0),
+			Enclosures: make([]*Enclosure, 0),
+		}
+
+		for _, c := range e.Categories {
+			i.Categories = append(i.Categories, strings.TrimSpace(c.Term))
+		}
+
+		for _, l := range e.Links {
+
+			if l.Rel == "enclosure" {
+				i.Enclosures = append(i.Enclosures, &Enclosure{URL: l.Href, Type: l.Type})
+			}
+		}
+
+		if i.Id == "" {
+			i.Id = i.Link
+		}
+
+		f.Items = append(f.Items, i)
+	}
+
+	return f, nil
+}
+
+func alternateLink(links []*atomLink) string {
+
+	for _, l := range links {
+
+		if l.Rel == "" || l.Rel == "alternate" {
+			return strings.TrimSpace(l.Href)
+		}
+	}
+
+	return ""
+}
+
+var time_layouts = []string{
+	time.RFC1123Z,
+	time.RFC1123,
+	time.RFC3339,
+	time.RFC3339Nano,
+	"Mon, 2 Jan 2006 15:04:05 -0700",
+	"Mon, 2 Jan 2006 15:04:05 MST",
+	"2 Jan 2006 15:04:05 -0700",
+	"2006-01-02T15:04:05Z0700",
+	"2006-01-02",
+}
+
+func parseTime(str string) time.Time {
+
+	str = strings.TrimSpace(str)
+
+	for _, layout := range time_layouts {
+
+		t, err := time.Parse(layout, str)
+
+		if err == nil {
+			return t
+		}
+	}
+
+	return time.Time{}
+}
+
+var re_tags = regexp.MustCompile(`<[^>]*>`)
+
+var re_blocks = regexp.MustCompile(`(?i)<\s*(br|/p|/div|/li|/h[1-6])\s*/?>`)
+
+var re_whitespace = regexp.MustCompile(`[ \t]+`)
+
+var re_newlines = regexp.MustCompile(`\n{3,}`)
+
+// StripHTML removes HTML markup from 'str', preserving paragraph and line breaks, and unescapes any HTML entities.
+func StripHTML(str string) string {
+
+	str = re_blocks.ReplaceAllString(str, "$0\n")
+	str = re_tags.ReplaceAllString(str, "")
+	str = html.UnescapeString(str)
+
+	lines := strings.Split(str, "\n")
+
+	for idx, ln := range lines {
+		lines[idx] = strings.TrimSpace(re_whitespace.ReplaceAllString(ln, " "))
+	}
+
+	str = strings.Join(lines, "\n")
+	str = re_newlines.ReplaceAllString(str, "\n\n")
+
+	return strings.TrimSpace(str)
+}
+
+func trimAll(values []string) []string {
+
+	trimmed := make([]string, len(values))
+
+	for idx, v := range values {
+		trimmed[idx] = strings.TrimSpace(v)
+	}
+
+	return trimmed
+}
@@ -0,0 +1,50 @@
+package mastodon
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// WriteJSONFile writes 'v', encoded as indented JSON, to 'path'. The file is written to a temporary file in the same
+// directory which then replaces 'path' so that an interrupted write never leaves a partially written file behind.
+// It is used by the tools in this package to record their state between runs.
+func WriteJSONFile(path string, v any) error {
+
+	body, err := json.MarshalIndent(v, "", "  ")
+
+	if err != nil {
+		return fmt.Errorf("Failed to encode %s, %w", path, err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), fmt.Sprintf(".%s-*", filepath.Base(path)))
+
+	if err != nil {
+		return fmt.Errorf("Failed to create temporary file, %w", err)
+	}
+
+	_, err = tmp.Write(body)
+
+	if err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return fmt.Errorf("Failed to write %s, %w", path, err)
+	}
+
+	err = tmp.Close()
+
+	if err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("Failed to close temporary file, %w", err)
+	}
+
+	err = os.Rename(tmp.Name(), path)
+
+	if err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("Failed to replace %s, %w", path, err)
+	}
+
+	return nil
+}
@@ -6,7 +6,11 @@ require (
 	github.com/aaronland/go-broadcaster v1.0.0
 	github.com/aaronland/go-mastodon-api/v2 v2.0.0
 	github.com/aaronland/go-uid v0.4.0
+	github.com/rivo/uniseg v0.4.7
 	github.com/sfomuseum/runtimevar v1.2.0
+	github.com/tidwall/gjson v1.17.3
+	gocloud.dev v0.38.0
+	gopkg.in/yaml.v2 v2.4.0
 )
 
 require (
@@ -39,13 +43,12 @@ require (
 	github.com/hashicorp/go-multierror v1.1.1 // indirect
 	github.com/jmespath/go-jmespath v0.4.0 // indirect
 	github.com/sfomuseum/go-flags v0.10.0 // indirect
-	github.com/tidwall/gjson v1.17.3 // indirect
 	github.com/tidwall/match v1.1.1 // indirect
 	github.com/tidwall/pretty v1.2.0 // indirect
 	github.com/whosonfirst/go-ioutil v1.0.2 // indirect
 	go.opencensus.io v0.24.0 // indirect
-	gocloud.dev v0.38.0 // indirect
 	golang.org/x/net v0.26.0 // indirect
+	golang.org/x/sync v0.7.0 // indirect
 	golang.org/x/sys v0.21.0 // indirect
 	golang.org/x/text v0.16.0 // indirect
 	golang.org/x/xerrors v0.0.0-20231012003039-104605ab7028 // indirect
@@ -53,5 +56,4 @@ require (
 	google.golang.org/genproto/googleapis/rpc v0.0.0-20240415180920-8c6c420018be // indirect
 	google.golang.org/grpc v1.63.2 // indirect
 	google.golang.org/protobuf v1.33.0 // indirect
-	gopkg.in/yaml.v2 v2.4.0 // indirect
 )
@@ -0,0 +1,21 @@
+package mastodon
+
+import (
+	"context"
+
+	"github.com/aaronland/go-broadcaster"
+)
+
+// Hooks are optional functions invoked while broadcasting a message. Any hook may be nil.
+type Hooks struct {
+	// BeforePost is invoked before a message is posted and may modify 'msg' and 'opts'. If it returns an error the
+	// message is not posted and that error is returned.
+	BeforePost func(ctx context.Context, msg *broadcaster.Message, opts *MessageOptions) error
+	// AfterPost is invoked after a message has been posted successfully, including in dryrun mode.
+	AfterPost func(ctx context.Context, msg *broadcaster.Message, rsp *Result)
+	// OnError is invoked when posting a message fails.
+	OnError func(ctx context.Context, msg *broadcaster.Message, err error)
+	// OnRetry is invoked before a failed Mastodon API request is retried. 'attempt' is the number of the retry,
+	// starting at 1.
+	OnRetry func(ctx context.Context, attempt int, err error)
+}
@@ -0,0 +1,326 @@
+package mastodon
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/tidwall/gjson"
+)
+
+// The default maximum number of characters in a Mastodon status.
+const default_max_characters = 500
+
+// The amount of time to wait before trying to fetch an instance's metadata again after failing to fetch it.
+const instance_retry_interval = time.Minute
+
+// Server software flavours reported by `Instance`.
+const (
+	// FlavourMastodon is the flavour of Mastodon instances, and of any server software that is not recognized.
+	FlavourMastodon string = "mastodon"
+	// FlavourGoToSocial is the flavour of GoToSocial instances.
+	FlavourGoToSocial string = "gotosocial"
+	// FlavourAkkoma is the flavour of Akkoma instances.
+	FlavourAkkoma string = "akkoma"
+	// FlavourPleroma is the flavour of Pleroma instances.
+	FlavourPleroma string = "pleroma"
+)
+
+// Status content types that may be supported by an instance, see `Instance.SupportsContentType`.
+const (
+	// ContentTypePlain is the content type of plain text statuses, which are supported by every instance.
+	ContentTypePlain string = "text/plain"
+	// ContentTypeMarkdown is the content type of Markdown statuses.
+	ContentTypeMarkdown string = "text/markdown"
+	// ContentTypeHTML is the content type of HTML statuses.
+	ContentTypeHTML string = "text/html"
+	// ContentTypeBBCode is the content type of BBCode statuses.
+	ContentTypeBBCode string = "text/bbcode"
+)
+
+// Media API methods used to upload media.
+const (
+	media_v1 string = "/api/v1/media"
+	media_v2 string = "/api/v2/media"
+)
+
+// compatProfile defines the default capabilities of a server software flavour, for use when they are not
+// reported by the instance's metadata.
+type compatProfile struct {
+	// content_types are the status content types supported by the flavour.
+	content_types []string
+	// media_endpoint is the preferred API method for uploading media.
+	media_endpoint string
+}
+
+// compat_profiles maps server software flavours to their compatibility profiles.
+var compat_profiles = map[string]*compatProfile{
+	FlavourMastodon: {
+		content_types:  []string{ContentTypePlain},
+		media_endpoint: media_v2,
+	},
+	FlavourGoToSocial: {
+		content_types:  []string{ContentTypePlain, ContentTypeMarkdown},
+		media_endpoint: media_v2,
+	},
+	FlavourAkkoma: {
+		content_types:  []string{ContentTypePlain, ContentTypeMarkdown, ContentTypeHTML, ContentTypeBBCode},
+		media_endpoint: media_v1,
+	},
+	FlavourPleroma: {
+		content_types:  []string{ContentTypePlain, ContentTypeMarkdown, ContentTypeHTML, ContentTypeBBCode},
+		media_endpoint: media_v1,
+	},
+}
+
+// Instance describes the server software, and the capabilities relevant to broadcasting, of the instance a
+// broadcaster posts to.
+type Instance struct {
+	// Domain is the domain name of the instance.
+	Domain string `json:"domain,omitempty"`
+	// Version is the version reported by the instance. Servers that implement the Mastodon API on top of other
+	// software often report a Mastodon-compatible version followed by their own, for example
+	// "2.7.2 (compatible; Akkoma 3.13.2)".
+	Version string `json:"version,omitempty"`
+	// Flavour is the server software of the instance: "mastodon", "gotosocial", "akkoma" or "pleroma".
+	Flavour string `json:"flavour"`
+	// MaxCharacters is the maximum number of characters allowed in a status.
+	MaxCharacters int `json:"max_characters"`
+	// ContentTypes are the content types, for example "text/markdown", statuses may be posted with.
+	ContentTypes []string `json:"content_types"`
+	// MediaEndpoint is the API method preferred for uploading media. If the v2 method is not implemented by the
+	// instance the v1 method is used instead.
+	MediaEndpoint string `json:"media_endpoint"`
+}
+
+// SupportsContentType returns true if statuses with the content type 'content_type' may be posted to 'i'.
+func (i *Instance) SupportsContentType(content_type string) bool {
+	return content_type == ContentTypePlain || slices.Contains(i.ContentTypes, content_type)
+}
+
+// ensureFlavour returns an error if 'flavour' is not a known server software flavour.
+func ensureFlavour(flavour string) error {
+
+	_, ok := compat_profiles[flavour]
+
+	if !ok {
+		return fmt.Errorf("Invalid flavour '%s', must be one of '%s', '%s', '%s' or '%s'", flavour, FlavourMastodon, FlavourGoToSocial, FlavourAkkoma, FlavourPleroma)
+	}
+
+	return nil
+}
+
+// ensureContentType returns an error if 'content_type' is not a known status content type.
+func ensureContentType(content_type string) error {
+
+	switch content_type {
+	case ContentTypePlain, ContentTypeMarkdown, ContentTypeHTML, ContentTypeBBCode:
+		return nil
+	default:
+		return fmt.Errorf("Invalid content type '%s'", content_type)
+	}
+}
+
+// Instance returns an `Instance` describing the instance 'b' posts to. The instance's metadata is fetched from the
+// `/api/v2/instance` endpoint or, if that is not available, the `/api/v1/instance` endpoint and cached once it has
+// been fetched successfully. The server software flavour is derived from that metadata unless it was set using the
+// ?flavour= parameter.
+func (b *MastodonBroadcaster) Instance(ctx context.Context) (*Instance, error) {
+
+	inst, err := b.loadInstance(ctx)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return inst, nil
+}
+
+// instanceOrDefault returns the `Instance` describing the instance 'b' posts to or, if it can not be determined
+// or 'b' is in dryrun mode, an `Instance` derived from the compatibility profile of the broadcaster's flavour.
+func (b *MastodonBroadcaster) instanceOrDefault(ctx context.Context) *Instance {
+
+	inst, _ := b.loadInstance(ctx)
+	return inst
+}
+
+// loadInstance returns the `Instance` describing the instance 'b' posts to, fetching its metadata if it has not been
+// fetched yet. If the metadata can not be fetched an `Instance` derived from the compatibility profile of the
+// broadcaster's flavour is returned along with the error, and fetching is not retried until `instance_retry_interval`
+// has passed so that an unavailable instance is not asked for its metadata before every status.
+func (b *MastodonBroadcaster) loadInstance(ctx context.Context) (*Instance, error) {
+
+	b.instance_mu.Lock()
+	defer b.instance_mu.Unlock()
+
+	if b.instance_loaded {
+		return b.instance, nil
+	}
+
+	flavour := b.flavour
+
+	if flavour == "" {
+		flavour = FlavourMastodon
+	}
+
+	if b.instance == nil {
+		b.instance = newInstance(flavour)
+	}
+
+	if b.dryrun {
+		b.instance_loaded = true
+		return b.instance, nil
+	}
+
+	if b.instance_err != nil && time.Now().Before(b.instance_retry) {
+		return b.instance, b.instance_err
+	}
+
+	inst, err := b.fetchInstance(ctx)
+
+	if err != nil {
+
+		b.logger.Warn("Failed to retrieve instance configuration, using defaults", "flavour", flavour, "max characters", b.instance.MaxCharacters, "error", err)
+
+		// Failures caused by the caller cancelling the request say nothing about the instance so they are retried
+		// the next time the instance is needed
+
+		if ctx.Err() == nil {
+			b.instance_err = err
+			b.instance_retry = time.Now().Add(instance_retry_interval)
+		}
+
+		return b.instance, err
+	}
+
+	b.logger.Debug("Retrieved instance configuration", "domain", inst.Domain, "version", inst.Version, "flavour", inst.Flavour, "content types", inst.ContentTypes)
+
+	b.instance = inst
+	b.instance_err = nil
+	b.instance_loaded = true
+
+	return b.instance, nil
+}
+
+// fetchInstance fetches the metadata for the instance 'b' posts to and returns a new `Instance` derived from it.
+func (b *MastodonBroadcaster) fetchInstance(ctx context.Context) (*Instance, error) {
+
+	body, err := b.executeJSON(ctx, "GET", "/api/v2/instance", &url.Values{})
+
+	if err != nil {
+
+		// Pleroma, and older versions of Akkoma and Mastodon, only implement the v1 endpoint
+
+		b.logger.Debug("Failed to retrieve v2 instance configuration, trying v1", "error", err)
+
+		body, err = b.executeJSON(ctx, "GET", "/api/v1/instance", &url.Values{})
+
+		if err != nil {
+			return nil, fmt.Errorf("Failed to retrieve instance configuration, %w", err)
+		}
+	}
+
+	r := gjson.ParseBytes(body)
+
+	flavour := b.flavour
+
+	if flavour == "" {
+		flavour = deriveFlavour(r)
+	}
+
+	inst := newInstance(flavour)
+
+	inst.Version = r.Get("version").String()
+	inst.Domain = r.Get("domain").String()
+
+	if inst.Domain == "" {
+		inst.Domain = r.Get("uri").String()
+	}
+
+	// Mastodon and GoToSocial report the maximum length of a status in the configuration for both versions
+	// of the endpoint, Pleroma and Akkoma in a top-level property of the v1 endpoint
+
+	for _, path := range []string{"configuration.statuses.max_characters", "max_toot_chars"} {
+
+		max_rsp := r.Get(path)
+
+		if max_rsp.Exists() && max_rsp.Int() > 0 {
+			inst.MaxCharacters = int(max_rsp.Int())
+			break
+		}
+	}
+
+	for _, path := range []string{"configuration.statuses.supported_mime_types", "pleroma.metadata.post_formats"} {
+
+		types_rsp := r.Get(path)
+
+		if !types_rsp.IsArray() {
+			continue
+		}
+
+		inst.ContentTypes = make([]string, 0)
+
+		for _, t := range types_rsp.Array() {
+			inst.ContentTypes = append(inst.ContentTypes, t.String())
+		}
+
+		break
+	}
+
+	return inst, nil
+}
+
+// newInstance returns a new `Instance` with the default capabilities of 'flavour'.
+func newInstance(flavour string) *Instance {
+
+	profile := compat_profiles[flavour]
+
+	inst := &Instance{
+		Flavour:       flavour,
+		MaxCharacters: default_max_characters,
+		ContentTypes:  slices.Clone(profile.content_types),
+		MediaEndpoint: profile.media_endpoint,
+	}
+
+	return inst
+}
+
+// deriveFlavour returns the server software flavour described by 'r', the response of the v1 or v2 instance
+// endpoint.
+func deriveFlavour(r gjson.Result) string {
+
+	version := strings.ToLower(r.Get("version").String())
+	source_url := strings.ToLower(r.Get("source_url").String())
+
+	// Akkoma is a fork of Pleroma and reports the same "pleroma" metadata so it is checked first
+
+	is_akkoma := strings.Contains(version, "akkoma")
+
+	for _, f := range r.Get("pleroma.metadata.features").Array() {
+
+		if strings.HasPrefix(f.String(), "akkoma:") {
+			is_akkoma = true
+			break
+		}
+	}
+
+	switch {
+	case is_akkoma:
+		return FlavourAkkoma
+	case strings.Contains(version, "pleroma") || r.Get("pleroma").Exists():
+		return FlavourPleroma
+	case strings.Contains(version, "gotosocial") || strings.Contains(source_url, "gotosocial"):
+		return FlavourGoToSocial
+	default:
+		return FlavourMastodon
+	}
+}
+
+// maxCharacters returns the maximum number of characters allowed in a status by the instance 'b' posts to. If it
+// can not be determined, or 'b' is in dryrun mode, the Mastodon default of 500 is returned.
+func (b *MastodonBroadcaster) maxCharacters(ctx context.Context) int {
+	return b.instanceOrDefault(ctx).MaxCharacters
+}
@@ -0,0 +1,80 @@
+package mastodon
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+// ledgerEntry is the subset of the properties of a line in a ledger file used to derive status IDs.
+type ledgerEntry struct {
+	// The status ID recorded by the batch tool.
+	StatusId string `json:"status_id"`
+	// The status ID recorded by the mirror tool.
+	Id string `json:"id"`
+	// The error, if any, recorded by the batch tool.
+	Error string `json:"error"`
+	// Whether the status was posted in dryrun mode.
+	Dryrun bool `json:"dryrun"`
+}
+
+// ReadLedger returns the IDs of the statuses recorded in the JSONL ledger file at 'path', in the order they were
+// recorded. Ledger files are written by the tools in this package: the -results file written by the batch tool and
+// the -ledger file written by the mirror tool. Entries for failed or dryrun broadcasts are skipped.
+func ReadLedger(path string) ([]string, error) {
+
+	r, err := os.Open(path)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to open %s, %w", path, err)
+	}
+
+	defer r.Close()
+
+	ids := make([]string, 0)
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+
+	line := 0
+
+	for scanner.Scan() {
+
+		line += 1
+
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+
+		var e ledgerEntry
+
+		err := json.Unmarshal(scanner.Bytes(), &e)
+
+		if err != nil {
+			return nil, fmt.Errorf("Failed to parse line %d of %s, %w", line, path, err)
+		}
+
+		if e.Error != "" || e.Dryrun {
+			continue
+		}
+
+		id := e.StatusId
+
+		if id == "" {
+			id = e.Id
+		}
+
+		if id != "" {
+			ids = append(ids, id)
+		}
+	}
+
+	err = scanner.Err()
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to read %s, %w", path, err)
+	}
+
+	return ids, nil
+}
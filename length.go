@@ -0,0 +1,190 @@
+package mastodon
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode"
+
+	"github.com/rivo/uniseg"
+)
+
+// The number of characters Mastodon counts each URL as, regardless of its actual length.
+const url_length = 23
+
+const (
+	// OverflowError signals that statuses which exceed the maximum length should not be posted and a `LengthError` returned.
+	OverflowError string = "error"
+	// OverflowEllipsis signals that statuses which exceed the maximum length should be truncated and end with an ellipsis.
+	OverflowEllipsis string = "ellipsis"
+	// OverflowEllipsisLink signals that statuses which exceed the maximum length should be truncated and end with an ellipsis
+	// followed by the last URL in the status.
+	OverflowEllipsisLink string = "ellipsis_link"
+	// OverflowThread signals that statuses which exceed the maximum length should be split in to a thread of replies.
+	OverflowThread string = "thread"
+)
+
+var re_word = regexp.MustCompile(`\S+`)
+
+// LengthError is returned when a status exceeds the maximum number of characters allowed by a Mastodon instance.
+type LengthError struct {
+	// Length is the number of characters in the status, including any content warning.
+	Length int
+	// Max is the maximum number of characters allowed.
+	Max int
+}
+
+func (e *LengthError) Error() string {
+	return fmt.Sprintf("Status is %d characters long, maximum is %d", e.Length, e.Max)
+}
+
+// CountCharacters returns the number of characters in 'text' as counted by Mastodon when validating the length of
+// a status. URLs are counted as 23 characters regardless of their length, mentions of remote accounts are counted
+// by their local part only (for example "@someone@example.social" is counted as "@someone") and everything else
+// is counted as user-perceived characters (grapheme clusters) so that, for example, emoji composed of multiple
+// code points are counted as a single character. Trailing punctuation after a URL, for example the full stop at the
+// end of a sentence, is not part of the URL and is counted separately. The length of a status, as enforced by
+// Mastodon, is the sum of the counts for its text and its content warning.
+func CountCharacters(text string) int {
+
+	placeholder := strings.Repeat("x", url_length)
+
+	text = re_link.ReplaceAllStringFunc(text, func(link string) string {
+		return placeholder + link[len(trimLink(link)):]
+	})
+
+	var buf strings.Builder
+	last := 0
+
+	for _, m := range re_mention.FindAllStringSubmatchIndex(text, -1) {
+
+		// m[4:6] is the domain part of the mention, if present; remove it and its leading "@"
+
+		if m[4] < 0 {
+			continue
+		}
+
+		buf.WriteString(text[last : m[4]-1])
+		last = m[5]
+	}
+
+	buf.WriteString(text[last:])
+
+	return uniseg.GraphemeClusterCount(buf.String())
+}
+
+// ensureOverflow returns an error if 'overflow' is not a valid overflow strategy.
+func ensureOverflow(overflow string) error {
+
+	switch overflow {
+	case OverflowError, OverflowEllipsis, OverflowEllipsisLink, OverflowThread:
+		return nil
+	default:
+		return fmt.Errorf("Invalid overflow strategy '%s'", overflow)
+	}
+}
+
+// truncateStatus shortens 'status' so that it, and an ellipsis, is at most 'max' characters long. If 'link' is true
+// the last URL in 'status' is moved to the end of the truncated status, after the ellipsis. A `LengthError` is
+// returned if 'max' does not leave room for any of the text of 'status' after the ellipsis (and URL).
+func truncateStatus(status string, max int, link bool) (string, error) {
+
+	length := CountCharacters(status)
+
+	if length <= max {
+		return status, nil
+	}
+
+	suffix := "…"
+
+	if link {
+
+		links := extractLinks(status)
+
+		if len(links) > 0 {
+
+			last := links[len(links)-1]
+			idx := strings.LastIndex(status, last)
+
+			status = strings.TrimSpace(status[:idx] + status[idx+len(last):])
+			suffix = "… " + last
+		}
+	}
+
+	budget := max - CountCharacters(suffix)
+
+	if budget <= 0 {
+		return "", &LengthError{
+			Length: length,
+			Max:    max,
+		}
+	}
+
+	parts := splitStatus(status, budget)
+
+	if len(parts) == 0 {
+		return strings.TrimSpace(suffix), nil
+	}
+
+	return parts[0] + suffix, nil
+}
+
+// splitStatus splits 'status' in to one or more parts, each at most 'max' characters long. Parts are split on
+// whitespace where possible; words (or URLs) that are longer than 'max' are split between characters.
+func splitStatus(status string, max int) []string {
+
+	parts := make([]string, 0)
+	remaining := strings.TrimSpace(status)
+
+	for remaining != "" {
+
+		if CountCharacters(remaining) <= max {
+			parts = append(parts, remaining)
+			break
+		}
+
+		cut := 0
+
+		for _, loc := range re_word.FindAllStringIndex(remaining, -1) {
+
+			if CountCharacters(remaining[:loc[1]]) > max {
+				break
+			}
+
+			cut = loc[1]
+		}
+
+		if cut == 0 {
+			cut = graphemeOffset(remaining, max)
+		}
+
+		parts = append(parts, strings.TrimRightFunc(remaining[:cut], unicode.IsSpace))
+		remaining = strings.TrimLeftFunc(remaining[cut:], unicode.IsSpace)
+	}
+
+	return parts
+}
+
+// graphemeOffset returns the byte offset in 'str' after its first 'max' grapheme clusters. The offset of at least
+// one grapheme cluster is always returned so that callers splitting 'str' are guaranteed to make progress.
+func graphemeOffset(str string, max int) int {
+
+	offset := 0
+	state := -1
+	count := 0
+
+	for offset < len(str) {
+
+		if count >= max && count > 0 {
+			break
+		}
+
+		cluster, _, _, new_state := uniseg.FirstGraphemeClusterInString(str[offset:], state)
+
+		offset += len(cluster)
+		state = new_state
+		count += 1
+	}
+
+	return offset
+}
@@ -0,0 +1,120 @@
+package mastodon
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestCountCharacters(t *testing.T) {
+
+	tests := []struct {
+		name   string
+		text   string
+		length int
+	}{
+		{name: "empty", text: "", length: 0},
+		{name: "ascii", text: "Hello world", length: 11},
+		{name: "accented", text: "Café", length: 4},
+		{name: "combining mark", text: "Cafe\u0301", length: 4},
+		{name: "emoji", text: "👍", length: 1},
+		{name: "skin tone", text: "👍🏽", length: 1},
+		{name: "zero width joiner", text: "👩‍👩‍👧‍👦", length: 1},
+		{name: "flag", text: "🇺🇸", length: 1},
+		{name: "url", text: "https://example.com/a/very/long/path/that/is/longer/than/twenty/three/characters", length: 23},
+		{name: "short url", text: "https://a.co", length: 23},
+		{name: "url in text", text: "See https://example.com/path", length: 27},
+		{name: "url full stop", text: "See https://example.com/path.", length: 28},
+		{name: "url parentheses", text: "(https://example.com/path)", length: 25},
+		{name: "url punctuation", text: "https://example.com/path?!", length: 25},
+		{name: "url quoted", text: `"https://example.com/path",`, length: 26},
+		{name: "urls", text: "https://example.com/a https://example.com/b", length: 47},
+		{name: "local mention", text: "@bob hello", length: 10},
+		{name: "remote mention", text: "@bob@example.social hello", length: 10},
+		{name: "email", text: "bob@example.social", length: 18},
+	}
+
+	for _, tt := range tests {
+
+		t.Run(tt.name, func(t *testing.T) {
+
+			length := CountCharacters(tt.text)
+
+			if length != tt.length {
+				t.Fatalf("Expected '%s' to be %d characters, got %d", tt.text, tt.length, length)
+			}
+		})
+	}
+}
+
+func TestTruncateStatus(t *testing.T) {
+
+	tests := []struct {
+		name     string
+		status   string
+		max      int
+		link     bool
+		expected string
+	}{
+		{name: "short", status: "Hello world", max: 11, expected: "Hello world"},
+		{name: "word boundary", status: "Hello wonderful world", max: 12, expected: "Hello…"},
+		{name: "long word", status: "Supercalifragilistic", max: 6, expected: "Super…"},
+		{name: "graphemes", status: "👍🏽👍🏽👍🏽👍🏽👍🏽👍🏽", max: 4, expected: "👍🏽👍🏽👍🏽…"},
+		{name: "url", status: "Read all about it https://example.com/path", max: 30, expected: "Read all about it…"},
+		{name: "link", status: "Read all about it https://example.com/path in the news", max: 43, link: true, expected: "Read all about it… https://example.com/path"},
+		{name: "link punctuation", status: "Read all about it at https://example.com/path.", max: 43, link: true, expected: "Read all about it… https://example.com/path"},
+	}
+
+	for _, tt := range tests {
+
+		t.Run(tt.name, func(t *testing.T) {
+
+			truncated, err := truncateStatus(tt.status, tt.max, tt.link)
+
+			if err != nil {
+				t.Fatalf("Failed to truncate status, %v", err)
+			}
+
+			if truncated != tt.expected {
+				t.Fatalf("Expected '%s', got '%s'", tt.expected, truncated)
+			}
+
+			if CountCharacters(truncated) > tt.max {
+				t.Fatalf("Expected truncated status to be at most %d characters, got %d", tt.max, CountCharacters(truncated))
+			}
+		})
+	}
+}
+
+func TestTruncateStatusBudget(t *testing.T) {
+
+	status := "Read all about it " + strings.Repeat("x", 40) + " https://example.com/path"
+
+	tests := []struct {
+		name string
+		max  int
+		link bool
+	}{
+		{name: "ellipsis", max: 1},
+		{name: "link", max: 25, link: true},
+		{name: "link only", max: 10, link: true},
+	}
+
+	for _, tt := range tests {
+
+		t.Run(tt.name, func(t *testing.T) {
+
+			_, err := truncateStatus(status, tt.max, tt.link)
+
+			var length_err *LengthError
+
+			if !errors.As(err, &length_err) {
+				t.Fatalf("Expected a length error, got %v", err)
+			}
+
+			if length_err.Max != tt.max {
+				t.Fatalf("Expected a maximum of %d characters, got %d", tt.max, length_err.Max)
+			}
+		})
+	}
+}
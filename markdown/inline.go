@@ -0,0 +1,69 @@
+package markdown
+
+import (
+	"regexp"
+	"strings"
+)
+
+var re_image = regexp.MustCompile(`!\[([^\]]*)\]\(([^)\s]+)(?:\s+"[^"]*")?\)`)
+
+var re_link = regexp.MustCompile(`\[([^\]]+)\]\(([^)\s]+)(?:\s+"[^"]*")?\)`)
+
+var re_autolink = regexp.MustCompile(`<((?:https?|mailto):[^>\s]+)>`)
+
+var re_code = regexp.MustCompile("`([^`]+)`")
+
+var re_strong = regexp.MustCompile(`(\*\*|__)([^\s*_](?:.*?[^\s*_])?)(\*\*|__)`)
+
+var re_em_star = regexp.MustCompile(`\*([^\s*](?:[^*]*[^\s*])?)\*`)
+
+// Underscores are only treated as emphasis at word boundaries so that snake_case words and URLs survive.
+var re_em_underscore = regexp.MustCompile(`(^|[\s(])_([^\s_](?:[^_]*[^\s_])?)_($|[\s).,:;!?])`)
+
+var re_strike = regexp.MustCompile(`~~([^~]+)~~`)
+
+// renderInline converts inline Markdown markup in 'ln' in to plain text.
+func renderInline(ln string) string {
+
+	if strings.HasPrefix(ln, "> ") {
+		ln = "> " + renderInline(strings.TrimPrefix(ln, "> "))
+		return ln
+	}
+
+	// Inline code is extracted first so that its contents are not treated as markup.
+
+	code := make([]string, 0)
+
+	ln = re_code.ReplaceAllStringFunc(ln, func(m string) string {
+		code = append(code, re_code.FindStringSubmatch(m)[1])
+		return "\x00"
+	})
+
+	ln = re_image.ReplaceAllString(ln, "$1")
+
+	ln = re_link.ReplaceAllStringFunc(ln, func(m string) string {
+
+		parts := re_link.FindStringSubmatch(m)
+		text := parts[1]
+		href := parts[2]
+
+		if text == href || strings.TrimPrefix(strings.TrimPrefix(href, "https://"), "http://") == text {
+			return href
+		}
+
+		return text + " (" + href + ")"
+	})
+
+	ln = re_autolink.ReplaceAllString(ln, "$1")
+
+	ln = re_strong.ReplaceAllString(ln, "$2")
+	ln = re_em_star.ReplaceAllString(ln, "$1")
+	ln = re_em_underscore.ReplaceAllString(ln, "$1$2$3")
+	ln = re_strike.ReplaceAllString(ln, "$1")
+
+	for _, c := range code {
+		ln = strings.Replace(ln, "\x00", c, 1)
+	}
+
+	return ln
+}
@@ -0,0 +1,314 @@
+// Package markdown provides methods for deriving messages to broadcast from Markdown documents
+// with optional YAML front matter.
+package markdown
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+// Document is a Markdown document whose body has been converted in to plain text suitable for posting to Mastodon.
+type Document struct {
+	// FrontMatter contains the properties defined in the document's YAML front matter, if present.
+	FrontMatter *FrontMatter
+	// Body is the plain text representation of the document's Markdown body.
+	Body string
+	// Source is the document's Markdown body, without its front matter, for instances that support Markdown statuses.
+	Source string
+}
+
+// FrontMatter defines the YAML front matter properties used to configure a message.
+type FrontMatter struct {
+	// Title is the title of the message.
+	Title string `yaml:"title"`
+	// ContentWarning is the content warning to display in front of the message.
+	ContentWarning string `yaml:"content_warning"`
+	// Visibility is the visibility of the message.
+	Visibility string `yaml:"visibility"`
+	// Language is the ISO 639 language code of the message.
+	Language string `yaml:"language"`
+	// Sensitive marks any images as sensitive.
+	Sensitive bool `yaml:"sensitive"`
+	// Schedule is an RFC3339 timestamp, or a duration relative to now, at which to publish the message.
+	Schedule string `yaml:"schedule"`
+	// Pin causes the message to be pinned to the profile of the account it is posted with.
+	Pin bool `yaml:"pin"`
+	// PinJob is the name of the job the message is pinned by.
+	PinJob string `yaml:"pin_job"`
+	// Images are the images to include with the message.
+	Images []*Image `yaml:"images"`
+}
+
+// Image defines an image to include with a message.
+type Image struct {
+	// Path is the path to the image. Relative paths are resolved relative to the document.
+	Path string `yaml:"path"`
+	// AltText is the alt text description for the image.
+	AltText string `yaml:"alt_text"`
+}
+
+// UnmarshalYAML allows images to be specified as either a path or a dictionary with "path" and "alt_text" keys.
+func (im *Image) UnmarshalYAML(unmarshal func(interface{}) error) error {
+
+	var path string
+
+	err := unmarshal(&path)
+
+	if err == nil {
+		im.Path = path
+		return nil
+	}
+
+	type image Image
+	var raw image
+
+	err = unmarshal(&raw)
+
+	if err != nil {
+		return err
+	}
+
+	*im = Image(raw)
+	return nil
+}
+
+// Parse reads a Markdown document, with optional YAML front matter, from 'r' and returns a `Document` instance.
+func Parse(r io.Reader) (*Document, error) {
+
+	body, err := io.ReadAll(r)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to read document, %w", err)
+	}
+
+	fm_body, md_body, err := splitFrontMatter(body)
+
+	if err != nil {
+		return nil, err
+	}
+
+	fm := &FrontMatter{}
+
+	if len(fm_body) > 0 {
+
+		err := yaml.UnmarshalStrict(fm_body, fm)
+
+		if err != nil {
+			return nil, fmt.Errorf("Failed to parse front matter, %w", err)
+		}
+	}
+
+	doc := &Document{
+		FrontMatter: fm,
+		Body:        ToPlainText(string(md_body)),
+		Source:      strings.TrimSpace(string(md_body)),
+	}
+
+	return doc, nil
+}
+
+// splitFrontMatter splits 'body' in to its YAML front matter, delimited by "---" lines, and the remaining Markdown.
+func splitFrontMatter(body []byte) ([]byte, []byte, error) {
+
+	body = bytes.TrimPrefix(body, []byte("\ufeff"))
+	body = bytes.ReplaceAll(body, []byte("\r\n"), []byte("\n"))
+
+	if !bytes.HasPrefix(body, []byte("---\n")) {
+		return nil, body, nil
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(body[4:]))
+	offset := 4
+
+	for scanner.Scan() {
+
+		ln := scanner.Text()
+		offset += len(ln) + 1
+
+		if ln == "---" || ln == "..." {
+
+			if offset > len(body) {
+				offset = len(body)
+			}
+
+			return body[4 : offset-len(ln)-1], body[offset:], nil
+		}
+	}
+
+	err := scanner.Err()
+
+	if err != nil {
+		return nil, nil, fmt.Errorf("Failed to read front matter, %w", err)
+	}
+
+	return nil, nil, fmt.Errorf("Unterminated front matter")
+}
+
+// ToPlainText converts the Markdown in 'md' in to plain text suitable for posting to Mastodon.
+// Headings, emphasis and code markers are removed, links are rendered as "text (url)", bullets are
+// rendered with "•" and lines within a paragraph are joined. Fenced code blocks are preserved verbatim.
+func ToPlainText(md string) string {
+
+	md = strings.ReplaceAll(md, "\r\n", "\n")
+
+	var buf strings.Builder
+
+	// Each block is separated from the previous block by a blank line except for
+	// consecutive list items which are separated by a single newline.
+
+	current := make([]string, 0)
+	fence := make([]string, 0)
+
+	in_fence := false
+	in_list := false
+
+	write := func(block string, tight bool) {
+
+		if buf.Len() > 0 {
+
+			if tight {
+				buf.WriteString("\n")
+			} else {
+				buf.WriteString("\n\n")
+			}
+		}
+
+		buf.WriteString(block)
+	}
+
+	flush := func() {
+
+		if len(current) > 0 {
+			write(strings.Join(current, " "), false)
+			current = make([]string, 0)
+		}
+	}
+
+	for _, ln := range strings.Split(md, "\n") {
+
+		trimmed := strings.TrimSpace(ln)
+
+		if strings.HasPrefix(trimmed, "```") || strings.HasPrefix(trimmed, "~~~") {
+
+			flush()
+
+			if in_fence {
+				write(strings.Join(fence, "\n"), false)
+				fence = make([]string, 0)
+			}
+
+			in_fence = !in_fence
+			in_list = false
+			continue
+		}
+
+		if in_fence {
+			fence = append(fence, strings.TrimRight(ln, " \t"))
+			continue
+		}
+
+		if trimmed == "" || isRule(trimmed) {
+			flush()
+			in_list = false
+			continue
+		}
+
+		if heading, ok := stripHeading(trimmed); ok {
+			flush()
+			write(renderInline(heading), false)
+			in_list = false
+			continue
+		}
+
+		item, is_bullet := stripBullet(trimmed)
+
+		if is_bullet || isOrderedItem(trimmed) {
+
+			flush()
+
+			if is_bullet {
+				item = "• " + renderInline(item)
+			} else {
+				item = renderInline(trimmed)
+			}
+
+			write(item, in_list)
+			in_list = true
+			continue
+		}
+
+		// Lazy continuation of list items is not supported; the line starts a new paragraph.
+		in_list = false
+		current = append(current, renderInline(trimmed))
+	}
+
+	flush()
+
+	if len(fence) > 0 {
+		write(strings.Join(fence, "\n"), false)
+	}
+
+	return strings.TrimSpace(buf.String())
+}
+
+func isRule(ln string) bool {
+
+	if len(ln) < 3 {
+		return false
+	}
+
+	for _, c := range []string{"-", "*", "_"} {
+
+		if strings.Trim(strings.ReplaceAll(ln, " ", ""), c) == "" {
+			return true
+		}
+	}
+
+	return false
+}
+
+func stripHeading(ln string) (string, bool) {
+
+	if !strings.HasPrefix(ln, "#") {
+		return "", false
+	}
+
+	level := len(ln) - len(strings.TrimLeft(ln, "#"))
+
+	if level > 6 || len(ln) == level || ln[level] != ' ' {
+		return "", false
+	}
+
+	heading := strings.TrimSpace(ln[level:])
+	heading = strings.TrimSpace(strings.TrimRight(heading, "#"))
+
+	return heading, true
+}
+
+func stripBullet(ln string) (string, bool) {
+
+	for _, prefix := range []string{"- ", "* ", "+ "} {
+
+		if strings.HasPrefix(ln, prefix) {
+			return strings.TrimSpace(ln[len(prefix):]), true
+		}
+	}
+
+	return "", false
+}
+
+func isOrderedItem(ln string) bool {
+
+	idx := 0
+
+	for idx < len(ln) && ln[idx] >= '0' && ln[idx] <= '9' {
+		idx += 1
+	}
+
+	return idx > 0 && idx+1 < len(ln) && (ln[idx] == '.' || ln[idx] == ')') && ln[idx+1] == ' '
+}
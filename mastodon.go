@@ -8,15 +8,20 @@ import (
 	_ "image"
 	"image/jpeg"
 	"log/slog"
+	"net/http"
 	"net/url"
 	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"text/template"
 	"time"
 
 	"github.com/aaronland/go-broadcaster"
+	"github.com/aaronland/go-broadcaster-mastodon/markdown"
 	"github.com/aaronland/go-mastodon-api/v2/client"
-	"github.com/aaronland/go-mastodon-api/v2/response"
 	"github.com/aaronland/go-uid"
-	"github.com/sfomuseum/runtimevar"
+	"github.com/tidwall/gjson"
 )
 
 func init() {
@@ -30,46 +35,283 @@ func init() {
 
 type MastodonBroadcaster struct {
 	broadcaster.Broadcaster
-	mastodon_client client.Client
-	testing         bool
-	dryrun          bool
-	quality         int
+	mastodon_client           client.Client
+	testing                   bool
+	testing_policy            *testingPolicy
+	pending                   sync.WaitGroup
+	closing                   chan struct{}
+	close_once                sync.Once
+	dryrun                    bool
+	dryrun_output             string
+	quality                   int
+	visibility                string
+	template                  *template.Template
+	policy                    *Policy
+	tags                      []string
+	validate_mentions         string
+	require_direct_recipients bool
+	overflow                  string
+	timeout                   time.Duration
+	retries                   int
+	retry_delay               time.Duration
+	hooks                     *Hooks
+	language                  string
+	sensitive                 bool
+	max_images                int
+	instance_mu               sync.Mutex
+	instance                  *Instance
+	instance_loaded           bool
+	instance_err              error
+	instance_retry            time.Time
+	media_fallback            atomic.Bool
+	flavour                   string
+	content_type              string
+	redactor                  *redactor
+	logger                    *slog.Logger
+	name                      string
+	accounts                  []*MastodonBroadcaster
+	amplify                   bool
+	pin                       bool
+	pin_job                   string
+	pin_state                 string
+	pin_rotate                bool
 }
 
+// NewMastodonBroadcaster returns a new `MastodonBroadcaster` configured by 'uri'. See the package documentation
+// (README.md) for the list of supported URI parameters.
 func NewMastodonBroadcaster(ctx context.Context, uri string) (broadcaster.Broadcaster, error) {
+	return newMastodonBroadcaster(ctx, uri, nil, nil)
+}
+
+// NewMastodonBroadcasterWithClient returns a new `MastodonBroadcaster` configured by 'uri' that uses 'cl' to call
+// the Mastodon API. The ?credentials= (and ?testing_credentials=) parameter is not required and any HTTP transport
+// parameters are ignored.
+func NewMastodonBroadcasterWithClient(ctx context.Context, uri string, cl client.Client) (*MastodonBroadcaster, error) {
+
+	if cl == nil {
+		return nil, fmt.Errorf("Missing client")
+	}
+
+	return newMastodonBroadcaster(ctx, uri, cl, nil)
+}
+
+// NewMastodonBroadcasterWithHTTPClient returns a new `MastodonBroadcaster` configured by 'uri' that uses 'http_client'
+// to call the Mastodon API. The ?request_timeout=, ?proxy= and ?ca_bundle= parameters are ignored.
+func NewMastodonBroadcasterWithHTTPClient(ctx context.Context, uri string, http_client *http.Client) (*MastodonBroadcaster, error) {
+
+	if http_client == nil {
+		return nil, fmt.Errorf("Missing HTTP client")
+	}
+
+	return newMastodonBroadcaster(ctx, uri, nil, http_client)
+}
+
+// newMastodonBroadcaster derives an `Options` instance from 'uri' and returns a new `MastodonBroadcaster` created
+// with those options. If 'cl' is nil the client is created from the ?credentials= (or ?testing_credentials=) parameter
+// using 'http_client', if not nil.
+func newMastodonBroadcaster(ctx context.Context, uri string, cl client.Client, http_client *http.Client) (*MastodonBroadcaster, error) {
 
 	u, err := url.Parse(uri)
 
 	if err != nil {
-		return nil, fmt.Errorf("Failed to parse URI, %w", err)
+		return nil, fmt.Errorf("Failed to parse URI, %w", RedactError(err))
 	}
 
 	q := u.Query()
 
-	creds_uri := q.Get("credentials")
+	r := newRedactor()
+
+	r.addQuery(q)
+
+	if u.Host == "profile" {
+
+		profile_q, err := profileQuery(ctx, u)
+
+		if err != nil {
+			return nil, r.redactError(fmt.Errorf("Failed to load profile, %w", err))
+		}
+
+		q = profile_q
+		r.addQuery(q)
+
+		if !q.Has("name") {
+			q.Set("name", strings.Trim(u.Path, "/"))
+		}
+	}
 
-	if creds_uri == "" {
-		return nil, fmt.Errorf("Missing ?credentials= parameter")
+	br, err := newMastodonBroadcasterWithQuery(ctx, q, cl, http_client, r)
+
+	if err != nil {
+		return nil, r.redactError(err)
 	}
 
-	rt_ctx, rt_cancel := context.WithTimeout(ctx, 5*time.Second)
-	defer rt_cancel()
+	return br, nil
+}
 
-	client_uri, err := runtimevar.StringVar(rt_ctx, creds_uri)
+// newMastodonBroadcasterWithQuery returns a new `MastodonBroadcaster` configured by the parameters in 'q'. Any
+// secret values resolved while creating the client are added to 'r' which is also used by the new broadcaster.
+func newMastodonBroadcasterWithQuery(ctx context.Context, q url.Values, cl client.Client, http_client *http.Client, r *redactor) (*MastodonBroadcaster, error) {
+
+	opts, err := optionsFromQuery(ctx, q)
 
 	if err != nil {
-		return nil, fmt.Errorf("Failed to derive URI from credentials, %w", err)
+		return nil, err
+	}
+
+	opts.HTTPClient = http_client
+
+	if cl == nil {
+
+		creds_uri := q.Get("credentials")
+
+		if opts.Testing != nil && q.Get("testing_credentials") != "" {
+			creds_uri = q.Get("testing_credentials")
+		}
+
+		switch {
+		case creds_uri != "":
+
+			c, err := newClientFromCredentials(ctx, creds_uri, opts, r)
+
+			if err != nil {
+				return nil, err
+			}
+
+			cl = c
+
+		case q.Get("host") != "" && q.Get("token") != "":
+
+			// The OAuth2 client is created by NewMastodonBroadcasterWithOptions
+
+			token, err := resolveSecret(ctx, q.Get("token"))
+
+			if err != nil {
+				return nil, fmt.Errorf("Failed to derive access token, %w", err)
+			}
+
+			opts.Host = q.Get("host")
+			opts.AccessToken = strings.TrimSpace(token)
+
+			r.add(opts.AccessToken)
+
+		default:
+			return nil, fmt.Errorf("Missing ?credentials= parameter, or ?host= and ?token= parameters")
+		}
+	}
+
+	opts.Client = cl
+
+	for idx, acct_uri := range q["account"] {
+
+		acct, err := newMastodonBroadcaster(ctx, acct_uri, nil, http_client)
+
+		if err != nil {
+			return nil, fmt.Errorf("Failed to create broadcaster for account %d, %w", idx+1, err)
+		}
+
+		opts.Accounts = append(opts.Accounts, acct)
 	}
 
-	cl, err := client.NewClient(ctx, client_uri)
+	br, err := NewMastodonBroadcasterWithOptions(ctx, opts)
 
 	if err != nil {
-		return nil, fmt.Errorf("Failed to create new Mastodon client, %w", err)
+		return nil, err
 	}
 
-	testing := false
-	dryrun := false
-	quality := 100
+	br.redactor.add(r.values...)
+	return br, nil
+}
+
+// optionsFromQuery returns a new `Options` instance derived from the parameters in 'q'. The client, and the
+// credentials it is derived from, are not included.
+func optionsFromQuery(ctx context.Context, q url.Values) (*Options, error) {
+
+	opts := &Options{
+		Visibility:       q.Get("visibility"),
+		DryrunOutput:     q.Get("dryrun_output"),
+		ValidateMentions: q.Get("validate_mentions"),
+		Overflow:         q.Get("overflow"),
+		Language:         q.Get("language"),
+		Name:             q.Get("name"),
+		PinJob:           q.Get("pin_job"),
+		PinState:         q.Get("pin_state"),
+		Flavour:          q.Get("flavour"),
+		ContentType:      q.Get("content_type"),
+	}
+
+	bool_params := map[string]*bool{
+		"dryrun":                    &opts.Dryrun,
+		"require_direct_recipients": &opts.RequireDirectRecipients,
+		"require_alt_text":          &opts.RequireAltText,
+		"sensitive":                 &opts.Sensitive,
+		"amplify":                   &opts.Amplify,
+		"pin":                       &opts.Pin,
+		"pin_rotate":                &opts.PinRotate,
+	}
+
+	for k, ptr := range bool_params {
+
+		if !q.Has(k) {
+			continue
+		}
+
+		v, err := strconv.ParseBool(q.Get(k))
+
+		if err != nil {
+			return nil, fmt.Errorf("Failed to parse ?%s= parameter, %w", k, err)
+		}
+
+		*ptr = v
+	}
+
+	int_params := map[string]*int{
+		"quality":    &opts.Quality,
+		"retries":    &opts.Retries,
+		"max_images": &opts.MaxImages,
+	}
+
+	for k, ptr := range int_params {
+
+		if !q.Has(k) {
+			continue
+		}
+
+		v, err := strconv.Atoi(q.Get(k))
+
+		if err != nil {
+			return nil, fmt.Errorf("Failed to parse ?%s= parameter, %w", k, err)
+		}
+
+		*ptr = v
+	}
+
+	duration_params := map[string]*time.Duration{
+		"timeout":     &opts.Timeout,
+		"retry_delay": &opts.RetryDelay,
+	}
+
+	for k, ptr := range duration_params {
+
+		if !q.Has(k) {
+			continue
+		}
+
+		d, err := parseTimeout(q.Get(k))
+
+		if err != nil {
+			return nil, fmt.Errorf("Failed to parse ?%s= parameter, %w", k, err)
+		}
+
+		*ptr = d
+	}
+
+	transport_opts, err := transportOptionsFromQuery(q)
+
+	if err != nil {
+		return nil, err
+	}
+
+	opts.Transport = transport_opts
 
 	if q.Has("testing") {
 
@@ -79,57 +321,707 @@ func NewMastodonBroadcaster(ctx context.Context, uri string) (broadcaster.Broadc
 			return nil, fmt.Errorf("Failed to parse ?testing= parameter, %w", err)
 		}
 
-		testing = t
+		if t {
+
+			testing_opts := &TestingOptions{
+				Prefix:     q.Get("testing_prefix"),
+				NoPrefix:   q.Has("testing_prefix") && q.Get("testing_prefix") == "",
+				Visibility: q.Get("testing_visibility"),
+			}
+
+			if q.Has("testing_delete_after") {
+
+				d, err := time.ParseDuration(q.Get("testing_delete_after"))
+
+				if err != nil {
+					return nil, fmt.Errorf("Failed to parse ?testing_delete_after= parameter, %w", err)
+				}
+
+				testing_opts.DeleteAfter = d
+			}
+
+			opts.Testing = testing_opts
+		}
 	}
 
-	if q.Has("dryrun") {
+	if q.Has("template") {
 
-		d, err := strconv.ParseBool(q.Get("dryrun"))
+		body, err := readConfig(ctx, q.Get("template"))
 
 		if err != nil {
-			return nil, fmt.Errorf("Failed to parse ?dryrun= parameter, %w", err)
+			return nil, fmt.Errorf("Invalid ?template= parameter, %w", err)
 		}
 
-		dryrun = d
+		opts.Template = body
+	}
+
+	if q.Has("tags") {
+		opts.Tags = strings.Split(q.Get("tags"), ",")
 	}
 
-	if q.Has("quality") {
+	if q.Has("policy") {
 
-		v, err := strconv.Atoi(q.Get("quality"))
+		p, err := loadPolicy(ctx, q.Get("policy"))
 
 		if err != nil {
-			return nil, fmt.Errorf("Failed to parse ?quality= parameter, %w", err)
+			return nil, fmt.Errorf("Invalid ?policy= parameter, %w", err)
 		}
 
-		quality = v
+		opts.Policy = p
+	}
+
+	return opts, nil
+}
+
+// NewMastodonBroadcasterWithOptions returns a new `MastodonBroadcaster` configured by 'opts'.
+func NewMastodonBroadcasterWithOptions(ctx context.Context, opts *Options) (*MastodonBroadcaster, error) {
+
+	if opts == nil {
+		return nil, fmt.Errorf("Missing options")
 	}
 
+	br, err := newBroadcasterFromOptions(opts)
+
+	if err != nil {
+		return nil, newRedactor(opts.AccessToken).redactError(err)
+	}
+
+	if opts.Amplify && len(opts.Accounts) == 0 {
+		return nil, fmt.Errorf("Amplification requires one or more additional accounts")
+	}
+
+	cl := opts.Client
+
+	if cl == nil {
+
+		if opts.Host == "" {
+			return nil, fmt.Errorf("Missing client or host")
+		}
+
+		client_uri := &url.URL{
+			Scheme: "oauth2",
+			User:   url.UserPassword("", opts.AccessToken),
+			Host:   opts.Host,
+		}
+
+		oauth2_cl, err := NewOAuth2Client(ctx, client_uri.String())
+
+		if err != nil {
+			return nil, br.redactor.redactError(fmt.Errorf("Failed to create new Mastodon client, %w", err))
+		}
+
+		err = configureOAuth2Client(oauth2_cl, opts)
+
+		if err != nil {
+			return nil, br.redactor.redactError(err)
+		}
+
+		cl = oauth2_cl
+	}
+
+	br.mastodon_client = cl
+	return br, nil
+}
+
+// newBroadcasterFromOptions returns a new `MastodonBroadcaster` configured by 'opts', without a client.
+func newBroadcasterFromOptions(opts *Options) (*MastodonBroadcaster, error) {
+
 	br := &MastodonBroadcaster{
-		mastodon_client: cl,
-		testing:         testing,
-		dryrun:          dryrun,
-		quality:         quality,
+		closing:                   make(chan struct{}),
+		dryrun:                    opts.Dryrun,
+		dryrun_output:             opts.DryrunOutput,
+		quality:                   100,
+		visibility:                "public",
+		policy:                    opts.Policy,
+		validate_mentions:         opts.ValidateMentions,
+		require_direct_recipients: opts.RequireDirectRecipients,
+		overflow:                  OverflowError,
+		timeout:                   opts.Timeout,
+		retries:                   opts.Retries,
+		retry_delay:               time.Second,
+		hooks:                     opts.Hooks,
+		language:                  opts.Language,
+		sensitive:                 opts.Sensitive,
+		max_images:                opts.MaxImages,
+		redactor:                  newRedactor(opts.AccessToken),
+		name:                      opts.Name,
+		accounts:                  opts.Accounts,
+		amplify:                   opts.Amplify,
+		pin:                       opts.Pin,
+		pin_job:                   opts.PinJob,
+		pin_state:                 opts.PinState,
+		pin_rotate:                opts.PinRotate,
+		flavour:                   opts.Flavour,
+		content_type:              opts.ContentType,
+	}
+
+	br.logger = newRedactingLogger(br.redactor)
+
+	for idx, acct := range br.accounts {
+
+		if acct == nil {
+			return nil, fmt.Errorf("Invalid account %d, broadcaster is nil", idx+1)
+		}
+
+		if len(acct.accounts) > 0 {
+			return nil, fmt.Errorf("Invalid account %d, additional accounts can not have additional accounts of their own", idx+1)
+		}
+
+		br.redactor.add(acct.redactor.values...)
+	}
+
+	if opts.Flavour != "" {
+
+		err := ensureFlavour(opts.Flavour)
+
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if opts.ContentType != "" {
+
+		err := ensureContentType(opts.ContentType)
+
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if opts.PinRotate && opts.PinState == "" {
+		return nil, fmt.Errorf("Pin rotation requires a pin state file")
+	}
+
+	if opts.MaxImages < 0 {
+		return nil, fmt.Errorf("Invalid maximum number of images, must not be negative")
+	}
+
+	if opts.RequireAltText {
+
+		// Missing alt text is reported as a content policy violation, with a "block" action,
+		// so that it is handled the same way as the equivalent policy rule. The rule is added
+		// to a copy of the policy so that the caller's policy is left unchanged.
+
+		policy := &Policy{}
+
+		if opts.Policy != nil {
+			p := *opts.Policy
+			policy = &p
+		}
+
+		policy.RequireAltText = &PolicyRule{Action: PolicyBlock}
+		br.policy = policy
+	}
+
+	if opts.Quality != 0 {
+
+		if opts.Quality < 1 || opts.Quality > 100 {
+			return nil, fmt.Errorf("Invalid quality, must be between 1 and 100")
+		}
+
+		br.quality = opts.Quality
+	}
+
+	if opts.Visibility != "" {
+
+		err := ensureVisibility(opts.Visibility)
+
+		if err != nil {
+			return nil, fmt.Errorf("Invalid visibility, %w", err)
+		}
+
+		br.visibility = opts.Visibility
+	}
+
+	if opts.Testing != nil {
+
+		testing_policy, err := newTestingPolicy(opts.Testing)
+
+		if err != nil {
+			return nil, err
+		}
+
+		br.testing = true
+		br.testing_policy = testing_policy
+	}
+
+	if opts.Template != "" {
+
+		t, err := parseTemplate("status", opts.Template)
+
+		if err != nil {
+			return nil, fmt.Errorf("Invalid template, %w", err)
+		}
+
+		br.template = t
+	}
+
+	if br.policy != nil {
+
+		err := br.policy.compile()
+
+		if err != nil {
+			return nil, fmt.Errorf("Invalid policy, %w", err)
+		}
+	}
+
+	if len(opts.Tags) > 0 {
+
+		tags, err := normalizeTags(opts.Tags)
+
+		if err != nil {
+			return nil, fmt.Errorf("Invalid tags, %w", err)
+		}
+
+		br.tags = tags
+	}
+
+	switch opts.ValidateMentions {
+	case "", MentionsWarn, MentionsBlock:
+		// pass
+	default:
+		return nil, fmt.Errorf("Invalid mention validation, must be '%s' or '%s'", MentionsWarn, MentionsBlock)
+	}
+
+	if opts.Overflow != "" {
+
+		err := ensureOverflow(opts.Overflow)
+
+		if err != nil {
+			return nil, err
+		}
+
+		br.overflow = opts.Overflow
+	}
+
+	if opts.Retries < 0 {
+		return nil, fmt.Errorf("Invalid retries, must not be negative")
+	}
+
+	if opts.RetryDelay > 0 {
+		br.retry_delay = opts.RetryDelay
 	}
 
 	return br, nil
 }
 
+// newClientFromCredentials returns a new `client.Client` instance for the aaronland/go-mastodon-api client URI
+// that 'creds_uri', a sfomuseum/runtimevar URI, resolves to. "oauth2://" URIs return an `OAuth2Client` configured
+// by the HTTP client and transport properties of 'opts'. The access token in the client URI is added to 'r'.
+func newClientFromCredentials(ctx context.Context, creds_uri string, opts *Options, r *redactor) (client.Client, error) {
+
+	client_uri, err := resolveSecret(ctx, creds_uri)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to derive URI from credentials, %w", err)
+	}
+
+	// To account for things that might be gocloud.dev/runtimevar-encoded in a file
+	// using editors that automatically add newlines
+
+	client_uri = strings.TrimSpace(client_uri)
+
+	r.addURI(client_uri)
+
+	if !strings.HasPrefix(client_uri, "oauth2://") {
+
+		if opts.HTTPClient != nil || (opts.Transport != nil && *opts.Transport != (TransportOptions{})) {
+			logger := newRedactingLogger(r)
+			logger.Warn("HTTP transport options are only supported for oauth2:// client URIs and will be ignored")
+		}
+
+		cl, err := client.NewClient(ctx, client_uri)
+
+		if err != nil {
+			return nil, fmt.Errorf("Failed to create new Mastodon client, %w", err)
+		}
+
+		return cl, nil
+	}
+
+	cl, err := NewOAuth2Client(ctx, client_uri)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to create new Mastodon client, %w", err)
+	}
+
+	err = configureOAuth2Client(cl, opts)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return cl, nil
+}
+
+// configureOAuth2Client assigns the HTTP client and User-Agent defined by 'opts' to 'cl'.
+func configureOAuth2Client(cl *OAuth2Client, opts *Options) error {
+
+	transport_opts := opts.Transport
+
+	if transport_opts == nil {
+		transport_opts = &TransportOptions{}
+	}
+
+	http_client := opts.HTTPClient
+
+	if http_client == nil {
+
+		c, err := NewHTTPClient(transport_opts)
+
+		if err != nil {
+			return fmt.Errorf("Failed to create HTTP client, %w", err)
+		}
+
+		http_client = c
+	}
+
+	cl.SetHTTPClient(http_client)
+	cl.SetUserAgent(transport_opts.UserAgent)
+
+	return nil
+}
+
+// BroadcastMessage posts 'msg' to Mastodon using the default options for 'b'.
 func (b *MastodonBroadcaster) BroadcastMessage(ctx context.Context, msg *broadcaster.Message) (uid.UID, error) {
+	return b.BroadcastMessageWithOptions(ctx, msg, nil)
+}
+
+// BroadcastMessageWithOptions posts 'msg' to Mastodon using the per-message options defined in 'opts'
+// which may be nil.
+func (b *MastodonBroadcaster) BroadcastMessageWithOptions(ctx context.Context, msg *broadcaster.Message, opts *MessageOptions) (uid.UID, error) {
+
+	rsp, err := b.PostMessage(ctx, msg, opts)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return uid.NewStringUID(ctx, rsp.Id)
+}
+
+// PostMessage posts 'msg' to Mastodon using the per-message options defined in 'opts', which may be nil,
+// and returns a `Result` instance describing the status that was posted.
+func (b *MastodonBroadcaster) PostMessage(ctx context.Context, msg *broadcaster.Message, opts *MessageOptions) (*Result, error) {
+
+	if opts == nil {
+		opts = &MessageOptions{}
+	}
+
+	if b.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, b.timeout)
+		defer cancel()
+	}
+
+	if b.hooks != nil && b.hooks.BeforePost != nil {
+
+		err := b.hooks.BeforePost(ctx, msg, opts)
+
+		if err != nil {
+			return nil, b.redactor.redactError(err)
+		}
+	}
+
+	rsp, err := b.postMessage(ctx, msg, opts)
+
+	if err != nil {
+		err = b.redactor.redactError(err)
+	}
+
+	if len(b.accounts) > 0 {
+		rsp, err = b.postAccounts(ctx, msg, opts, rsp, err)
+	}
+
+	if err != nil {
+
+		if b.hooks != nil && b.hooks.OnError != nil {
+			b.hooks.OnError(ctx, msg, err)
+		}
+
+		return nil, err
+	}
+
+	if b.hooks != nil && b.hooks.AfterPost != nil {
+		b.hooks.AfterPost(ctx, msg, rsp)
+	}
+
+	return rsp, nil
+}
+
+func (b *MastodonBroadcaster) postMessage(ctx context.Context, msg *broadcaster.Message, opts *MessageOptions) (*Result, error) {
+
+	if opts.Poll != nil && len(msg.Images) > 0 {
+		return nil, fmt.Errorf("Polls can not be combined with images")
+	}
+
+	if b.max_images > 0 && len(msg.Images) > b.max_images {
+		return nil, fmt.Errorf("Too many images (%d), maximum is %d", len(msg.Images), b.max_images)
+	}
+
+	if len(opts.Descriptions) > len(msg.Images) {
+		return nil, fmt.Errorf("More image descriptions (%d) than images (%d)", len(opts.Descriptions), len(msg.Images))
+	}
+
+	text := msg.Body
+
+	content_type := b.content_type
 
-	status := msg.Body
+	if opts.ContentType != "" {
+
+		err := ensureContentType(opts.ContentType)
+
+		if err != nil {
+			return nil, err
+		}
+
+		content_type = opts.ContentType
+	}
+
+	if content_type != "" {
+
+		inst := b.instanceOrDefault(ctx)
+
+		switch {
+		case inst.SupportsContentType(content_type):
+			// pass
+		case content_type == ContentTypeMarkdown:
+			b.logger.Debug("Instance does not support Markdown, converting to plain text", "flavour", inst.Flavour)
+			text = markdown.ToPlainText(text)
+			content_type = ""
+		default:
+			return nil, fmt.Errorf("Content type '%s' is not supported by the instance (%s)", content_type, inst.Flavour)
+		}
+	}
+
+	status := text
+
+	if b.template != nil {
+
+		vars := &TemplateVars{
+			Title:         msg.Title,
+			Body:          text,
+			Data:          opts.Data,
+			MaxCharacters: b.maxCharacters(ctx),
+		}
+
+		if vars.Data == nil {
+			vars.Data = make(map[string]any)
+		}
+
+		rendered, err := renderTemplate(b.template, vars)
+
+		if err != nil {
+			return nil, err
+		}
+
+		status = rendered
+	}
 
 	if b.testing {
-		status = fmt.Sprintf("this is a test and there may be more / please disregard and apologies for the distraction / meanwhile: %s", status)
+
+		s, err := b.testing_policy.applyPrefix(msg, opts, status)
+
+		if err != nil {
+			return nil, err
+		}
+
+		status = s
+	}
+
+	visibility := b.visibility
+
+	if opts.Visibility != "" {
+
+		err := ensureVisibility(opts.Visibility)
+
+		if err != nil {
+			return nil, err
+		}
+
+		visibility = opts.Visibility
 	}
 
 	args := &url.Values{}
 
+	if opts.InReplyTo != "" {
+
+		if b.dryrun {
+			args.Set("in_reply_to_id", opts.InReplyTo)
+		} else {
+
+			rc, err := b.deriveReplyContext(ctx, opts.InReplyTo)
+
+			if err != nil {
+				return nil, fmt.Errorf("Failed to derive reply context for %s, %w", opts.InReplyTo, withPhase(err, PhaseReply))
+			}
+
+			args.Set("in_reply_to_id", rc.Id)
+
+			visibility = replyVisibility(visibility, rc.Visibility)
+
+			// Mentions notify the people being mentioned so they are not added to test posts.
+			if !b.testing {
+				status = prependMentions(status, rc.Mentions)
+			}
+		}
+	}
+
+	if opts.Quote != "" {
+
+		if b.dryrun {
+			args.Set("quoted_status_id", opts.Quote)
+		} else {
+
+			quote_id, err := b.resolveStatusId(ctx, opts.Quote)
+
+			if err != nil {
+				return nil, fmt.Errorf("Failed to resolve quoted status %s, %w", opts.Quote, withPhase(err, PhaseQuote))
+			}
+
+			args.Set("quoted_status_id", quote_id)
+		}
+	}
+
+	if b.testing && b.testing_policy.visibility != "" {
+		visibility = b.testing_policy.visibility
+	}
+
+	if len(b.tags) > 0 {
+
+		// Mastodon counts the content warning towards the maximum length of a status, see below.
+		tags_max := b.maxCharacters(ctx) - CountCharacters(opts.SpoilerText)
+
+		status = appendTags(status, b.tags, tags_max, b.logger)
+	}
+
 	args.Set("status", status)
-	args.Set("visibility", "public")
+	args.Set("visibility", visibility)
+
+	if content_type != "" {
+		args.Set("content_type", content_type)
+	}
+
+	if opts.SpoilerText != "" {
+		args.Set("spoiler_text", opts.SpoilerText)
+	}
+
+	if opts.Sensitive || (b.sensitive && len(msg.Images) > 0) {
+		args.Set("sensitive", "true")
+	}
+
+	if opts.Language != "" {
+		args.Set("language", opts.Language)
+	} else if b.language != "" {
+		args.Set("language", b.language)
+	}
+
+	if !opts.ScheduledAt.IsZero() {
+		args.Set("scheduled_at", opts.ScheduledAt.UTC().Format(time.RFC3339))
+	}
+
+	if opts.Poll != nil {
+
+		if len(opts.Poll.Options) < 2 {
+			return nil, fmt.Errorf("Polls must have at least two options")
+		}
+
+		for _, o := range opts.Poll.Options {
+			args.Add("poll[options][]", o)
+		}
+
+		args.Set("poll[expires_in]", strconv.Itoa(int(opts.Poll.ExpiresIn.Seconds())))
+		args.Set("poll[multiple]", strconv.FormatBool(opts.Poll.Multiple))
+		args.Set("poll[hide_totals]", strconv.FormatBool(opts.Poll.HideTotals))
+	}
+
+	policy_check := &policyCheck{
+		Status:       status,
+		SpoilerText:  opts.SpoilerText,
+		Images:       len(msg.Images),
+		Descriptions: opts.Descriptions,
+	}
+
+	warnings, err := b.enforcePolicy(policy_check)
+
+	if err != nil {
+		return nil, err
+	}
+
+	unresolved, err := b.checkMentions(ctx, status, visibility)
+
+	if err != nil {
+		return nil, withPhase(err, PhaseMentions)
+	}
+
+	overflow := b.overflow
+
+	if opts.Overflow != "" {
+
+		err := ensureOverflow(opts.Overflow)
+
+		if err != nil {
+			return nil, err
+		}
+
+		overflow = opts.Overflow
+	}
+
+	// Mastodon counts the content warning towards the maximum length of a status.
+	max_characters := b.maxCharacters(ctx)
+	available := max_characters - CountCharacters(opts.SpoilerText)
+
+	thread := make([]string, 0)
+
+	if CountCharacters(status) > available {
+
+		length_err := &LengthError{
+			Length: CountCharacters(status) + CountCharacters(opts.SpoilerText),
+			Max:    max_characters,
+		}
+
+		// If the content warning alone uses up the maximum length the status can not be truncated or split
+
+		if available <= 0 {
+			return nil, length_err
+		}
+
+		switch overflow {
+		case OverflowEllipsis, OverflowEllipsisLink:
+
+			// truncateStatus fails if the content warning leaves no room for the ellipsis, and link, and some text;
+			// report the length of the whole status, including the content warning, as the other strategies do
+
+			truncated, err := truncateStatus(status, available, overflow == OverflowEllipsisLink)
+
+			if err != nil {
+				return nil, length_err
+			}
+
+			status = truncated
+
+		case OverflowThread:
+
+			if !opts.ScheduledAt.IsZero() {
+				return nil, fmt.Errorf("Statuses that exceed the maximum length can not be split in to threads when scheduled")
+			}
+
+			parts := splitStatus(status, available)
+			status = parts[0]
+			thread = parts[1:]
+
+		default:
+			return nil, length_err
+		}
+
+		args.Set("status", status)
+	}
+
+	media_ids := make([]string, 0)
 
 	if len(msg.Images) > 0 {
 
-		for _, im := range msg.Images {
+		for idx, im := range msg.Images {
 
 			// but what if GIF...
 
@@ -152,53 +1044,186 @@ func (b *MastodonBroadcaster) BroadcastMessage(ctx context.Context, msg *broadca
 
 			wr.Flush()
 
+			description := ""
+
+			if idx < len(opts.Descriptions) {
+				description = opts.Descriptions[idx]
+			}
+
 			if b.dryrun {
+				media_ids = append(media_ids, "dryrun")
 				args.Add("media_ids[]", "dryrun")
-			} else {
+				continue
+			}
 
-				br := bytes.NewReader(buf.Bytes())
+			br := bytes.NewReader(buf.Bytes())
 
-				slog.Debug("Upload media for post")
-				rsp, err := b.mastodon_client.UploadMedia(ctx, br, nil)
+			b.logger.Debug("Upload media for post")
+
+			media_id, err := b.uploadMedia(ctx, br)
+
+			if err != nil {
+				return nil, fmt.Errorf("Failed to upload image, %w", withPhase(err, PhaseUpload))
+			}
+
+			b.logger.Debug("Successfully uploaded media", "id", media_id)
+
+			if description != "" {
+
+				// The media upload endpoint in aaronland/go-mastodon-api ignores
+				// any arguments so alt text is assigned after the fact.
+
+				desc_args := &url.Values{}
+				desc_args.Set("description", description)
+
+				_, err := b.executeJSON(ctx, "PUT", fmt.Sprintf("/api/v1/media/%s", media_id), desc_args)
 
 				if err != nil {
-					return nil, fmt.Errorf("Failed to upload image, %w", err)
+					return nil, fmt.Errorf("Failed to assign description to media %s, %w", media_id, withPhase(err, PhaseDescribe))
 				}
+			}
+
+			media_ids = append(media_ids, media_id)
+			args.Add("media_ids[]", media_id)
+		}
+	}
+
+	if b.dryrun {
+
+		b.logger.Info("Dryrun", "args", args)
 
-				media_id, err := response.Id(ctx, rsp)
+		rsp := &Result{
+			Id:                 "1",
+			Visibility:         visibility,
+			InReplyToId:        args.Get("in_reply_to_id"),
+			ScheduledAt:        args.Get("scheduled_at"),
+			MediaIds:           media_ids,
+			Dryrun:             true,
+			Warnings:           warnings,
+			UnresolvedMentions: unresolved,
+		}
+
+		if b.dryrun_output != "" {
+
+			path, err := writeDryrun(b.dryrun_output, args, opts.Descriptions)
+
+			if err != nil {
+				return nil, fmt.Errorf("Failed to write dryrun output, %w", err)
+			}
+
+			rsp.DryrunPath = path
+		}
+
+		for _, part := range thread {
+
+			part_args := threadArgs(args, part, "dryrun")
+			b.logger.Info("Dryrun", "args", part_args)
+
+			if b.dryrun_output != "" {
+
+				_, err := writeDryrun(b.dryrun_output, part_args, nil)
 
 				if err != nil {
-					return nil, fmt.Errorf("Failed to derive media ID from response, %w", err)
+					return nil, fmt.Errorf("Failed to write dryrun output, %w", err)
 				}
-
-				slog.Debug("Successfully uploaded media", "id", media_id)
-				args.Add("media_ids[]", media_id)
 			}
+
+			rsp.Thread = append(rsp.Thread, "dryrun")
 		}
+
+		b.pinStatus(ctx, rsp, opts)
+		return rsp, nil
 	}
 
-	var status_id string
+	post_ctx := ctx
 
-	if b.dryrun {
-		slog.Info("Dryrun", "args", args)
-		status_id = "1"
-	} else {
+	if opts.IdempotencyKey != "" {
+		post_ctx = withIdempotencyKey(ctx, opts.IdempotencyKey)
+	}
 
-		rsp, err := b.mastodon_client.ExecuteMethod(ctx, "POST", "/api/v1/statuses", args)
+	body, err := b.executeJSON(post_ctx, "POST", "/api/v1/statuses", args)
 
-		if err != nil {
-			return nil, fmt.Errorf("Failed to post message, %w", err)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to post message, %w", withPhase(err, PhasePost))
+	}
+
+	id_rsp := gjson.GetBytes(body, "id")
+
+	if !id_rsp.Exists() {
+		return nil, fmt.Errorf("Failed to derive status ID from response, missing 'id' property")
+	}
+
+	rsp := &Result{
+		Id:                 id_rsp.String(),
+		URL:                gjson.GetBytes(body, "url").String(),
+		URI:                gjson.GetBytes(body, "uri").String(),
+		CreatedAt:          gjson.GetBytes(body, "created_at").String(),
+		ScheduledAt:        gjson.GetBytes(body, "scheduled_at").String(),
+		Visibility:         visibility,
+		InReplyToId:        args.Get("in_reply_to_id"),
+		MediaIds:           media_ids,
+		Warnings:           warnings,
+		UnresolvedMentions: unresolved,
+	}
+
+	b.logger.Info("Mastodon post successful", "status ID", rsp.Id)
+
+	if b.testing && b.testing_policy.delete_after > 0 {
+		b.scheduleDelete(rsp)
+	}
+
+	in_reply_to := rsp.Id
+
+	for idx, part := range thread {
+
+		part_args := threadArgs(args, part, in_reply_to)
+
+		part_ctx := ctx
+
+		if opts.IdempotencyKey != "" {
+			part_ctx = withIdempotencyKey(ctx, fmt.Sprintf("%s-%d", opts.IdempotencyKey, idx+2))
 		}
 
-		id, err := response.Id(ctx, rsp)
+		body, err := b.executeJSON(part_ctx, "POST", "/api/v1/statuses", part_args)
 
 		if err != nil {
-			return nil, fmt.Errorf("Failed to derive status ID from response, %w", err)
+			return nil, fmt.Errorf("Failed to post part %d of thread for status %s, %w", idx+2, rsp.Id, withPhase(err, PhaseThread))
+		}
+
+		part_id := gjson.GetBytes(body, "id").String()
+
+		if part_id == "" {
+			return nil, fmt.Errorf("Failed to derive status ID for part %d of thread for status %s, missing 'id' property", idx+2, rsp.Id)
 		}
 
-		status_id = id
+		b.logger.Info("Mastodon thread post successful", "status ID", part_id, "in reply to", in_reply_to)
+
+		if b.testing && b.testing_policy.delete_after > 0 {
+			b.scheduleDelete(&Result{Id: part_id})
+		}
+
+		rsp.Thread = append(rsp.Thread, part_id)
+		in_reply_to = part_id
+	}
+
+	b.pinStatus(ctx, rsp, opts)
+	return rsp, nil
+}
+
+// threadArgs returns the arguments for posting 'status' as a reply to 'in_reply_to', as part of a thread, using the
+// visibility, content warning, language and content type defined in 'args'.
+func threadArgs(args *url.Values, status string, in_reply_to string) *url.Values {
+
+	part_args := &url.Values{}
+	part_args.Set("status", status)
+	part_args.Set("in_reply_to_id", in_reply_to)
+
+	for _, k := range []string{"visibility", "spoiler_text", "language", "content_type"} {
+
+		if args.Has(k) {
+			part_args.Set(k, args.Get(k))
+		}
 	}
 
-	slog.Info("Mastodon post successful", "status ID", status_id)
-	return uid.NewStringUID(ctx, status_id)
+	return part_args
 }
@@ -0,0 +1,153 @@
+package mastodon
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/tidwall/gjson"
+)
+
+// The interval between checking whether media uploaded asynchronously has been processed.
+const media_processing_interval = time.Second
+
+// The maximum number of times to check whether media uploaded asynchronously has been processed.
+const media_processing_checks = 60
+
+// mediaEndpoint returns the API method used to upload media to the instance 'b' posts to.
+func (b *MastodonBroadcaster) mediaEndpoint(ctx context.Context) string {
+
+	if b.media_fallback.Load() {
+		return media_v1
+	}
+
+	return b.instanceOrDefault(ctx).MediaEndpoint
+}
+
+// uploadMedia uploads the contents of 'r' and returns the ID of the media, waiting for it to be processed if the
+// instance processes it asynchronously. The media API method is chosen by the compatibility profile of the instance
+// 'b' posts to, falling back to the v1 method if the v2 method is not implemented. Clients other than `OAuth2Client`
+// always use their own `UploadMedia` method. Uploads are not idempotent, since each one creates a new media
+// attachment, so they are only retried if they never reached the instance or it could not handle them.
+func (b *MastodonBroadcaster) uploadMedia(ctx context.Context, r io.ReadSeeker) (string, error) {
+
+	var rsp io.ReadSeekCloser
+
+	err := b.withRetries(ctx, false, func() error {
+
+		_, err := r.Seek(0, io.SeekStart)
+
+		if err != nil {
+			return err
+		}
+
+		cl, ok := b.mastodon_client.(*OAuth2Client)
+
+		if !ok {
+			rsp, err = b.mastodon_client.UploadMedia(ctx, r, nil)
+			return err
+		}
+
+		api_method := b.mediaEndpoint(ctx)
+
+		rsp, err = cl.uploadMedia(ctx, api_method, r, nil)
+
+		if err == nil || api_method != media_v2 || !isNotImplemented(err) {
+			return err
+		}
+
+		b.logger.Debug("v2 media endpoint is not implemented, falling back to v1", "error", err)
+		b.media_fallback.Store(true)
+
+		_, err = r.Seek(0, io.SeekStart)
+
+		if err != nil {
+			return err
+		}
+
+		rsp, err = cl.uploadMedia(ctx, media_v1, r, nil)
+		return err
+	})
+
+	if err != nil {
+		return "", err
+	}
+
+	defer rsp.Close()
+
+	body, err := io.ReadAll(rsp)
+
+	if err != nil {
+		return "", fmt.Errorf("Failed to read response, %w", err)
+	}
+
+	media_id := gjson.GetBytes(body, "id").String()
+
+	if media_id == "" {
+		return "", fmt.Errorf("Failed to derive media ID from response, missing 'id' property")
+	}
+
+	// The v2 endpoint returns media that is still being processed without a URL
+
+	url_rsp := gjson.GetBytes(body, "url")
+
+	if url_rsp.Exists() && url_rsp.Type == gjson.Null {
+
+		err := b.waitForMedia(ctx, media_id)
+
+		if err != nil {
+			return "", err
+		}
+	}
+
+	return media_id, nil
+}
+
+// waitForMedia waits for the media 'media_id', uploaded asynchronously, to be processed.
+func (b *MastodonBroadcaster) waitForMedia(ctx context.Context, media_id string) error {
+
+	for i := 0; i < media_processing_checks; i++ {
+
+		b.logger.Debug("Wait for media to be processed", "id", media_id)
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(media_processing_interval):
+			// pass
+		}
+
+		body, err := b.executeJSON(ctx, "GET", fmt.Sprintf("/api/v1/media/%s", media_id), &url.Values{})
+
+		if err != nil {
+			return fmt.Errorf("Failed to retrieve media %s, %w", media_id, err)
+		}
+
+		if gjson.GetBytes(body, "url").String() != "" {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("Media %s was not processed after %v", media_id, media_processing_interval*media_processing_checks)
+}
+
+// isNotImplemented returns true if 'err' reports that an API method is not implemented by the instance.
+func isNotImplemented(err error) bool {
+
+	var api_err *APIError
+
+	if !errors.As(err, &api_err) {
+		return false
+	}
+
+	switch api_err.StatusCode {
+	case http.StatusNotFound, http.StatusMethodNotAllowed, http.StatusNotImplemented:
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,176 @@
+package mastodon
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/tidwall/gjson"
+)
+
+const (
+	// MentionsWarn signals that mentions which can not be resolved should be logged (and reported in the `Result`) but the status still posted.
+	MentionsWarn string = "warn"
+	// MentionsBlock signals that mentions which can not be resolved should prevent a status from being posted.
+	MentionsBlock string = "block"
+)
+
+// MentionError is returned when a status mentions accounts that can not be resolved.
+type MentionError struct {
+	// Unresolved are the mentions, including the leading "@", that could not be resolved.
+	Unresolved []string
+}
+
+func (e *MentionError) Error() string {
+	return fmt.Sprintf("Failed to resolve mentioned accounts: %s", strings.Join(e.Unresolved, ", "))
+}
+
+// checkMentions resolves every account mentioned in 'status' and returns the list of mentions that could not be
+// resolved. If 'b' is configured to block unresolved mentions, or 'visibility' is "direct" and 'b' is configured
+// to require that all the recipients of direct statuses resolve, a `MentionError` is returned instead.
+func (b *MastodonBroadcaster) checkMentions(ctx context.Context, status string, visibility string) ([]string, error) {
+
+	action := b.validate_mentions
+
+	if visibility == "direct" && b.require_direct_recipients {
+		action = MentionsBlock
+	}
+
+	if action == "" {
+		return nil, nil
+	}
+
+	mentions := extractMentions(status)
+
+	if len(mentions) == 0 {
+
+		if visibility == "direct" && b.require_direct_recipients {
+			return nil, fmt.Errorf("Direct status does not mention any recipients")
+		}
+
+		return nil, nil
+	}
+
+	if b.dryrun {
+		b.logger.Debug("Skip resolving mentions in dryrun mode", "count", len(mentions))
+		return nil, nil
+	}
+
+	unresolved := make([]string, 0)
+	seen := make(map[string]bool)
+
+	for _, m := range mentions {
+
+		k := strings.ToLower(m.Acct())
+
+		if seen[k] {
+			continue
+		}
+
+		seen[k] = true
+
+		ok, err := b.resolveMention(ctx, m)
+
+		if err != nil {
+			return nil, fmt.Errorf("Failed to resolve mention %s, %w", m.Text, err)
+		}
+
+		if !ok {
+			unresolved = append(unresolved, m.Text)
+		}
+	}
+
+	if len(unresolved) == 0 {
+		return nil, nil
+	}
+
+	if action == MentionsBlock {
+		return nil, &MentionError{Unresolved: unresolved}
+	}
+
+	b.logger.Warn("Status mentions accounts that can not be resolved", "mentions", unresolved)
+	return unresolved, nil
+}
+
+// resolveMention returns true if 'm' can be resolved to an account. Accounts are first looked up using the
+// `/api/v1/accounts/lookup` endpoint, which only knows about accounts the local instance has already seen,
+// and then using the `/api/v2/search` endpoint with `resolve=true` so that remote accounts are fetched by the
+// local instance via Webfinger.
+func (b *MastodonBroadcaster) resolveMention(ctx context.Context, m *mention) (bool, error) {
+
+	acct := m.Acct()
+
+	lookup_args := &url.Values{}
+	lookup_args.Set("acct", acct)
+
+	body, err := b.executeJSON(ctx, "GET", "/api/v1/accounts/lookup", lookup_args)
+
+	if err == nil && gjson.GetBytes(body, "id").Exists() {
+		b.logger.Debug("Resolved mention", "mention", m.Text, "id", gjson.GetBytes(body, "id").String())
+		return true, nil
+	}
+
+	// The lookup endpoint returns a 404 error for unknown accounts in which case we fall back to search.
+	// Clients other than `OAuth2Client` do not return typed errors so any untyped error is treated as
+	// "not found" too.
+
+	var api_err *APIError
+
+	if err != nil && errors.As(err, &api_err) && api_err.StatusCode != http.StatusNotFound {
+		return false, fmt.Errorf("Failed to look up account, %w", err)
+	}
+
+	search_args := &url.Values{}
+	search_args.Set("q", "@"+acct)
+	search_args.Set("type", "accounts")
+	search_args.Set("resolve", "true")
+	search_args.Set("limit", "5")
+
+	body, err = b.executeJSON(ctx, "GET", "/api/v2/search", search_args)
+
+	if err != nil {
+		return false, fmt.Errorf("Failed to search for account, %w", err)
+	}
+
+	local_domain := b.instanceOrDefault(ctx).Domain
+
+	for _, a := range gjson.GetBytes(body, "accounts").Array() {
+
+		if matchesAcct(m, a.Get("acct").String(), local_domain) {
+			b.logger.Debug("Resolved mention", "mention", m.Text, "id", a.Get("id").String())
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// matchesAcct returns true if 'acct', as returned by the Mastodon API, refers to the same account as 'm'.
+// Accounts on the local instance, whose domain is 'local_domain', are returned without a domain so if 'm' has
+// a domain and 'acct' does not they only match if the domain of 'm' is 'local_domain'. Otherwise a mention of
+// "@bob@typo.example" would be resolved by a local account named "bob".
+func matchesAcct(m *mention, acct string, local_domain string) bool {
+
+	username, domain, _ := strings.Cut(acct, "@")
+
+	if !strings.EqualFold(username, m.Username) {
+		return false
+	}
+
+	if m.Domain == "" {
+		return domain == ""
+	}
+
+	if domain == "" {
+
+		// Older instances report their URI, rather than their domain, as a URL
+		local_domain = strings.TrimSuffix(strings.TrimPrefix(strings.TrimPrefix(local_domain, "https://"), "http://"), "/")
+
+		return local_domain != "" && strings.EqualFold(m.Domain, local_domain)
+	}
+
+	return strings.EqualFold(domain, m.Domain)
+}
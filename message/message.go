@@ -0,0 +1,276 @@
+// Package message provides a serializable description of a message, and its Mastodon-specific options,
+// for use by tools that read messages to broadcast from files, HTTP requests or message queues.
+package message
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"image"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/aaronland/go-broadcaster"
+	"github.com/aaronland/go-broadcaster-mastodon"
+)
+
+// Message is a serializable description of a message, and its Mastodon-specific options, to broadcast.
+type Message struct {
+	// Id is an optional caller-defined identifier for the message.
+	Id string `json:"id,omitempty"`
+	// Title is the title of the message.
+	Title string `json:"title,omitempty"`
+	// Body is the body of the message.
+	Body string `json:"body"`
+	// ContentType is the content type of the body, for example "text/markdown".
+	ContentType string `json:"content_type,omitempty"`
+	// Images are zero or more images to include with the message.
+	Images []*Image `json:"images,omitempty"`
+	// Visibility is the visibility of the status.
+	Visibility string `json:"visibility,omitempty"`
+	// ContentWarning is the content warning to display in front of the status.
+	ContentWarning string `json:"content_warning,omitempty"`
+	// Sensitive marks any images as sensitive.
+	Sensitive bool `json:"sensitive,omitempty"`
+	// Language is the ISO 639 language code of the status.
+	Language string `json:"language,omitempty"`
+	// InReplyTo is the ID, or URL, of a status to reply to.
+	InReplyTo string `json:"in_reply_to,omitempty"`
+	// Quote is the ID, or URL, of a status to quote.
+	Quote string `json:"quote,omitempty"`
+	// Schedule is an RFC3339 timestamp, or a duration relative to now, at which to publish the status.
+	Schedule string `json:"schedule,omitempty"`
+	// Poll is an optional poll to attach to the status.
+	Poll *Poll `json:"poll,omitempty"`
+	// Data is arbitrary key/value data passed to the broadcaster's status template, if defined.
+	Data map[string]any `json:"data,omitempty"`
+	// Pin causes the status to be pinned to the profile of the account it is posted with.
+	Pin bool `json:"pin,omitempty"`
+	// PinJob is the name of the job the status is pinned by, used to unpin the status the job previously pinned.
+	PinJob string `json:"pin_job,omitempty"`
+}
+
+// Image describes an image to include with a message. Exactly one of `Path`, `URL` or `Data` should be set.
+type Image struct {
+	// Path is the path to an image on the local filesystem.
+	Path string `json:"path,omitempty"`
+	// URL is the URL of an image to fetch.
+	URL string `json:"url,omitempty"`
+	// Data is a base64-encoded image.
+	Data string `json:"data,omitempty"`
+	// AltText is the alt text description of the image.
+	AltText string `json:"alt_text,omitempty"`
+}
+
+// Poll describes a poll to attach to a status.
+type Poll struct {
+	// Options are the choices for the poll.
+	Options []string `json:"options"`
+	// ExpiresIn is the duration, for example "24h", after which the poll closes.
+	ExpiresIn string `json:"expires_in,omitempty"`
+	// Multiple allows more than one option to be chosen.
+	Multiple bool `json:"multiple,omitempty"`
+	// HideTotals hides vote counts until the poll closes.
+	HideTotals bool `json:"hide_totals,omitempty"`
+}
+
+// DeriveOptions defines the rules for resolving the images associated with a `Message`.
+type DeriveOptions struct {
+	// Root is the directory that relative image paths are resolved against.
+	Root string
+	// AllowPaths allows images to be read from the local filesystem.
+	AllowPaths bool
+	// AllowURLs allows images to be fetched from remote URLs.
+	AllowURLs bool
+	// HTTPClient is the client used to fetch remote images. If nil `http.DefaultClient` is used.
+	HTTPClient *http.Client
+}
+
+// DefaultPollExpiry is the duration after which polls close if `Poll.ExpiresIn` is empty.
+const DefaultPollExpiry = 24 * time.Hour
+
+// Derive returns the `broadcaster.Message` and `mastodon.MessageOptions` instances described by 'm'.
+func (m *Message) Derive(ctx context.Context, opts *DeriveOptions) (*broadcaster.Message, *mastodon.MessageOptions, error) {
+
+	if opts == nil {
+		opts = &DeriveOptions{}
+	}
+
+	msg := &broadcaster.Message{
+		Title: m.Title,
+		Body:  m.Body,
+	}
+
+	msg_opts := &mastodon.MessageOptions{
+		InReplyTo:   m.InReplyTo,
+		Quote:       m.Quote,
+		Visibility:  m.Visibility,
+		SpoilerText: m.ContentWarning,
+		Sensitive:   m.Sensitive,
+		Language:    m.Language,
+		Data:        m.Data,
+		Pin:         m.Pin,
+		PinJob:      m.PinJob,
+		ContentType: m.ContentType,
+	}
+
+	if m.Schedule != "" {
+
+		t, err := ParseSchedule(m.Schedule)
+
+		if err != nil {
+			return nil, nil, err
+		}
+
+		msg_opts.ScheduledAt = t
+	}
+
+	if m.Poll != nil {
+
+		expires := DefaultPollExpiry
+
+		if m.Poll.ExpiresIn != "" {
+
+			d, err := time.ParseDuration(m.Poll.ExpiresIn)
+
+			if err != nil {
+				return nil, nil, fmt.Errorf("Failed to parse poll expiry, %w", err)
+			}
+
+			expires = d
+		}
+
+		msg_opts.Poll = &mastodon.PollOptions{
+			Options:    m.Poll.Options,
+			ExpiresIn:  expires,
+			Multiple:   m.Poll.Multiple,
+			HideTotals: m.Poll.HideTotals,
+		}
+	}
+
+	count_images := len(m.Images)
+
+	if count_images > 0 {
+
+		msg.Images = make([]image.Image, count_images)
+		msg_opts.Descriptions = make([]string, count_images)
+
+		for idx, im_def := range m.Images {
+
+			im, err := im_def.Decode(ctx, opts)
+
+			if err != nil {
+				return nil, nil, fmt.Errorf("Failed to derive image at offset %d, %w", idx, err)
+			}
+
+			msg.Images[idx] = im
+			msg_opts.Descriptions[idx] = im_def.AltText
+		}
+	}
+
+	return msg, msg_opts, nil
+}
+
+// Decode reads and decodes the image described by 'im' subject to the rules defined in 'opts'.
+func (im *Image) Decode(ctx context.Context, opts *DeriveOptions) (image.Image, error) {
+
+	var r io.Reader
+
+	switch {
+	case im.Data != "":
+
+		b, err := base64.StdEncoding.DecodeString(im.Data)
+
+		if err != nil {
+			return nil, fmt.Errorf("Failed to decode image data, %w", err)
+		}
+
+		r = bytes.NewReader(b)
+
+	case im.URL != "":
+
+		if !opts.AllowURLs {
+			return nil, fmt.Errorf("Image URLs are not allowed")
+		}
+
+		cl := opts.HTTPClient
+
+		if cl == nil {
+			cl = http.DefaultClient
+		}
+
+		req, err := http.NewRequestWithContext(ctx, "GET", im.URL, nil)
+
+		if err != nil {
+			return nil, fmt.Errorf("Failed to create request for %s, %w", im.URL, err)
+		}
+
+		rsp, err := cl.Do(req)
+
+		if err != nil {
+			return nil, fmt.Errorf("Failed to fetch %s, %w", im.URL, err)
+		}
+
+		defer rsp.Body.Close()
+
+		if rsp.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("Failed to fetch %s, %s", im.URL, rsp.Status)
+		}
+
+		r = rsp.Body
+
+	case im.Path != "":
+
+		if !opts.AllowPaths {
+			return nil, fmt.Errorf("Image paths are not allowed")
+		}
+
+		path := im.Path
+
+		if !filepath.IsAbs(path) && opts.Root != "" {
+			path = filepath.Join(opts.Root, path)
+		}
+
+		fh, err := os.Open(path)
+
+		if err != nil {
+			return nil, fmt.Errorf("Failed to open %s, %w", path, err)
+		}
+
+		defer fh.Close()
+		r = fh
+
+	default:
+		return nil, fmt.Errorf("Image has no path, URL or data")
+	}
+
+	decoded, _, err := image.Decode(r)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to decode image, %w", err)
+	}
+
+	return decoded, nil
+}
+
+// ParseSchedule parses 'str' as either an RFC3339 timestamp or a duration relative to now.
+func ParseSchedule(str string) (time.Time, error) {
+
+	d, err := time.ParseDuration(str)
+
+	if err == nil {
+		return time.Now().Add(d), nil
+	}
+
+	t, err := time.Parse(time.RFC3339, str)
+
+	if err != nil {
+		return time.Time{}, fmt.Errorf("Failed to parse schedule '%s', %w", str, err)
+	}
+
+	return t, nil
+}
@@ -0,0 +1,149 @@
+package mastodon
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"slices"
+	"strings"
+
+	"github.com/tidwall/gjson"
+)
+
+// The maximum number of notifications to request per page.
+const notifications_page_size = 40
+
+// Notification types reported by `Notification`.
+const (
+	// NotificationMention is the type of notification for a status that mentions, or replies to, the broadcaster's account.
+	NotificationMention string = "mention"
+)
+
+// Notification is a notification received by the account a broadcaster posts as.
+type Notification struct {
+	// Id is the ID of the notification.
+	Id string `json:"id"`
+	// Type is the type of notification, for example "mention".
+	Type string `json:"type"`
+	// CreatedAt is the time the notification was created.
+	CreatedAt string `json:"created_at"`
+	// Account is the Webfinger account URI of the account that triggered the notification, relative to the instance.
+	Account string `json:"account"`
+	// AccountURL is the location of the profile page of the account that triggered the notification.
+	AccountURL string `json:"account_url,omitempty"`
+	// Status is the status associated with the notification, if any.
+	Status *Status `json:"status,omitempty"`
+}
+
+// NotificationsOptions defines which notifications `GetNotifications` returns.
+type NotificationsOptions struct {
+	// Types are the types of notifications to return. If empty all types are returned.
+	Types []string
+	// MinId is the ID of the most recent notification already seen. If set, all the notifications newer
+	// than it are returned. If empty only the most recent page of notifications is returned.
+	MinId string
+}
+
+// GetNotifications returns the notifications received by the account 'b' posts as, ordered from oldest to newest.
+func (b *MastodonBroadcaster) GetNotifications(ctx context.Context, opts *NotificationsOptions) ([]*Notification, error) {
+
+	notifications := make([]*Notification, 0)
+
+	args := &url.Values{}
+	args.Set("limit", fmt.Sprintf("%d", notifications_page_size))
+
+	for _, t := range opts.Types {
+		args.Add("types[]", t)
+	}
+
+	min_id := opts.MinId
+
+	for {
+
+		if min_id != "" {
+			args.Set("min_id", min_id)
+		}
+
+		body, err := b.executeJSON(ctx, "GET", "/api/v1/notifications", args)
+
+		if err != nil {
+			return nil, fmt.Errorf("Failed to retrieve notifications, %w", withPhase(err, PhaseNotifications))
+		}
+
+		page := gjson.ParseBytes(body).Array()
+
+		for _, n := range page {
+			notifications = append(notifications, notificationFromJSON(n))
+		}
+
+		// Without a cursor there is nothing to page forward from
+
+		if min_id == "" || len(page) < notifications_page_size {
+			break
+		}
+
+		// Pages requested with min_id are the notifications immediately newer than it, so keep
+		// moving the cursor forward until there are no more
+
+		next_id := min_id
+
+		for _, n := range page {
+
+			id := n.Get("id").String()
+
+			if CompareIds(id, next_id) > 0 {
+				next_id = id
+			}
+		}
+
+		if next_id == min_id {
+			break
+		}
+
+		b.logger.Debug("Fetch next page of notifications", "min_id", next_id, "count", len(notifications))
+		min_id = next_id
+	}
+
+	slices.SortStableFunc(notifications, func(a *Notification, b *Notification) int {
+		return CompareIds(a.Id, b.Id)
+	})
+
+	return notifications, nil
+}
+
+// notificationFromJSON returns a new `Notification` instance derived from 'r', a Mastodon API notification.
+func notificationFromJSON(r gjson.Result) *Notification {
+
+	n := &Notification{
+		Id:         r.Get("id").String(),
+		Type:       r.Get("type").String(),
+		CreatedAt:  r.Get("created_at").String(),
+		Account:    r.Get("account.acct").String(),
+		AccountURL: r.Get("account.url").String(),
+	}
+
+	status_rsp := r.Get("status")
+
+	if status_rsp.Exists() && status_rsp.Type != gjson.Null {
+		n.Status = statusFromJSON(status_rsp)
+	}
+
+	return n
+}
+
+// CompareIds compares the Mastodon IDs 'a' and 'b', returning -1, 0 or 1 if 'a' is older than, the same as or
+// newer than 'b'. IDs are opaque strings but in practice they are either numeric (Mastodon) or lexically sortable
+// (for example the ULIDs used by GoToSocial) so shorter IDs are considered older.
+func CompareIds(a string, b string) int {
+
+	if len(a) != len(b) {
+
+		if len(a) < len(b) {
+			return -1
+		}
+
+		return 1
+	}
+
+	return strings.Compare(a, b)
+}
@@ -0,0 +1,252 @@
+package mastodon
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/aaronland/go-mastodon-api/v2/client"
+)
+
+// MessageOptions defines per-message options, beyond those defined by `broadcaster.Message`, for
+// posting a message to Mastodon.
+type MessageOptions struct {
+	// InReplyTo is the status ID, or the URL of a status on any Mastodon instance, that the message
+	// is a reply to. Status URLs from remote instances are resolved to local status IDs using the
+	// instance's search API.
+	InReplyTo string
+	// Quote is the status ID, or the URL of a status on any Mastodon instance, that the message
+	// quotes. Status URLs are resolved using the same rules as `InReplyTo`.
+	Quote string
+	// Visibility is the visibility of the status. If empty the default visibility of the broadcaster is used.
+	Visibility string
+	// SpoilerText is the content warning to display in front of the status.
+	SpoilerText string
+	// Sensitive marks any media attached to the status as sensitive.
+	Sensitive bool
+	// Language is the ISO 639 language code of the status.
+	Language string
+	// ScheduledAt is the time at which the status should be published. If zero the status is published immediately.
+	ScheduledAt time.Time
+	// Descriptions is the alt text for each image in the message, in the same order as `broadcaster.Message.Images`.
+	Descriptions []string
+	// Poll is an optional poll to attach to the status. Polls can not be combined with images.
+	Poll *PollOptions
+	// Overflow is the strategy for handling statuses that exceed the instance's maximum length: "error", "ellipsis",
+	// "ellipsis_link" or "thread". If empty the broadcaster's default strategy is used.
+	Overflow string
+	// Data is arbitrary key/value data passed to the broadcaster's status template, if defined.
+	Data map[string]any
+	// Pin causes the status to be pinned to the profile of the account it is posted with, even if pinning is not
+	// enabled for the broadcaster.
+	Pin bool
+	// PinJob is the name of the job the status is pinned by. If empty the broadcaster's default job is used.
+	PinJob string
+	// ContentType is the content type of the message body, for example "text/markdown". If empty the broadcaster's
+	// default content type is used. Markdown is converted in to plain text for instances that do not support it.
+	ContentType string
+	// IdempotencyKey, if not empty, is sent as the "Idempotency-Key" header when posting the status so that if an
+	// earlier attempt to post the message did create a status, for example before timing out, the instance returns
+	// that status rather than creating another. Thread parts use the key suffixed with their position in the thread.
+	// Requests to post statuses with a key are retried like any other request (see `Options.Retries`).
+	IdempotencyKey string
+}
+
+// PollOptions defines a poll to attach to a status.
+type PollOptions struct {
+	// Options are the choices for the poll.
+	Options []string
+	// ExpiresIn is the duration after which the poll closes.
+	ExpiresIn time.Duration
+	// Multiple allows more than one option to be chosen.
+	Multiple bool
+	// HideTotals hides vote counts until the poll closes.
+	HideTotals bool
+}
+
+// ensureVisibility returns an error if 'visibility' is not a valid Mastodon status visibility.
+func ensureVisibility(visibility string) error {
+
+	for _, v := range visibilities {
+		if v == visibility {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("Invalid visibility '%s'", visibility)
+}
+
+// Options defines the configuration of a `MastodonBroadcaster` created with `NewMastodonBroadcasterWithOptions`.
+type Options struct {
+	// Client is the aaronland/go-mastodon-api client used to call the Mastodon API. If nil an `OAuth2Client` is
+	// created using `Host` and `AccessToken`.
+	Client client.Client
+	// Host is the hostname of the Mastodon instance to post to. Required if `Client` is nil.
+	Host string
+	// AccessToken is the OAuth2 access token used to post to `Host`.
+	AccessToken string
+	// HTTPClient is the HTTP client used by the `OAuth2Client` created from `Host` and `AccessToken`. If nil a new
+	// client configured by `Transport` is used.
+	HTTPClient *http.Client
+	// Transport defines the HTTP transport for the `OAuth2Client` created from `Host` and `AccessToken`.
+	Transport *TransportOptions
+	// Visibility is the default visibility for statuses. Default is "public".
+	Visibility string
+	// Quality is the JPEG quality to encode images with. Default is 100.
+	Quality int
+	// Language is the default ISO 639 language code for statuses.
+	Language string
+	// Sensitive marks media attached to every status as sensitive.
+	Sensitive bool
+	// MaxImages is the maximum number of images a message may have. If zero there is no limit other than the instance's.
+	MaxImages int
+	// RequireAltText causes messages with images that are missing alt text not to be posted. Missing alt text is
+	// reported as a `PolicyError`.
+	RequireAltText bool
+	// Dryrun causes messages to be logged but not posted.
+	Dryrun bool
+	// DryrunOutput is an optional directory to write the requests for messages posted in dryrun mode to.
+	DryrunOutput string
+	// Testing, if not nil, causes messages to be handled according to its testing policy.
+	Testing *TestingOptions
+	// Template is an optional Go text/template used to render statuses.
+	Template string
+	// Policy is an optional content policy that statuses are checked against before they are posted.
+	Policy *Policy
+	// Tags are default hashtags to append to every status.
+	Tags []string
+	// ValidateMentions, if not empty, causes mentioned accounts to be resolved before posting. Valid options are
+	// "warn" and "block".
+	ValidateMentions string
+	// RequireDirectRecipients causes statuses with "direct" visibility not to be posted unless every mentioned account resolves.
+	RequireDirectRecipients bool
+	// Overflow is the strategy for handling statuses that exceed the instance's maximum length. Default is "error".
+	Overflow string
+	// Timeout is the maximum amount of time broadcasting a single message may take. If zero there is no timeout.
+	Timeout time.Duration
+	// Retries is the number of times a failed Mastodon API request is retried if the failure might be temporary
+	// (see `IsRetryable`). Requests to post statuses without an idempotency key (see `MessageOptions.IdempotencyKey`),
+	// and to upload media, are only retried if they never reached the instance, were rejected by a rate limit or the
+	// instance was unavailable.
+	Retries int
+	// RetryDelay is the delay before the first retry; it doubles with each subsequent retry. If a request was rejected
+	// by a rate limit with a known reset time the request is retried after that time instead. Default is one second.
+	RetryDelay time.Duration
+	// Hooks are optional functions invoked while broadcasting a message.
+	Hooks *Hooks
+	// Name is an optional name for the account, used to identify it in the results of broadcasters with additional accounts.
+	Name string
+	// Accounts are additional broadcasters, each with their own account, visibility and tags, that messages are also
+	// broadcast with. Each account succeeds or fails independently of the others.
+	Accounts []*MastodonBroadcaster
+	// Amplify causes each of `Accounts` to boost the status posted by the broadcaster instead of posting their own.
+	Amplify bool
+	// Pin causes every status to be pinned to the profile of the broadcaster's account after it is posted.
+	Pin bool
+	// PinJob is the name of the job statuses are pinned by. If empty the broadcaster's `Name` is used, or "default"
+	// if that is also empty.
+	PinJob string
+	// PinState is the path to a JSON file recording the status most recently pinned by each job.
+	PinState string
+	// PinRotate causes the status previously pinned by a job, as recorded in `PinState`, to be unpinned when the
+	// job pins a new status.
+	PinRotate bool
+	// Flavour is the server software of the instance, "mastodon", "gotosocial", "akkoma" or "pleroma", whose
+	// compatibility profile is applied. If empty it is derived from the instance's metadata.
+	Flavour string
+	// ContentType is the default content type of message bodies, for example "text/markdown". If empty message
+	// bodies are plain text.
+	ContentType string
+}
+
+// String returns a description of 'opts' with its access token, and any credentials in its proxy URL, replaced by
+// "REDACTED". Clients, HTTP clients, policies and hooks are only reported as being present or not and additional
+// accounts are only counted.
+func (opts *Options) String() string {
+
+	access_token := ""
+
+	if opts.AccessToken != "" {
+		access_token = Redacted
+	}
+
+	fields := []string{
+		fmt.Sprintf("Client:%t", opts.Client != nil),
+		fmt.Sprintf("Host:%s", opts.Host),
+		fmt.Sprintf("AccessToken:%s", access_token),
+		fmt.Sprintf("HTTPClient:%t", opts.HTTPClient != nil),
+	}
+
+	if opts.Transport != nil {
+
+		fields = append(fields,
+			fmt.Sprintf("Transport:{RequestTimeout:%v ProxyURL:%s CABundle:%s UserAgent:%s}",
+				opts.Transport.RequestTimeout, RedactURI(opts.Transport.ProxyURL), opts.Transport.CABundle, opts.Transport.UserAgent),
+		)
+	}
+
+	fields = append(fields,
+		fmt.Sprintf("Visibility:%s", opts.Visibility),
+		fmt.Sprintf("Quality:%d", opts.Quality),
+		fmt.Sprintf("Language:%s", opts.Language),
+		fmt.Sprintf("Sensitive:%t", opts.Sensitive),
+		fmt.Sprintf("MaxImages:%d", opts.MaxImages),
+		fmt.Sprintf("RequireAltText:%t", opts.RequireAltText),
+		fmt.Sprintf("Dryrun:%t", opts.Dryrun),
+		fmt.Sprintf("DryrunOutput:%s", opts.DryrunOutput),
+	)
+
+	if opts.Testing != nil {
+		fields = append(fields, fmt.Sprintf("Testing:%+v", *opts.Testing))
+	}
+
+	fields = append(fields,
+		fmt.Sprintf("Template:%t", opts.Template != ""),
+		fmt.Sprintf("Policy:%t", opts.Policy != nil),
+		fmt.Sprintf("Tags:%v", opts.Tags),
+		fmt.Sprintf("ValidateMentions:%s", opts.ValidateMentions),
+		fmt.Sprintf("RequireDirectRecipients:%t", opts.RequireDirectRecipients),
+		fmt.Sprintf("Overflow:%s", opts.Overflow),
+		fmt.Sprintf("Timeout:%v", opts.Timeout),
+		fmt.Sprintf("Retries:%d", opts.Retries),
+		fmt.Sprintf("RetryDelay:%v", opts.RetryDelay),
+		fmt.Sprintf("Hooks:%t", opts.Hooks != nil),
+		fmt.Sprintf("Name:%s", opts.Name),
+		fmt.Sprintf("Accounts:%d", len(opts.Accounts)),
+		fmt.Sprintf("Amplify:%t", opts.Amplify),
+		fmt.Sprintf("Pin:%t", opts.Pin),
+		fmt.Sprintf("PinJob:%s", opts.PinJob),
+		fmt.Sprintf("PinState:%s", opts.PinState),
+		fmt.Sprintf("PinRotate:%t", opts.PinRotate),
+		fmt.Sprintf("Flavour:%s", opts.Flavour),
+		fmt.Sprintf("ContentType:%s", opts.ContentType),
+	)
+
+	return fmt.Sprintf("{%s}", strings.Join(fields, " "))
+}
+
+// GoString returns the same redacted description of 'opts' as `String` so that access tokens are not included in
+// "%#v" output.
+func (opts *Options) GoString() string {
+	return opts.String()
+}
+
+// LogValue implements the `slog.LogValuer` interface, returning the redacted description of 'opts' returned by `String`.
+func (opts *Options) LogValue() slog.Value {
+	return slog.StringValue(opts.String())
+}
+
+// TestingOptions defines the testing policy for a `MastodonBroadcaster` created with `NewMastodonBroadcasterWithOptions`.
+type TestingOptions struct {
+	// Prefix is a Go text/template used to render a prefix for test statuses. If empty the default prefix is used
+	// unless `NoPrefix` is true.
+	Prefix string
+	// NoPrefix disables the prefix for test statuses.
+	NoPrefix bool
+	// Visibility, if not empty, is the visibility that all test statuses are posted with.
+	Visibility string
+	// DeleteAfter, if greater than zero, is the amount of time after which test statuses are deleted.
+	DeleteAfter time.Duration
+}
@@ -0,0 +1,211 @@
+package mastodon
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"sync"
+	"time"
+)
+
+// The job that pinned statuses are recorded under if neither the message, the broadcaster's ?pin_job= parameter
+// nor its name define one.
+const default_pin_job = "default"
+
+// pin_state_mu serializes reading and writing pin state files, which may be shared by more than one broadcaster.
+var pin_state_mu sync.Mutex
+
+// pinState records the status most recently pinned by each job.
+type pinState struct {
+	// Jobs maps job names to the status they most recently pinned.
+	Jobs map[string]*pinnedStatus `json:"jobs"`
+}
+
+// pinnedStatus describes a status pinned by a job.
+type pinnedStatus struct {
+	// StatusId is the ID of the pinned status.
+	StatusId string `json:"status_id"`
+	// URL is the web URL of the pinned status.
+	URL string `json:"url,omitempty"`
+	// Time is the time the status was pinned.
+	Time string `json:"time"`
+	// Unpin are the IDs of statuses previously pinned by the job that could not be unpinned. Unpinning them is
+	// retried the next time the job pins a status.
+	Unpin []string `json:"unpin,omitempty"`
+}
+
+// pinJob returns the name of the job that statuses posted with 'opts' are pinned by.
+func (b *MastodonBroadcaster) pinJob(opts *MessageOptions) string {
+
+	switch {
+	case opts.PinJob != "":
+		return opts.PinJob
+	case b.pin_job != "":
+		return b.pin_job
+	case b.name != "":
+		return b.name
+	default:
+		return default_pin_job
+	}
+}
+
+// pinStatus pins the status described by 'rsp' to the profile of the account 'b' posts as if pinning is enabled
+// for 'b' or by 'opts'. If rotation is enabled the status previously pinned by the same job is unpinned. The
+// status has already been posted so failures are logged and recorded in 'rsp' rather than returned.
+func (b *MastodonBroadcaster) pinStatus(ctx context.Context, rsp *Result, opts *MessageOptions) {
+
+	if !b.pin && !opts.Pin {
+		return
+	}
+
+	job := b.pinJob(opts)
+	logger := b.logger.With("status ID", rsp.Id, "job", job)
+
+	switch {
+	case rsp.ScheduledAt != "":
+		rsp.PinError = "Scheduled statuses can not be pinned"
+	case rsp.Visibility == "direct":
+		rsp.PinError = "Statuses with 'direct' visibility can not be pinned"
+	}
+
+	if rsp.PinError != "" {
+		logger.Warn("Failed to pin status", "error", rsp.PinError)
+		return
+	}
+
+	if b.dryrun {
+		logger.Info("Dryrun pin", "rotate", b.pin_rotate)
+		rsp.Pinned = true
+		return
+	}
+
+	_, err := b.executeJSON(ctx, "POST", fmt.Sprintf("/api/v1/statuses/%s/pin", rsp.Id), &url.Values{})
+
+	if err != nil {
+		err = fmt.Errorf("Failed to pin status %s, %w", rsp.Id, withPhase(err, PhasePin))
+		logger.Error("Failed to pin status", "error", err)
+		rsp.PinError = err.Error()
+		return
+	}
+
+	rsp.Pinned = true
+	logger.Info("Mastodon pin successful")
+
+	// Statuses posted in testing mode are often deleted so they are not recorded, and don't cause
+	// the statuses pinned by the job outside of testing mode to be unpinned
+
+	if b.pin_state == "" || b.testing {
+		return
+	}
+
+	pin_state_mu.Lock()
+	defer pin_state_mu.Unlock()
+
+	s, err := readPinState(b.pin_state)
+
+	if err != nil {
+		logger.Error("Failed to read pin state, previous status will not be unpinned", "error", err)
+		return
+	}
+
+	pinned := &pinnedStatus{
+		StatusId: rsp.Id,
+		URL:      rsp.URL,
+		Time:     time.Now().UTC().Format(time.RFC3339),
+		Unpin:    make([]string, 0),
+	}
+
+	prev, exists := s.Jobs[job]
+
+	if exists && b.pin_rotate {
+
+		unpin := append([]string{prev.StatusId}, prev.Unpin...)
+
+		for _, id := range unpin {
+
+			if id == rsp.Id {
+				continue
+			}
+
+			unpinned, err := b.unpinStatus(ctx, id)
+
+			if err != nil {
+				logger.Error("Failed to unpin previous status, will retry on next pin", "previous status ID", id, "error", err)
+				pinned.Unpin = append(pinned.Unpin, id)
+				continue
+			}
+
+			if unpinned {
+				logger.Info("Unpinned previous status", "previous status ID", id)
+				rsp.Unpinned = append(rsp.Unpinned, id)
+			}
+		}
+	}
+
+	s.Jobs[job] = pinned
+
+	err = writePinState(b.pin_state, s)
+
+	if err != nil {
+		logger.Error("Failed to write pin state", "error", err)
+	}
+}
+
+// unpinStatus unpins the status 'id' from the profile of the account 'b' posts as, returning false if the status
+// has since been deleted, which is not considered an error.
+func (b *MastodonBroadcaster) unpinStatus(ctx context.Context, id string) (bool, error) {
+
+	_, err := b.executeJSON(ctx, "POST", fmt.Sprintf("/api/v1/statuses/%s/unpin", id), &url.Values{})
+
+	if err == nil {
+		return true, nil
+	}
+
+	var api_err *APIError
+
+	if errors.As(err, &api_err) && api_err.StatusCode == http.StatusNotFound {
+		b.logger.Debug("Previous status no longer exists", "status ID", id)
+		return false, nil
+	}
+
+	return false, fmt.Errorf("Failed to unpin status %s, %w", id, withPhase(err, PhasePin))
+}
+
+// readPinState reads the pin state file at 'path'. If the file does not exist an empty state is returned.
+func readPinState(path string) (*pinState, error) {
+
+	s := &pinState{
+		Jobs: make(map[string]*pinnedStatus),
+	}
+
+	body, err := os.ReadFile(path)
+
+	if os.IsNotExist(err) {
+		return s, nil
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to read %s, %w", path, err)
+	}
+
+	err = json.Unmarshal(body, s)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to parse %s, %w", path, err)
+	}
+
+	if s.Jobs == nil {
+		s.Jobs = make(map[string]*pinnedStatus)
+	}
+
+	return s, nil
+}
+
+// writePinState atomically writes 's' to 'path'.
+func writePinState(path string, s *pinState) error {
+	return WriteJSONFile(path, s)
+}
@@ -0,0 +1,382 @@
+package mastodon
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+const (
+	// PolicyWarn signals that a policy violation should be logged (and reported in the `Result`) but the status still posted.
+	PolicyWarn string = "warn"
+	// PolicyBlock signals that a policy violation should prevent a status from being posted.
+	PolicyBlock string = "block"
+)
+
+// PolicyRule defines the action to take when a content policy rule fails.
+type PolicyRule struct {
+	// Action is either "warn" or "block". Default is "block".
+	Action string `yaml:"action" json:"action"`
+}
+
+// ForbiddenWordsRule fails if a status contains any of its words. Words are matched case-insensitively on word boundaries.
+type ForbiddenWordsRule struct {
+	PolicyRule `yaml:",inline"`
+	Words      []string `yaml:"words" json:"words"`
+}
+
+// ForbiddenPatternsRule fails if a status matches any of its regular expressions.
+type ForbiddenPatternsRule struct {
+	PolicyRule `yaml:",inline"`
+	Patterns   []string `yaml:"patterns" json:"patterns"`
+}
+
+// AllowedDomainsRule fails if a status contains a link to a host that is not one of (or a subdomain of) its domains.
+type AllowedDomainsRule struct {
+	PolicyRule `yaml:",inline"`
+	Domains    []string `yaml:"domains" json:"domains"`
+}
+
+// HashtagsRule fails if a status is missing any of its required hashtags or contains more than its maximum number of hashtags.
+type HashtagsRule struct {
+	PolicyRule `yaml:",inline"`
+	Required   []string `yaml:"required" json:"required"`
+	Max        int      `yaml:"max" json:"max"`
+}
+
+// MentionsRule fails if a status mentions more than its maximum number of accounts.
+type MentionsRule struct {
+	PolicyRule `yaml:",inline"`
+	Max        int `yaml:"max" json:"max"`
+}
+
+// Policy defines content checks that are applied to statuses before they are posted. Rules that are not defined are not checked.
+type Policy struct {
+	ForbiddenWords     *ForbiddenWordsRule    `yaml:"forbidden_words" json:"forbidden_words"`
+	ForbiddenPatterns  *ForbiddenPatternsRule `yaml:"forbidden_patterns" json:"forbidden_patterns"`
+	AllowedLinkDomains *AllowedDomainsRule    `yaml:"allowed_link_domains" json:"allowed_link_domains"`
+	Hashtags           *HashtagsRule          `yaml:"hashtags" json:"hashtags"`
+	Mentions           *MentionsRule          `yaml:"mentions" json:"mentions"`
+	// RequireAltText fails if any image is missing a description.
+	RequireAltText *PolicyRule `yaml:"require_alt_text" json:"require_alt_text"`
+	// EmptyBody fails if the text of a status is empty.
+	EmptyBody *PolicyRule `yaml:"empty_body" json:"empty_body"`
+
+	forbidden_words    []*regexp.Regexp
+	forbidden_patterns []*regexp.Regexp
+}
+
+// PolicyViolation describes a single content policy rule that a status failed.
+type PolicyViolation struct {
+	// Rule is the name of the rule, as it appears in the policy file.
+	Rule string `json:"rule"`
+	// Action is the action associated with the rule, either "warn" or "block".
+	Action string `json:"action"`
+	// Message is a human-readable description of the violation.
+	Message string `json:"message"`
+}
+
+func (v *PolicyViolation) String() string {
+	return fmt.Sprintf("%s (%s): %s", v.Rule, v.Action, v.Message)
+}
+
+// PolicyError is returned when a status fails one or more content policy rules whose action is "block".
+type PolicyError struct {
+	// Violations are all the rules the status failed, including those whose action is "warn".
+	Violations []*PolicyViolation
+}
+
+func (e *PolicyError) Error() string {
+
+	msgs := make([]string, len(e.Violations))
+
+	for idx, v := range e.Violations {
+		msgs[idx] = v.String()
+	}
+
+	return fmt.Sprintf("Status failed content policy: %s", strings.Join(msgs, "; "))
+}
+
+// policyCheck contains the parts of a status that content policy rules are applied to.
+type policyCheck struct {
+	Status       string
+	SpoilerText  string
+	Images       int
+	Descriptions []string
+}
+
+// loadPolicy reads and parses the content policy defined by 'uri' which may be a sfomuseum/runtimevar URI
+// or the path to a file on the local filesystem. Policies may be encoded as YAML or JSON.
+func loadPolicy(ctx context.Context, uri string) (*Policy, error) {
+
+	body, err := readConfig(ctx, uri)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to read policy, %w", err)
+	}
+
+	return parsePolicy([]byte(body))
+}
+
+// parsePolicy parses 'body' as a YAML (or JSON) encoded `Policy` and compiles its rules.
+func parsePolicy(body []byte) (*Policy, error) {
+
+	var p *Policy
+
+	err := yaml.UnmarshalStrict(body, &p)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to unmarshal policy, %w", err)
+	}
+
+	if p == nil {
+		p = &Policy{}
+	}
+
+	err = p.compile()
+
+	if err != nil {
+		return nil, err
+	}
+
+	return p, nil
+}
+
+// compile validates the actions of the rules in 'p', assigning the default action to rules without one, and
+// compiles its forbidden words and patterns.
+func (p *Policy) compile() error {
+
+	rules := map[string]*PolicyRule{}
+
+	if p.ForbiddenWords != nil {
+		rules["forbidden_words"] = &p.ForbiddenWords.PolicyRule
+	}
+
+	if p.ForbiddenPatterns != nil {
+		rules["forbidden_patterns"] = &p.ForbiddenPatterns.PolicyRule
+	}
+
+	if p.AllowedLinkDomains != nil {
+		rules["allowed_link_domains"] = &p.AllowedLinkDomains.PolicyRule
+	}
+
+	if p.Hashtags != nil {
+		rules["hashtags"] = &p.Hashtags.PolicyRule
+	}
+
+	if p.Mentions != nil {
+		rules["mentions"] = &p.Mentions.PolicyRule
+	}
+
+	if p.RequireAltText != nil {
+		rules["require_alt_text"] = p.RequireAltText
+	}
+
+	if p.EmptyBody != nil {
+		rules["empty_body"] = p.EmptyBody
+	}
+
+	for name, r := range rules {
+
+		switch r.Action {
+		case "":
+			r.Action = PolicyBlock
+		case PolicyWarn, PolicyBlock:
+			// pass
+		default:
+			return fmt.Errorf("Invalid action '%s' for rule '%s'", r.Action, name)
+		}
+	}
+
+	p.forbidden_words = make([]*regexp.Regexp, 0)
+	p.forbidden_patterns = make([]*regexp.Regexp, 0)
+
+	if p.ForbiddenWords != nil {
+
+		for _, w := range p.ForbiddenWords.Words {
+
+			re, err := regexp.Compile(`(?i)(?:^|[^\p{L}\p{N}_])` + regexp.QuoteMeta(w) + `(?:$|[^\p{L}\p{N}_])`)
+
+			if err != nil {
+				return fmt.Errorf("Failed to compile forbidden word '%s', %w", w, err)
+			}
+
+			p.forbidden_words = append(p.forbidden_words, re)
+		}
+	}
+
+	if p.ForbiddenPatterns != nil {
+
+		for _, str_re := range p.ForbiddenPatterns.Patterns {
+
+			re, err := regexp.Compile(str_re)
+
+			if err != nil {
+				return fmt.Errorf("Failed to compile forbidden pattern '%s', %w", str_re, err)
+			}
+
+			p.forbidden_patterns = append(p.forbidden_patterns, re)
+		}
+	}
+
+	return nil
+}
+
+// check applies every rule in 'p' to 'c' and returns the list of rules that failed.
+func (p *Policy) check(c *policyCheck) []*PolicyViolation {
+
+	violations := make([]*PolicyViolation, 0)
+
+	fail := func(rule string, r *PolicyRule, msg string, args ...any) {
+
+		violations = append(violations, &PolicyViolation{
+			Rule:    rule,
+			Action:  r.Action,
+			Message: fmt.Sprintf(msg, args...),
+		})
+	}
+
+	text := c.Status
+
+	if c.SpoilerText != "" {
+		text = c.SpoilerText + "\n" + text
+	}
+
+	if p.EmptyBody != nil && strings.TrimSpace(c.Status) == "" {
+		fail("empty_body", p.EmptyBody, "Status text is empty")
+	}
+
+	if p.ForbiddenWords != nil {
+
+		for idx, re := range p.forbidden_words {
+
+			if re.MatchString(text) {
+				fail("forbidden_words", &p.ForbiddenWords.PolicyRule, "Status contains forbidden word '%s'", p.ForbiddenWords.Words[idx])
+			}
+		}
+	}
+
+	if p.ForbiddenPatterns != nil {
+
+		for idx, re := range p.forbidden_patterns {
+
+			if re.MatchString(text) {
+				fail("forbidden_patterns", &p.ForbiddenPatterns.PolicyRule, "Status matches forbidden pattern '%s'", p.ForbiddenPatterns.Patterns[idx])
+			}
+		}
+	}
+
+	if p.AllowedLinkDomains != nil {
+
+		for _, link := range extractLinks(text) {
+
+			u, err := url.Parse(link)
+
+			if err != nil {
+				fail("allowed_link_domains", &p.AllowedLinkDomains.PolicyRule, "Status contains invalid link '%s'", link)
+				continue
+			}
+
+			if !isAllowedDomain(u.Hostname(), p.AllowedLinkDomains.Domains) {
+				fail("allowed_link_domains", &p.AllowedLinkDomains.PolicyRule, "Status links to disallowed domain '%s'", u.Hostname())
+			}
+		}
+	}
+
+	if p.Hashtags != nil {
+
+		tags := extractHashtags(c.Status)
+
+		for _, req := range p.Hashtags.Required {
+
+			req = strings.TrimPrefix(req, "#")
+			found := false
+
+			for _, t := range tags {
+
+				if strings.EqualFold(t, req) {
+					found = true
+					break
+				}
+			}
+
+			if !found {
+				fail("hashtags", &p.Hashtags.PolicyRule, "Status is missing required hashtag '#%s'", req)
+			}
+		}
+
+		if p.Hashtags.Max > 0 && len(tags) > p.Hashtags.Max {
+			fail("hashtags", &p.Hashtags.PolicyRule, "Status contains %d hashtags, maximum is %d", len(tags), p.Hashtags.Max)
+		}
+	}
+
+	if p.Mentions != nil {
+
+		mentions := extractMentions(c.Status)
+
+		if p.Mentions.Max > 0 && len(mentions) > p.Mentions.Max {
+			fail("mentions", &p.Mentions.PolicyRule, "Status contains %d mentions, maximum is %d", len(mentions), p.Mentions.Max)
+		}
+	}
+
+	if p.RequireAltText != nil {
+
+		for i := 0; i < c.Images; i++ {
+
+			if i >= len(c.Descriptions) || strings.TrimSpace(c.Descriptions[i]) == "" {
+				fail("require_alt_text", p.RequireAltText, "Image %d is missing alt text", i+1)
+			}
+		}
+	}
+
+	return violations
+}
+
+// isAllowedDomain returns true if 'host' is equal to, or a subdomain of, any of 'domains'.
+func isAllowedDomain(host string, domains []string) bool {
+
+	host = strings.ToLower(host)
+
+	for _, d := range domains {
+
+		d = strings.ToLower(strings.TrimPrefix(d, "."))
+
+		if host == d || strings.HasSuffix(host, "."+d) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// enforcePolicy applies the content policy rules in 'b' to 'c'. Violations whose action is "warn" are logged and returned.
+// If any violations have a "block" action a `PolicyError` listing every violation is returned.
+func (b *MastodonBroadcaster) enforcePolicy(c *policyCheck) ([]*PolicyViolation, error) {
+
+	if b.policy == nil {
+		return nil, nil
+	}
+
+	violations := b.policy.check(c)
+	blocked := false
+
+	for _, v := range violations {
+
+		if v.Action == PolicyBlock {
+			blocked = true
+			continue
+		}
+
+		b.logger.Warn("Status failed content policy", "rule", v.Rule, "message", v.Message)
+	}
+
+	if blocked {
+		return nil, &PolicyError{Violations: violations}
+	}
+
+	return violations, nil
+}
@@ -0,0 +1,434 @@
+package mastodon
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+// The name of the environment variable that defines the location of the profiles configuration file.
+const ConfigEnvVar string = "MASTODON_BROADCASTER_CONFIG"
+
+// Config defines named profiles that can be referenced by `mastodon://profile/{NAME}` URIs.
+type Config struct {
+	// Profiles maps profile names to their definitions.
+	Profiles map[string]*Profile `yaml:"profiles"`
+	// root is the directory relative paths in the config are resolved against.
+	root string
+}
+
+// Profile bundles the configuration for posting to a Mastodon account.
+type Profile struct {
+	// Description is an optional description of the profile.
+	Description string `yaml:"description"`
+	// Credentials is a sfomuseum/runtimevar URI which resolves to a valid aaronland/go-mastodon-api client URI.
+	Credentials string `yaml:"credentials"`
+	// Host is the hostname of the Mastodon instance to post to, if `Credentials` is not set.
+	Host string `yaml:"host"`
+	// Token is a sfomuseum/runtimevar (or "env://{NAME}") URI which resolves to the access token used to post to `Host`.
+	Token string `yaml:"token"`
+	// Visibility is the default visibility for statuses.
+	Visibility string `yaml:"visibility"`
+	// Language is the default ISO 639 language code for statuses.
+	Language string `yaml:"language"`
+	// Tags are default hashtags to append to every status.
+	Tags []string `yaml:"tags"`
+	// Media defines how images are handled.
+	Media *MediaProfile `yaml:"media"`
+	// Testing defines the testing policy for the profile.
+	Testing *TestingProfile `yaml:"testing"`
+	// Policy is a sfomuseum/runtimevar URI, or a path, for a content policy file.
+	Policy string `yaml:"policy"`
+	// Template is a sfomuseum/runtimevar URI, or a path, for a status template.
+	Template string `yaml:"template"`
+	// Pin defines how statuses are pinned to the profile of the account.
+	Pin *PinProfile `yaml:"pin"`
+	// Accounts are the names of other profiles, or `mastodon://` URIs, for additional accounts that messages are also
+	// broadcast with. Profiles used as accounts can not have accounts of their own.
+	Accounts []string `yaml:"accounts"`
+	// Amplify causes `Accounts` to boost the status posted by the profile instead of posting their own.
+	Amplify bool `yaml:"amplify"`
+	// Parameters are any other `mastodon://` URI parameters to apply to the profile.
+	Parameters map[string]string `yaml:"parameters"`
+}
+
+// MediaProfile defines how images are handled for a profile.
+type MediaProfile struct {
+	// Quality is the JPEG quality to encode images with.
+	Quality int `yaml:"quality"`
+	// MaxImages is the maximum number of images a message may have.
+	MaxImages int `yaml:"max_images"`
+	// RequireAltText causes messages with images that are missing alt text not to be posted.
+	RequireAltText bool `yaml:"require_alt_text"`
+	// Sensitive marks all media as sensitive.
+	Sensitive bool `yaml:"sensitive"`
+}
+
+// PinProfile defines how statuses are pinned to the profile of an account.
+type PinProfile struct {
+	// Enabled causes every status to be pinned.
+	Enabled bool `yaml:"enabled"`
+	// Job is the name of the job statuses are pinned by. If empty the name of the profile is used.
+	Job string `yaml:"job"`
+	// State is the path to a JSON file recording the status most recently pinned by each job.
+	State string `yaml:"state"`
+	// Rotate causes the status previously pinned by the same job to be unpinned.
+	Rotate bool `yaml:"rotate"`
+}
+
+// TestingProfile defines the testing policy for a profile.
+type TestingProfile struct {
+	// Enabled enables testing mode.
+	Enabled bool `yaml:"enabled"`
+	// Credentials is a sfomuseum/runtimevar URI for the credentials of a sandbox account to post test messages with.
+	Credentials string `yaml:"credentials"`
+	// Prefix is a Go text/template used to render a prefix for test statuses. If present but empty no prefix is added.
+	Prefix *string `yaml:"prefix"`
+	// Visibility is the visibility that all test statuses are posted with.
+	Visibility string `yaml:"visibility"`
+	// DeleteAfter is a duration after which test statuses are deleted.
+	DeleteAfter string `yaml:"delete_after"`
+}
+
+// DefaultConfigPath returns the location of the profiles configuration file used when none is specified: the value
+// of the MASTODON_BROADCASTER_CONFIG environment variable if set, or "go-broadcaster-mastodon/config.yml" in the
+// user's configuration directory.
+func DefaultConfigPath() (string, error) {
+
+	path := os.Getenv(ConfigEnvVar)
+
+	if path != "" {
+		return path, nil
+	}
+
+	root, err := os.UserConfigDir()
+
+	if err != nil {
+		return "", fmt.Errorf("Failed to determine user configuration directory, %w", err)
+	}
+
+	return filepath.Join(root, "go-broadcaster-mastodon", "config.yml"), nil
+}
+
+// LoadConfig reads and parses the profiles configuration file defined by 'uri' which may be a sfomuseum/runtimevar
+// URI or the path to a file on the local filesystem. Relative paths for policy and template files in a local
+// configuration file are resolved relative to the directory containing that file.
+func LoadConfig(ctx context.Context, uri string) (*Config, error) {
+
+	body, err := readConfig(ctx, uri)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to read config, %w", err)
+	}
+
+	var cfg *Config
+
+	err = yaml.UnmarshalStrict([]byte(body), &cfg)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to unmarshal config, %w", err)
+	}
+
+	if cfg == nil || len(cfg.Profiles) == 0 {
+		return nil, fmt.Errorf("Config does not define any profiles")
+	}
+
+	u, err := url.Parse(uri)
+
+	if err != nil || u.Scheme == "" {
+		cfg.root = filepath.Dir(uri)
+	}
+
+	return cfg, nil
+}
+
+// ProfileNames returns the sorted list of profile names defined in 'cfg'.
+func (cfg *Config) ProfileNames() []string {
+
+	names := make([]string, 0, len(cfg.Profiles))
+
+	for name := range cfg.Profiles {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+	return names
+}
+
+// Profile returns the profile named 'name'.
+func (cfg *Config) Profile(name string) (*Profile, error) {
+
+	p, ok := cfg.Profiles[name]
+
+	if !ok || p == nil {
+		return nil, fmt.Errorf("Profile '%s' not found", name)
+	}
+
+	return p, nil
+}
+
+// Query returns the `mastodon://` URI parameters for the profile named 'name'.
+func (cfg *Config) Query(name string) (url.Values, error) {
+
+	p, err := cfg.Profile(name)
+
+	if err != nil {
+		return nil, err
+	}
+
+	q := url.Values{}
+
+	for k, v := range p.Parameters {
+		q.Set(k, v)
+	}
+
+	set := func(k string, v string) {
+
+		if v != "" {
+			q.Set(k, v)
+		}
+	}
+
+	set("credentials", p.Credentials)
+	set("host", p.Host)
+	set("token", p.Token)
+	set("visibility", p.Visibility)
+	set("language", p.Language)
+	set("policy", cfg.resolvePath(p.Policy))
+	set("template", cfg.resolvePath(p.Template))
+
+	if len(p.Tags) > 0 {
+		q.Set("tags", strings.Join(p.Tags, ","))
+	}
+
+	if p.Media != nil {
+
+		if p.Media.Quality != 0 {
+			q.Set("quality", strconv.Itoa(p.Media.Quality))
+		}
+
+		if p.Media.MaxImages != 0 {
+			q.Set("max_images", strconv.Itoa(p.Media.MaxImages))
+		}
+
+		if p.Media.RequireAltText {
+			q.Set("require_alt_text", "true")
+		}
+
+		if p.Media.Sensitive {
+			q.Set("sensitive", "true")
+		}
+	}
+
+	if p.Testing != nil {
+
+		if p.Testing.Enabled {
+			q.Set("testing", "true")
+		}
+
+		set("testing_credentials", p.Testing.Credentials)
+		set("testing_visibility", p.Testing.Visibility)
+		set("testing_delete_after", p.Testing.DeleteAfter)
+
+		if p.Testing.Prefix != nil {
+			q.Set("testing_prefix", *p.Testing.Prefix)
+		}
+	}
+
+	if p.Pin != nil {
+
+		if p.Pin.Enabled {
+			q.Set("pin", "true")
+		}
+
+		if p.Pin.Rotate {
+			q.Set("pin_rotate", "true")
+		}
+
+		set("pin_job", p.Pin.Job)
+		set("pin_state", cfg.resolvePath(p.Pin.State))
+	}
+
+	if p.Amplify {
+		q.Set("amplify", "true")
+	}
+
+	for _, acct := range p.Accounts {
+
+		if strings.Contains(acct, "://") {
+			q.Add("account", acct)
+			continue
+		}
+
+		acct_p, err := cfg.Profile(acct)
+
+		if err != nil {
+			return nil, fmt.Errorf("Invalid account for profile '%s', %w", name, err)
+		}
+
+		if len(acct_p.Accounts) > 0 {
+			return nil, fmt.Errorf("Profile '%s' can not be an account of profile '%s' because it has accounts of its own", acct, name)
+		}
+
+		acct_q, err := cfg.Query(acct)
+
+		if err != nil {
+			return nil, err
+		}
+
+		if !acct_q.Has("name") {
+			acct_q.Set("name", acct)
+		}
+
+		q.Add("account", "mastodon://?"+acct_q.Encode())
+	}
+
+	return q, nil
+}
+
+// Validate checks that the profile named 'name' defines credentials and that its parameters are valid. Credentials
+// are not resolved. Any policy and template files are read.
+func (cfg *Config) Validate(ctx context.Context, name string) error {
+
+	q, err := cfg.Query(name)
+
+	if err != nil {
+		return err
+	}
+
+	err = validateQuery(ctx, q)
+
+	if err != nil {
+		return fmt.Errorf("Profile '%s' is invalid, %w", name, err)
+	}
+
+	return nil
+}
+
+// validateQuery checks that 'q', a set of `mastodon://` URI parameters, defines credentials and that its parameters,
+// and those of any additional account URIs, are valid. Credentials are not resolved.
+func validateQuery(ctx context.Context, q url.Values) error {
+
+	if q.Get("credentials") == "" && (q.Get("host") == "" || q.Get("token") == "") {
+		return fmt.Errorf("Missing credentials")
+	}
+
+	opts, err := optionsFromQuery(ctx, q)
+
+	if err != nil {
+		return err
+	}
+
+	if opts.Amplify && len(q["account"]) == 0 {
+		return fmt.Errorf("Amplification requires one or more additional accounts")
+	}
+
+	_, err = newBroadcasterFromOptions(opts)
+
+	if err != nil {
+		return err
+	}
+
+	for idx, acct_uri := range q["account"] {
+
+		u, err := url.Parse(acct_uri)
+
+		if err != nil {
+			return fmt.Errorf("Failed to parse URI for account %d, %w", idx+1, RedactError(err))
+		}
+
+		// Account profiles are validated on their own
+
+		if u.Host == "profile" {
+			continue
+		}
+
+		acct_q := u.Query()
+
+		if len(acct_q["account"]) > 0 {
+			return fmt.Errorf("Invalid account %d, additional accounts can not have additional accounts of their own", idx+1)
+		}
+
+		err = validateQuery(ctx, acct_q)
+
+		if err != nil {
+			return fmt.Errorf("Invalid account %d, %w", idx+1, err)
+		}
+	}
+
+	return nil
+}
+
+// resolvePath returns 'path' relative to the directory containing the configuration file, if 'path' is a relative
+// path and the configuration was read from the local filesystem.
+func (cfg *Config) resolvePath(path string) string {
+
+	if path == "" || cfg.root == "" || filepath.IsAbs(path) {
+		return path
+	}
+
+	u, err := url.Parse(path)
+
+	if err == nil && u.Scheme != "" {
+		return path
+	}
+
+	return filepath.Join(cfg.root, path)
+}
+
+// profileQuery returns the `mastodon://` URI parameters for the profile named by the path of 'u', a
+// `mastodon://profile/{NAME}` URI, merged with the parameters of 'u' which take precedence. The profiles
+// configuration file is read from the ?config= parameter or `DefaultConfigPath`.
+func profileQuery(ctx context.Context, u *url.URL) (url.Values, error) {
+
+	name := strings.Trim(u.Path, "/")
+
+	if name == "" {
+		return nil, fmt.Errorf("Missing profile name")
+	}
+
+	q := u.Query()
+
+	config_uri := q.Get("config")
+
+	if config_uri == "" {
+
+		path, err := DefaultConfigPath()
+
+		if err != nil {
+			return nil, err
+		}
+
+		config_uri = path
+	}
+
+	cfg, err := LoadConfig(ctx, config_uri)
+
+	if err != nil {
+		return nil, err
+	}
+
+	profile_q, err := cfg.Query(name)
+
+	if err != nil {
+		return nil, err
+	}
+
+	for k, v := range q {
+
+		if k == "config" {
+			continue
+		}
+
+		profile_q[k] = v
+	}
+
+	return profile_q, nil
+}
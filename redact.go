@@ -0,0 +1,422 @@
+package mastodon
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// The string secret values are replaced with by `RedactURI`.
+const Redacted string = "REDACTED"
+
+// The `mastodon://` URI parameters whose values are, or reference, secrets.
+var secret_parameters = []string{
+	"credentials",
+	"testing_credentials",
+	"token",
+}
+
+// RedactURI returns a copy of 'uri', a `mastodon://` URI, with any access tokens in its credentials and token
+// parameters, and those of any additional account URIs, replaced by "REDACTED". Parameters that reference a secret stored elsewhere, for example a file or
+// an environment variable, are left as-is. If 'uri' can not be parsed "REDACTED" is returned.
+func RedactURI(uri string) string {
+
+	u, err := url.Parse(uri)
+
+	if err != nil {
+		return Redacted
+	}
+
+	if u.User != nil {
+		u.User = url.User(Redacted)
+	}
+
+	q := u.Query()
+
+	for _, k := range secret_parameters {
+
+		if !q.Has(k) {
+			continue
+		}
+
+		q.Set(k, RedactSecretURI(q.Get(k)))
+	}
+
+	for idx, acct_uri := range q["account"] {
+		q["account"][idx] = RedactURI(acct_uri)
+	}
+
+	u.RawQuery = q.Encode()
+	return formatURI(u, uri)
+}
+
+// RedactSecretURI returns a copy of 'uri', a sfomuseum/runtimevar (or "env://") URI for a secret value, with the
+// value replaced by "REDACTED" if it is a constant. If the constant is an aaronland/go-mastodon-api client URI only
+// the access token is redacted. URIs that reference a secret stored elsewhere are returned as-is.
+func RedactSecretURI(uri string) string {
+
+	u, err := url.Parse(uri)
+
+	if err != nil {
+		return Redacted
+	}
+
+	switch u.Scheme {
+	case "constant":
+
+		q := u.Query()
+		q.Set("val", redactClientURI(q.Get("val")))
+
+		u.RawQuery = q.Encode()
+		return formatURI(u, uri)
+
+	case "":
+		// sfomuseum/runtimevar returns the path of URIs without a scheme as-is
+		return redactClientURI(uri)
+
+	default:
+
+		if u.User != nil {
+			return redactClientURI(uri)
+		}
+
+		return uri
+	}
+}
+
+// redactClientURI returns a copy of 'uri', expected to be an aaronland/go-mastodon-api client URI, with its access
+// token replaced by "REDACTED". If 'uri' is not a URI with user information it is assumed to be a bare token and
+// "REDACTED" is returned.
+func redactClientURI(uri string) string {
+
+	uri = strings.TrimSpace(uri)
+
+	u, err := url.Parse(uri)
+
+	if err != nil || u.Scheme == "" || u.User == nil {
+		return Redacted
+	}
+
+	_, has_password := u.User.Password()
+
+	if has_password {
+		u.User = url.UserPassword(u.User.Username(), Redacted)
+	} else {
+		u.User = url.User(Redacted)
+	}
+
+	return u.String()
+}
+
+// formatURI returns the string form of 'u', preserving the empty authority ("//") of hostless URIs like
+// `mastodon://?credentials=...` if it was present in 'uri', the string 'u' was parsed from.
+func formatURI(u *url.URL, uri string) string {
+
+	str := u.String()
+
+	if u.Host == "" && u.User == nil && strings.HasPrefix(uri, u.Scheme+"://") && !strings.HasPrefix(str, u.Scheme+"://") {
+		str = u.Scheme + "://" + strings.TrimPrefix(str, u.Scheme+":")
+	}
+
+	return str
+}
+
+// RedactError returns a copy of 'err' with the URL reported by any `url.Error` in its chain, for example the error
+// returned when a `mastodon://` URI can not be parsed, redacted using `RedactURI`. The returned error wraps 'err'.
+func RedactError(err error) error {
+
+	if err == nil {
+		return nil
+	}
+
+	r := newRedactor()
+	return r.redactError(err)
+}
+
+// redactor replaces a set of secret values, and their URL-escaped forms, in strings, errors and log records.
+type redactor struct {
+	values []string
+}
+
+// newRedactor returns a new `redactor` for 'values'.
+func newRedactor(values ...string) *redactor {
+
+	r := &redactor{
+		values: make([]string, 0),
+	}
+
+	r.add(values...)
+	return r
+}
+
+// add adds 'values', and their URL-escaped forms, to the list of values redacted by 'r'.
+func (r *redactor) add(values ...string) {
+
+	for _, v := range values {
+
+		if v == "" {
+			continue
+		}
+
+		forms := []string{
+			v,
+			url.QueryEscape(v),
+			url.QueryEscape(url.QueryEscape(v)),
+			url.PathEscape(v),
+			url.UserPassword("", v).String(),
+		}
+
+		for _, f := range forms {
+
+			f = strings.TrimPrefix(f, ":")
+
+			if f == "" || f == Redacted || r.has(f) {
+				continue
+			}
+
+			r.values = append(r.values, f)
+		}
+	}
+
+	// Replace longer values first so that a value which contains another value is redacted in its entirety
+
+	sort.SliceStable(r.values, func(i, j int) bool {
+		return len(r.values[i]) > len(r.values[j])
+	})
+}
+
+// addURI adds the secret values in 'uri', a sfomuseum/runtimevar (or "env://") URI or an aaronland/go-mastodon-api
+// client URI, to the list of values redacted by 'r'. URIs that reference a secret stored elsewhere contain no
+// secret values.
+func (r *redactor) addURI(uri string) {
+
+	uri = strings.TrimSpace(uri)
+
+	if uri == "" {
+		return
+	}
+
+	u, err := url.Parse(uri)
+
+	if err != nil || u.Scheme == "" {
+		r.add(uri)
+		return
+	}
+
+	if u.Scheme == "constant" {
+		r.addURI(u.Query().Get("val"))
+		return
+	}
+
+	if u.User != nil {
+
+		pswd, has_password := u.User.Password()
+
+		if has_password {
+			r.add(pswd)
+		} else {
+			r.add(u.User.Username())
+		}
+	}
+}
+
+// addQuery adds the secret values in the parameters of 'q', a set of `mastodon://` URI parameters, including those
+// of any additional account URIs, to the list of values redacted by 'r'.
+func (r *redactor) addQuery(q url.Values) {
+
+	for _, k := range secret_parameters {
+		r.addURI(q.Get(k))
+	}
+
+	for _, acct_uri := range q["account"] {
+
+		u, err := url.Parse(acct_uri)
+
+		if err != nil {
+			r.add(acct_uri)
+			continue
+		}
+
+		r.addQuery(u.Query())
+	}
+}
+
+// has returns a boolean value indicating whether 'v' is redacted by 'r'.
+func (r *redactor) has(v string) bool {
+
+	for _, existing := range r.values {
+
+		if existing == v {
+			return true
+		}
+	}
+
+	return false
+}
+
+// redact returns a copy of 'str' with the values redacted by 'r' replaced by "REDACTED".
+func (r *redactor) redact(str string) string {
+
+	for _, v := range r.values {
+		str = strings.ReplaceAll(str, v, Redacted)
+	}
+
+	return str
+}
+
+// redactError returns 'err' wrapped in a `redactedError` whose message has the values redacted by 'r', and the
+// URL reported by any `url.Error`, redacted.
+func (r *redactor) redactError(err error) error {
+
+	if err == nil {
+		return nil
+	}
+
+	msg := err.Error()
+
+	var url_err *url.Error
+
+	if errors.As(err, &url_err) {
+		msg = strings.ReplaceAll(msg, url_err.URL, RedactURI(url_err.URL))
+		msg = strings.ReplaceAll(msg, fmt.Sprintf("%q", url_err.URL), fmt.Sprintf("%q", RedactURI(url_err.URL)))
+	}
+
+	msg = r.redact(msg)
+
+	if msg == err.Error() {
+		return err
+	}
+
+	e := &redactedError{
+		err: err,
+		msg: msg,
+	}
+
+	return e
+}
+
+// redactedError wraps an error whose message contains secret values, replacing its message with a redacted copy.
+// The wrapped error is still available to `errors.Is` and `errors.As`.
+type redactedError struct {
+	err error
+	msg string
+}
+
+func (e *redactedError) Error() string {
+	return e.msg
+}
+
+func (e *redactedError) Unwrap() error {
+	return e.err
+}
+
+// redactingHandler is a `slog.Handler` which redacts the values redacted by 'redactor' from log messages and
+// attributes before passing them to 'handler' or, if nil, the handler of the default logger at the time the record
+// is logged.
+type redactingHandler struct {
+	redactor *redactor
+	handler  slog.Handler
+}
+
+// newRedactingLogger returns a new `slog.Logger` which redacts the values redacted by 'r' and logs to the default logger.
+func newRedactingLogger(r *redactor) *slog.Logger {
+
+	h := &redactingHandler{
+		redactor: r,
+	}
+
+	return slog.New(h)
+}
+
+func (h *redactingHandler) next() slog.Handler {
+
+	if h.handler != nil {
+		return h.handler
+	}
+
+	return slog.Default().Handler()
+}
+
+func (h *redactingHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.next().Enabled(ctx, level)
+}
+
+func (h *redactingHandler) Handle(ctx context.Context, rec slog.Record) error {
+
+	redacted_rec := slog.NewRecord(rec.Time, rec.Level, h.redactor.redact(rec.Message), rec.PC)
+
+	rec.Attrs(func(a slog.Attr) bool {
+		redacted_rec.AddAttrs(h.redactAttr(a))
+		return true
+	})
+
+	return h.next().Handle(ctx, redacted_rec)
+}
+
+func (h *redactingHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+
+	redacted_attrs := make([]slog.Attr, len(attrs))
+
+	for idx, a := range attrs {
+		redacted_attrs[idx] = h.redactAttr(a)
+	}
+
+	return &redactingHandler{
+		redactor: h.redactor,
+		handler:  h.next().WithAttrs(redacted_attrs),
+	}
+}
+
+func (h *redactingHandler) WithGroup(name string) slog.Handler {
+
+	return &redactingHandler{
+		redactor: h.redactor,
+		handler:  h.next().WithGroup(name),
+	}
+}
+
+// redactAttr returns a copy of 'a' with any secret values redacted. Values of any other kind than strings, errors
+// and groups are only replaced, by their redacted string form, if that string contains a secret value.
+func (h *redactingHandler) redactAttr(a slog.Attr) slog.Attr {
+
+	v := a.Value.Resolve()
+
+	switch v.Kind() {
+	case slog.KindString:
+		return slog.String(a.Key, h.redactor.redact(v.String()))
+	case slog.KindGroup:
+
+		group := v.Group()
+		redacted_group := make([]any, len(group))
+
+		for idx, ga := range group {
+			redacted_group[idx] = h.redactAttr(ga)
+		}
+
+		return slog.Group(a.Key, redacted_group...)
+
+	case slog.KindAny:
+
+		err, is_error := v.Any().(error)
+
+		if is_error {
+			return slog.Any(a.Key, h.redactor.redactError(err))
+		}
+
+		str := fmt.Sprint(v.Any())
+		redacted_str := h.redactor.redact(str)
+
+		if redacted_str != str {
+			return slog.String(a.Key, redacted_str)
+		}
+
+		return slog.Attr{Key: a.Key, Value: v}
+
+	default:
+		return slog.Attr{Key: a.Key, Value: v}
+	}
+}
@@ -0,0 +1,529 @@
+package mastodon
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aaronland/go-broadcaster"
+)
+
+// A token containing characters that are escaped differently in URL paths, queries and user information.
+const test_token = "s3cr3t/t0ken+with=special&chars"
+
+// expectRedacted fails the test if 'str' contains 'token' in any of the forms it might be encoded as.
+func expectRedacted(t *testing.T, label string, str string, token string) {
+
+	t.Helper()
+
+	forms := []string{
+		token,
+		url.QueryEscape(token),
+		url.PathEscape(token),
+		url.QueryEscape(url.QueryEscape(token)),
+	}
+
+	for _, f := range forms {
+
+		if strings.Contains(str, f) {
+			t.Fatalf("Expected %s to be redacted, found '%s' in '%s'", label, f, str)
+		}
+	}
+}
+
+func TestRedactor(t *testing.T) {
+
+	r := newRedactor(test_token)
+
+	tests := []string{
+		fmt.Sprintf("token %s", test_token),
+		fmt.Sprintf("https://example.social/?token=%s", url.QueryEscape(test_token)),
+		fmt.Sprintf("https://example.social/%s", url.PathEscape(test_token)),
+		fmt.Sprintf("mastodon://?credentials=%s", url.QueryEscape(url.QueryEscape(test_token))),
+		fmt.Sprintf("oauth2://%s@example.social", url.UserPassword("", test_token).String()),
+	}
+
+	for _, str := range tests {
+
+		redacted := r.redact(str)
+
+		expectRedacted(t, "string", redacted, test_token)
+
+		if !strings.Contains(redacted, Redacted) {
+			t.Fatalf("Expected '%s' to contain %s", redacted, Redacted)
+		}
+	}
+
+	if r.redact("nothing to see here") != "nothing to see here" {
+		t.Fatalf("Expected string without secrets to be unchanged")
+	}
+}
+
+func TestRedactorEmpty(t *testing.T) {
+
+	r := newRedactor("", Redacted)
+
+	if len(r.values) != 0 {
+		t.Fatalf("Expected empty and already redacted values to be ignored, got %v", r.values)
+	}
+}
+
+func TestRedactorLongestFirst(t *testing.T) {
+
+	r := newRedactor("abc", "abcdef")
+
+	redacted := r.redact("abcdef")
+
+	if redacted != Redacted {
+		t.Fatalf("Expected longer value to be redacted in its entirety, got '%s'", redacted)
+	}
+}
+
+func TestRedactorAddURI(t *testing.T) {
+
+	client_uri := fmt.Sprintf("oauth2://:%s@example.social", url.QueryEscape(test_token))
+
+	tests := []string{
+		test_token,
+		client_uri,
+		fmt.Sprintf("constant://?val=%s", url.QueryEscape(client_uri)),
+		fmt.Sprintf("constant://?val=%s", url.QueryEscape(test_token)),
+	}
+
+	for _, uri := range tests {
+
+		r := newRedactor()
+		r.addURI(uri)
+
+		expectRedacted(t, "token", r.redact(test_token), test_token)
+	}
+
+	r := newRedactor()
+	r.addURI("env://MASTODON_CREDENTIALS")
+
+	if len(r.values) != 0 {
+		t.Fatalf("Expected URIs which reference a secret stored elsewhere not to be redacted, got %v", r.values)
+	}
+}
+
+func TestRedactorAddQuery(t *testing.T) {
+
+	acct_uri := fmt.Sprintf("mastodon://?token=%s&host=example.social", url.QueryEscape("constant://?val="+url.QueryEscape("account-"+test_token)))
+	creds_uri := fmt.Sprintf("constant://?val=%s", url.QueryEscape(fmt.Sprintf("oauth2://:%s@example.social", url.QueryEscape(test_token))))
+
+	q := url.Values{}
+	q.Set("credentials", creds_uri)
+	q.Add("account", acct_uri)
+
+	r := newRedactor()
+	r.addQuery(q)
+
+	expectRedacted(t, "token", r.redact(test_token), test_token)
+	expectRedacted(t, "account token", r.redact("account-"+test_token), "account-"+test_token)
+}
+
+func TestRedactURI(t *testing.T) {
+
+	client_uri := fmt.Sprintf("oauth2://:%s@example.social", url.QueryEscape(test_token))
+
+	tests := map[string]string{
+		"constant credentials": fmt.Sprintf("mastodon://?credentials=%s", url.QueryEscape("constant://?val="+url.QueryEscape(client_uri))),
+		"bare credentials":     fmt.Sprintf("mastodon://?credentials=%s", url.QueryEscape(client_uri)),
+		"token":                fmt.Sprintf("mastodon://?host=example.social&token=%s", url.QueryEscape("constant://?val="+url.QueryEscape(test_token))),
+		"testing credentials":  fmt.Sprintf("mastodon://?credentials=env://CREDS&testing_credentials=%s", url.QueryEscape(client_uri)),
+		"account":              fmt.Sprintf("mastodon://?credentials=env://CREDS&account=%s", url.QueryEscape(fmt.Sprintf("mastodon://?credentials=%s", url.QueryEscape(client_uri)))),
+		"user info":            fmt.Sprintf("mastodon://%s@example.social", url.QueryEscape(test_token)),
+	}
+
+	for label, uri := range tests {
+
+		redacted := RedactURI(uri)
+
+		expectRedacted(t, label, redacted, test_token)
+
+		if !strings.Contains(redacted, Redacted) {
+			t.Fatalf("Expected %s URI '%s' to contain %s", label, redacted, Redacted)
+		}
+
+		if !strings.HasPrefix(redacted, "mastodon://") {
+			t.Fatalf("Expected %s URI '%s' to keep its scheme", label, redacted)
+		}
+	}
+
+	uri := "mastodon://?credentials=env://MASTODON_CREDENTIALS&dryrun=true"
+	redacted, _ := url.QueryUnescape(RedactURI(uri))
+
+	if !strings.Contains(redacted, "env://MASTODON_CREDENTIALS") {
+		t.Fatalf("Expected URIs which reference a secret stored elsewhere to be left as-is, got '%s'", redacted)
+	}
+
+	if RedactURI(fmt.Sprintf("mastodon://\x7f%s", test_token)) != Redacted {
+		t.Fatalf("Expected invalid URI to be redacted in its entirety")
+	}
+}
+
+func TestRedactSecretURI(t *testing.T) {
+
+	client_uri := fmt.Sprintf("oauth2://:%s@example.social", url.QueryEscape(test_token))
+
+	tests := map[string]string{
+		"constant client URI": "constant://?val=" + url.QueryEscape(client_uri),
+		"constant token":      "constant://?val=" + url.QueryEscape(test_token),
+		"client URI":          client_uri,
+		"bare token":          test_token,
+	}
+
+	for label, uri := range tests {
+
+		redacted := RedactSecretURI(uri)
+		expectRedacted(t, label, redacted, test_token)
+
+		if !strings.Contains(redacted, Redacted) {
+			t.Fatalf("Expected %s '%s' to contain %s", label, redacted, Redacted)
+		}
+	}
+
+	redacted, _ := url.QueryUnescape(RedactSecretURI("constant://?val=" + url.QueryEscape(client_uri)))
+
+	if !strings.Contains(redacted, "example.social") {
+		t.Fatalf("Expected only the access token of a client URI to be redacted, got '%s'", redacted)
+	}
+
+	for _, uri := range []string{"env://MASTODON_CREDENTIALS", "file:///usr/local/etc/credentials"} {
+
+		if RedactSecretURI(uri) != uri {
+			t.Fatalf("Expected '%s' to be left as-is, got '%s'", uri, RedactSecretURI(uri))
+		}
+	}
+}
+
+func TestRedactError(t *testing.T) {
+
+	if RedactError(nil) != nil {
+		t.Fatalf("Expected nil error to be returned as nil")
+	}
+
+	// Control characters cause url.Parse to return a url.Error reporting the URI
+
+	uri := fmt.Sprintf("mastodon://?credentials=%s&dryrun=\x7f", url.QueryEscape("constant://?val="+url.QueryEscape(test_token)))
+
+	_, parse_err := url.Parse(uri)
+
+	if parse_err == nil {
+		t.Fatalf("Expected '%s' to fail to parse", uri)
+	}
+
+	err := RedactError(fmt.Errorf("Failed to parse broadcaster URI, %w", parse_err))
+
+	expectRedacted(t, "error", err.Error(), test_token)
+
+	var url_err *url.Error
+
+	if !errors.As(err, &url_err) {
+		t.Fatalf("Expected redacted error to wrap url.Error")
+	}
+
+	plain_err := errors.New("Nothing to see here")
+
+	if RedactError(plain_err) != plain_err {
+		t.Fatalf("Expected error without secrets to be returned as-is")
+	}
+}
+
+func TestRedactorRedactError(t *testing.T) {
+
+	r := newRedactor(test_token)
+
+	api_err := &APIError{
+		StatusCode: http.StatusUnauthorized,
+		Status:     "401 Unauthorized",
+		Message:    fmt.Sprintf("Invalid token %s", test_token),
+	}
+
+	err := r.redactError(fmt.Errorf("Failed to post status, %w", &UnauthorizedError{api_err}))
+
+	expectRedacted(t, "error", err.Error(), test_token)
+
+	var unauthorized_err *UnauthorizedError
+
+	if !errors.As(err, &unauthorized_err) {
+		t.Fatalf("Expected redacted error to wrap UnauthorizedError")
+	}
+}
+
+func TestRedactingHandler(t *testing.T) {
+
+	var buf bytes.Buffer
+
+	h := &redactingHandler{
+		redactor: newRedactor(test_token),
+		handler:  slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}),
+	}
+
+	logger := slog.New(h)
+
+	logger.Info(fmt.Sprintf("Message %s", test_token))
+	logger.Warn("String", "token", test_token)
+	logger.Error("Error", "error", fmt.Errorf("Failed with %s", test_token))
+	logger.Debug("Any", "values", []string{test_token})
+	logger.Debug("URL", "url", &url.URL{Scheme: "https", Host: "example.social", RawQuery: "token=" + url.QueryEscape(test_token)})
+	logger.Info("Group", slog.Group("request", slog.String("token", test_token)))
+	logger.With("token", test_token).Info("With attributes")
+	logger.WithGroup("request").Info("With group", "token", test_token)
+
+	out := buf.String()
+
+	if strings.Count(out, "\n") != 8 {
+		t.Fatalf("Expected 8 log records, got '%s'", out)
+	}
+
+	expectRedacted(t, "log output", out, test_token)
+
+	if strings.Count(out, Redacted) < 8 {
+		t.Fatalf("Expected every log record to contain %s, got '%s'", Redacted, out)
+	}
+}
+
+func TestRedactingLoggerDefault(t *testing.T) {
+
+	buf := captureDefaultLogger(t)
+
+	logger := newRedactingLogger(newRedactor(test_token))
+	logger.Warn("Default", "token", test_token)
+
+	if !strings.Contains(buf.String(), Redacted) {
+		t.Fatalf("Expected redacted record to be logged to the default logger, got '%s'", buf.String())
+	}
+
+	expectRedacted(t, "log output", buf.String(), test_token)
+}
+
+// lockedBuffer is a `bytes.Buffer` which is safe to write to from multiple goroutines.
+type lockedBuffer struct {
+	buf bytes.Buffer
+	mu  sync.Mutex
+}
+
+func (b *lockedBuffer) Write(p []byte) (int, error) {
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.buf.Write(p)
+}
+
+func (b *lockedBuffer) String() string {
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.buf.String()
+}
+
+// captureDefaultLogger replaces the default logger with one that logs debug messages to the returned buffer until
+// the test completes.
+func captureDefaultLogger(t *testing.T) *lockedBuffer {
+
+	buf := new(lockedBuffer)
+	default_logger := slog.Default()
+
+	slog.SetDefault(slog.New(slog.NewTextHandler(buf, &slog.HandlerOptions{Level: slog.LevelDebug})))
+
+	t.Cleanup(func() {
+		slog.SetDefault(default_logger)
+	})
+
+	return buf
+}
+
+// newEchoServer returns a test server which fails every request with 'status' and an error message containing the
+// access token of the request.
+func newEchoServer(t *testing.T, status int) *httptest.Server {
+
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(rsp http.ResponseWriter, req *http.Request) {
+
+		token := strings.TrimPrefix(req.Header.Get("Authorization"), "Bearer ")
+
+		rsp.Header().Set("Content-Type", "application/json")
+		rsp.WriteHeader(status)
+
+		fmt.Fprintf(rsp, `{"error":"Request with token %s and %s failed","error_description":"%s"}`, token, url.QueryEscape(token), url.PathEscape(token))
+	}))
+
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestPostMessageRedactsErrors(t *testing.T) {
+
+	tests := map[string]int{
+		"validation":   http.StatusUnprocessableEntity,
+		"unauthorized": http.StatusUnauthorized,
+		"server":       http.StatusServiceUnavailable,
+	}
+
+	for label, status := range tests {
+
+		t.Run(label, func(t *testing.T) {
+
+			ctx := context.Background()
+
+			logs := captureDefaultLogger(t)
+			srv := newEchoServer(t, status)
+
+			hook_errors := make([]error, 0)
+			mu := new(sync.Mutex)
+
+			record := func(err error) {
+				mu.Lock()
+				defer mu.Unlock()
+				hook_errors = append(hook_errors, err)
+			}
+
+			br, err := NewMastodonBroadcasterWithOptions(ctx, &Options{
+				Host:        strings.TrimPrefix(srv.URL, "https://"),
+				AccessToken: test_token,
+				HTTPClient:  srv.Client(),
+				Retries:     1,
+				RetryDelay:  time.Millisecond,
+				Hooks: &Hooks{
+					OnError: func(ctx context.Context, msg *broadcaster.Message, err error) {
+						record(err)
+					},
+					OnRetry: func(ctx context.Context, attempt int, err error) {
+						record(err)
+					},
+				},
+			})
+
+			if err != nil {
+				t.Fatalf("Failed to create broadcaster, %v", err)
+			}
+
+			defer br.Close(ctx)
+
+			msg := &broadcaster.Message{
+				Body: "Hello world",
+			}
+
+			_, err = br.PostMessage(ctx, msg, nil)
+
+			if err == nil {
+				t.Fatalf("Expected PostMessage to fail")
+			}
+
+			expectRedacted(t, "error", err.Error(), test_token)
+
+			var api_err *APIError
+
+			if !errors.As(err, &api_err) {
+				t.Fatalf("Expected redacted error to wrap APIError, %v", err)
+			}
+
+			if api_err.StatusCode != status {
+				t.Fatalf("Expected status %d, got %d", status, api_err.StatusCode)
+			}
+
+			if len(hook_errors) == 0 {
+				t.Fatalf("Expected OnError hook to be invoked")
+			}
+
+			for _, hook_err := range hook_errors {
+				expectRedacted(t, "hook error", hook_err.Error(), test_token)
+			}
+
+			expectRedacted(t, "log output", logs.String(), test_token)
+		})
+	}
+}
+
+func TestPostMessageRedactsLocalErrors(t *testing.T) {
+
+	ctx := context.Background()
+
+	logs := captureDefaultLogger(t)
+	srv := newEchoServer(t, http.StatusInternalServerError)
+
+	br, err := NewMastodonBroadcasterWithOptions(ctx, &Options{
+		Host:        strings.TrimPrefix(srv.URL, "https://"),
+		AccessToken: test_token,
+		HTTPClient:  srv.Client(),
+		Hooks: &Hooks{
+			BeforePost: func(ctx context.Context, msg *broadcaster.Message, opts *MessageOptions) error {
+				return fmt.Errorf("Rejected message for token %s", test_token)
+			},
+		},
+	})
+
+	if err != nil {
+		t.Fatalf("Failed to create broadcaster, %v", err)
+	}
+
+	defer br.Close(ctx)
+
+	msg := &broadcaster.Message{
+		Body: "Hello world",
+	}
+
+	_, err = br.PostMessage(ctx, msg, nil)
+
+	if err == nil {
+		t.Fatalf("Expected PostMessage to fail")
+	}
+
+	expectRedacted(t, "error", err.Error(), test_token)
+	expectRedacted(t, "log output", logs.String(), test_token)
+}
+
+func TestPostMessageRedactsTransportErrors(t *testing.T) {
+
+	ctx := context.Background()
+
+	logs := captureDefaultLogger(t)
+
+	srv := newEchoServer(t, http.StatusOK)
+	host := strings.TrimPrefix(srv.URL, "https://")
+	srv.Close()
+
+	br, err := NewMastodonBroadcasterWithOptions(ctx, &Options{
+		Host:        host,
+		AccessToken: test_token,
+		HTTPClient:  srv.Client(),
+		Retries:     1,
+		RetryDelay:  time.Millisecond,
+	})
+
+	if err != nil {
+		t.Fatalf("Failed to create broadcaster, %v", err)
+	}
+
+	defer br.Close(ctx)
+
+	msg := &broadcaster.Message{
+		Body: "Hello world",
+	}
+
+	_, err = br.PostMessage(ctx, msg, nil)
+
+	if err == nil {
+		t.Fatalf("Expected PostMessage to fail")
+	}
+
+	var url_err *url.Error
+
+	if !errors.As(err, &url_err) {
+		t.Fatalf("Expected transport error to wrap url.Error, %v", err)
+	}
+
+	expectRedacted(t, "error", err.Error(), test_token)
+	expectRedacted(t, "log output", logs.String(), test_token)
+}
@@ -0,0 +1,242 @@
+package mastodon
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/tidwall/gjson"
+)
+
+// The set of Mastodon status visibilities ordered from least to most restrictive.
+var visibilities = []string{
+	"public",
+	"unlisted",
+	"private",
+	"direct",
+}
+
+// replyContext contains the properties of a status being replied to that affect the reply.
+type replyContext struct {
+	// The local ID of the status being replied to.
+	Id string
+	// The visibility of the status being replied to.
+	Visibility string
+	// The accounts to mention in the reply.
+	Mentions []string
+}
+
+// resolveStatusId returns the local status ID for 'str' which may be a status ID or the URL of a status
+// on any Mastodon instance. URLs are resolved using the `/api/v2/search` endpoint with `resolve=true`
+// so that statuses from remote instances are fetched by the local instance if necessary.
+func (b *MastodonBroadcaster) resolveStatusId(ctx context.Context, str string) (string, error) {
+
+	str = strings.TrimSpace(str)
+
+	if str == "" {
+		return "", fmt.Errorf("Empty status ID")
+	}
+
+	if !strings.HasPrefix(str, "https://") && !strings.HasPrefix(str, "http://") {
+		return str, nil
+	}
+
+	args := &url.Values{}
+	args.Set("q", str)
+	args.Set("type", "statuses")
+	args.Set("resolve", "true")
+	args.Set("limit", "1")
+
+	body, err := b.executeJSON(ctx, "GET", "/api/v2/search", args)
+
+	if err != nil {
+		return "", fmt.Errorf("Failed to search for status %s, %w", str, err)
+	}
+
+	id_rsp := gjson.GetBytes(body, "statuses.0.id")
+
+	if !id_rsp.Exists() {
+		return "", fmt.Errorf("Failed to resolve status %s", str)
+	}
+
+	b.logger.Debug("Resolved status URL", "url", str, "id", id_rsp.String())
+	return id_rsp.String(), nil
+}
+
+// deriveReplyContext resolves 'str' to a local status ID and returns the visibility of that status
+// and the accounts that should be mentioned in a reply to it. As with the Mastodon web client the
+// author of the status and any accounts it mentions are included, excluding the account doing the
+// posting.
+func (b *MastodonBroadcaster) deriveReplyContext(ctx context.Context, str string) (*replyContext, error) {
+
+	status_id, err := b.resolveStatusId(ctx, str)
+
+	if err != nil {
+		return nil, err
+	}
+
+	status_body, err := b.executeJSON(ctx, "GET", fmt.Sprintf("/api/v1/statuses/%s", status_id), &url.Values{})
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to retrieve status %s, %w", status_id, err)
+	}
+
+	self, err := b.VerifyCredentials(ctx)
+
+	if err != nil {
+		return nil, err
+	}
+
+	mentions := make([]string, 0)
+	seen := map[string]bool{
+		strings.ToLower(self.Acct): true,
+	}
+
+	accts := []string{
+		gjson.GetBytes(status_body, "account.acct").String(),
+	}
+
+	for _, m := range gjson.GetBytes(status_body, "mentions.#.acct").Array() {
+		accts = append(accts, m.String())
+	}
+
+	for _, acct := range accts {
+
+		k := strings.ToLower(acct)
+
+		if acct == "" || seen[k] {
+			continue
+		}
+
+		seen[k] = true
+		mentions = append(mentions, acct)
+	}
+
+	rc := &replyContext{
+		Id:         status_id,
+		Visibility: gjson.GetBytes(status_body, "visibility").String(),
+		Mentions:   mentions,
+	}
+
+	return rc, nil
+}
+
+// replyVisibility returns the more restrictive of 'requested' and 'parent'. Replying to a private
+// or direct status with a public reply would expose the conversation to people who could not see
+// the original status.
+func replyVisibility(requested string, parent string) string {
+	return RestrictiveVisibility(requested, parent)
+}
+
+// RestrictiveVisibility returns the more restrictive of the status visibilities 'requested' and 'source', for
+// example "private" for "public" and "private". Unrecognized visibilities are treated as "public" so 'requested'
+// is returned unless 'source' is more restrictive.
+func RestrictiveVisibility(requested string, source string) string {
+
+	requested_idx := visibilityIndex(requested)
+	source_idx := visibilityIndex(source)
+
+	if source_idx > requested_idx {
+		return visibilities[source_idx]
+	}
+
+	return requested
+}
+
+func visibilityIndex(visibility string) int {
+
+	for idx, v := range visibilities {
+		if v == visibility {
+			return idx
+		}
+	}
+
+	return 0
+}
+
+// prependMentions prefixes 'status' with each account in 'mentions' that is not already mentioned. Accounts are
+// compared case-insensitively against the whole mentions in 'status', so "@bob" is not mistaken for "@bobby".
+func prependMentions(status string, mentions []string) string {
+
+	mentioned := make(map[string]bool)
+
+	for _, m := range extractMentions(status) {
+		mentioned[strings.ToLower(m.Acct())] = true
+	}
+
+	missing := make([]string, 0)
+
+	for _, acct := range mentions {
+
+		acct = strings.TrimPrefix(acct, "@")
+
+		if mentioned[strings.ToLower(acct)] {
+			continue
+		}
+
+		mentioned[strings.ToLower(acct)] = true
+		missing = append(missing, fmt.Sprintf("@%s", acct))
+	}
+
+	if len(missing) == 0 {
+		return status
+	}
+
+	return fmt.Sprintf("%s %s", strings.Join(missing, " "), status)
+}
+
+// executeJSON executes a Mastodon API method and returns the body of the response.
+func (b *MastodonBroadcaster) executeJSON(ctx context.Context, http_method string, api_method string, args *url.Values) ([]byte, error) {
+
+	body, _, err := b.executeJSONWithHeader(ctx, http_method, api_method, args)
+	return body, err
+}
+
+// executeJSONWithHeader executes a Mastodon API method and returns the body and the headers of the response. The
+// headers are only available for clients, like `OAuth2Client`, whose responses have a `Header() http.Header` method,
+// otherwise they are empty.
+func (b *MastodonBroadcaster) executeJSONWithHeader(ctx context.Context, http_method string, api_method string, args *url.Values) ([]byte, http.Header, error) {
+
+	var rsp io.ReadSeekCloser
+
+	// POST requests are only idempotent if the instance can recognize a repeated request by its idempotency key
+
+	idempotent := http_method != "POST" || idempotencyKey(ctx) != ""
+
+	err := b.withRetries(ctx, idempotent, func() error {
+
+		r, err := b.mastodon_client.ExecuteMethod(ctx, http_method, api_method, args)
+
+		if err != nil {
+			return err
+		}
+
+		rsp = r
+		return nil
+	})
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	defer rsp.Close()
+
+	body, err := io.ReadAll(rsp)
+
+	if err != nil {
+		return nil, nil, fmt.Errorf("Failed to read response, %w", err)
+	}
+
+	header := http.Header{}
+
+	h, ok := rsp.(interface{ Header() http.Header })
+
+	if ok && h.Header() != nil {
+		header = h.Header()
+	}
+
+	return body, header, nil
+}
@@ -0,0 +1,41 @@
+package mastodon
+
+// Result contains details about a status posted to Mastodon.
+type Result struct {
+	// Id is the ID of the status, or of the scheduled status if `ScheduledAt` is set.
+	Id string `json:"id"`
+	// URL is the web URL of the status.
+	URL string `json:"url,omitempty"`
+	// URI is the ActivityPub URI of the status.
+	URI string `json:"uri,omitempty"`
+	// CreatedAt is the time the status was created, as reported by the Mastodon instance.
+	CreatedAt string `json:"created_at,omitempty"`
+	// ScheduledAt is the time a scheduled status will be published.
+	ScheduledAt string `json:"scheduled_at,omitempty"`
+	// Visibility is the visibility the status was posted with.
+	Visibility string `json:"visibility"`
+	// InReplyToId is the ID of the status this status is a reply to.
+	InReplyToId string `json:"in_reply_to_id,omitempty"`
+	// MediaIds are the IDs of any media attached to the status.
+	MediaIds []string `json:"media_ids,omitempty"`
+	// Thread are the IDs of any replies the status was split in to because it exceeded the instance's maximum length.
+	Thread []string `json:"thread,omitempty"`
+	// Warnings are any content policy rules, whose action is "warn", that the status failed.
+	Warnings []*PolicyViolation `json:"warnings,omitempty"`
+	// UnresolvedMentions are any mentioned accounts that could not be resolved, if mentions are validated with a "warn" action.
+	UnresolvedMentions []string `json:"unresolved_mentions,omitempty"`
+	// Dryrun is true if the status was not actually posted.
+	Dryrun bool `json:"dryrun,omitempty"`
+	// DryrunPath is the path of the file the dryrun request was written to, if any.
+	DryrunPath string `json:"dryrun_path,omitempty"`
+	// Pinned is true if the status was pinned to the profile of the account that posted it.
+	Pinned bool `json:"pinned,omitempty"`
+	// Unpinned are the IDs of any statuses previously pinned by the same job that were unpinned.
+	Unpinned []string `json:"unpinned,omitempty"`
+	// PinError is the message of the error, if any, that caused the status not to be pinned.
+	PinError string `json:"pin_error,omitempty"`
+	// Account is the name of the account that posted the status, if the broadcaster has additional accounts.
+	Account string `json:"account,omitempty"`
+	// Accounts describe the outcome for each additional account of the broadcaster, if any.
+	Accounts []*AccountResult `json:"accounts,omitempty"`
+}
@@ -0,0 +1,104 @@
+package mastodon
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"time"
+)
+
+// withRetries invokes 'fn' and, if it fails with an error that might be temporary, retries it up to the number
+// of times configured for 'b'. Requests that are not 'idempotent' are only retried if they never reached the
+// instance or were rejected by a rate limit, or because the instance was unavailable, since otherwise there is no
+// way to know whether the original request was handled.
+func (b *MastodonBroadcaster) withRetries(ctx context.Context, idempotent bool, fn func() error) error {
+
+	attempt := 0
+
+	for {
+
+		err := fn()
+
+		if err == nil || attempt >= b.retries || !shouldRetry(err, idempotent) {
+			return err
+		}
+
+		attempt += 1
+		delay := b.retryDelay(attempt, err)
+
+		if b.hooks != nil && b.hooks.OnRetry != nil {
+			b.hooks.OnRetry(ctx, attempt, b.redactor.redactError(err))
+		}
+
+		b.logger.Warn("API call failed, retrying", "attempt", attempt, "delay", delay, "error", err)
+
+		timer := time.NewTimer(delay)
+
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		case <-timer.C:
+		}
+	}
+}
+
+// shouldRetry returns true if a request that failed with 'err' should be retried.
+func shouldRetry(err error, idempotent bool) bool {
+
+	var rate_err *RateLimitError
+
+	if errors.As(err, &rate_err) {
+		return true
+	}
+
+	if !idempotent {
+		return isUnavailable(err) || isNotSent(err)
+	}
+
+	return IsRetryable(err)
+}
+
+// isUnavailable returns true if 'err' is an API error with a "503 Service Unavailable" status, which instances
+// return when they are not handling requests.
+func isUnavailable(err error) bool {
+
+	var api_err *APIError
+
+	return errors.As(err, &api_err) && api_err.StatusCode == http.StatusServiceUnavailable
+}
+
+// isNotSent returns true if 'err' reports that a request was never sent to the instance, for example because its
+// host name could not be resolved or a connection to it could not be established.
+func isNotSent(err error) bool {
+
+	var dns_err *net.DNSError
+
+	if errors.As(err, &dns_err) {
+		return true
+	}
+
+	var op_err *net.OpError
+
+	return errors.As(err, &op_err) && op_err.Op == "dial"
+}
+
+// retryDelay returns the amount of time to wait before retry number 'attempt' of a request that failed with 'err'.
+// Requests rejected by a rate limit with a known reset time are retried after that time; otherwise the delay
+// configured for 'b' is doubled for each attempt.
+func (b *MastodonBroadcaster) retryDelay(attempt int, err error) time.Duration {
+
+	var rate_err *RateLimitError
+
+	if errors.As(err, &rate_err) && !rate_err.RateLimitReset.IsZero() {
+
+		d := time.Until(rate_err.RateLimitReset)
+
+		if d > 0 {
+			return d
+		}
+	}
+
+	return b.retry_delay * time.Duration(1<<(attempt-1))
+}
@@ -0,0 +1,143 @@
+package mastodon
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+
+	"github.com/tidwall/gjson"
+)
+
+// Status contains details about an existing Mastodon status.
+type Status struct {
+	// Id is the ID of the status, relative to the instance it was retrieved from.
+	Id string `json:"id"`
+	// URL is the web URL of the status.
+	URL string `json:"url,omitempty"`
+	// URI is the ActivityPub URI of the status.
+	URI string `json:"uri,omitempty"`
+	// Account is the Webfinger account URI of the status's author, relative to the instance it was retrieved from.
+	Account string `json:"account,omitempty"`
+	// CreatedAt is the time the status was created.
+	CreatedAt string `json:"created_at,omitempty"`
+	// Content is the HTML content of the status.
+	Content string `json:"content"`
+	// Text is the plain text source of the status. It is only available for statuses posted by the account
+	// the status was retrieved with.
+	Text string `json:"text,omitempty"`
+	// SpoilerText is the content warning of the status.
+	SpoilerText string `json:"spoiler_text,omitempty"`
+	// Sensitive is true if the status's media is marked as sensitive.
+	Sensitive bool `json:"sensitive,omitempty"`
+	// Language is the ISO 639 language code of the status.
+	Language string `json:"language,omitempty"`
+	// Visibility is the visibility of the status.
+	Visibility string `json:"visibility"`
+	// InReplyToId is the ID of the status this status is a reply to, if any.
+	InReplyToId string `json:"in_reply_to_id,omitempty"`
+	// InReplyToAccountId is the ID of the account this status is a reply to, if any.
+	InReplyToAccountId string `json:"in_reply_to_account_id,omitempty"`
+	// Media are the media attachments of the status.
+	Media []*StatusMedia `json:"media,omitempty"`
+}
+
+// StatusMedia contains details about a media attachment of an existing Mastodon status.
+type StatusMedia struct {
+	// Id is the ID of the media attachment.
+	Id string `json:"id"`
+	// Type is the type of the media attachment, for example "image" or "video".
+	Type string `json:"type"`
+	// URL is the location of the original media file.
+	URL string `json:"url"`
+	// Description is the alt text of the media attachment.
+	Description string `json:"description,omitempty"`
+}
+
+// GetStatus returns the `Status` for 'str' which may be a status ID or the URL of a status on any Mastodon
+// instance, using the account of 'b'. Status URLs are resolved using the same rules as `MessageOptions.InReplyTo`.
+func (b *MastodonBroadcaster) GetStatus(ctx context.Context, str string) (*Status, error) {
+
+	body, err := b.fetchStatus(ctx, str)
+
+	if err != nil {
+		return nil, err
+	}
+
+	st := statusFromJSON(gjson.ParseBytes(body))
+	status_id := st.Id
+
+	// The plain text source of a status is only available to its author
+
+	source_body, err := b.executeJSON(ctx, "GET", fmt.Sprintf("/api/v1/statuses/%s/source", status_id), &url.Values{})
+
+	if err != nil {
+		b.logger.Debug("Plain text source of status is not available", "id", status_id, "error", err)
+	} else {
+		st.Text = gjson.GetBytes(source_body, "text").String()
+	}
+
+	return st, nil
+}
+
+// fetchStatus returns the body of the Mastodon API response for the status 'str' which may be a status ID or the
+// URL of a status on any Mastodon instance.
+func (b *MastodonBroadcaster) fetchStatus(ctx context.Context, str string) ([]byte, error) {
+
+	status_id, err := b.resolveStatusId(ctx, str)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to resolve status %s, %w", str, withPhase(err, PhaseFetch))
+	}
+
+	body, err := b.executeJSON(ctx, "GET", fmt.Sprintf("/api/v1/statuses/%s", status_id), &url.Values{})
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to retrieve status %s, %w", status_id, withPhase(err, PhaseFetch))
+	}
+
+	if !gjson.GetBytes(body, "id").Exists() {
+		return nil, fmt.Errorf("Failed to derive status ID from response, missing 'id' property")
+	}
+
+	return body, nil
+}
+
+// statusFromJSON returns a new `Status` instance derived from 'r', a Mastodon API status.
+func statusFromJSON(r gjson.Result) *Status {
+
+	st := &Status{
+		Id:                 r.Get("id").String(),
+		URL:                r.Get("url").String(),
+		URI:                r.Get("uri").String(),
+		Account:            r.Get("account.acct").String(),
+		CreatedAt:          r.Get("created_at").String(),
+		Content:            r.Get("content").String(),
+		SpoilerText:        r.Get("spoiler_text").String(),
+		Sensitive:          r.Get("sensitive").Bool(),
+		Language:           r.Get("language").String(),
+		Visibility:         r.Get("visibility").String(),
+		InReplyToId:        r.Get("in_reply_to_id").String(),
+		InReplyToAccountId: r.Get("in_reply_to_account_id").String(),
+		Media:              make([]*StatusMedia, 0),
+	}
+
+	for _, m := range r.Get("media_attachments").Array() {
+
+		media := &StatusMedia{
+			Id:          m.Get("id").String(),
+			Type:        m.Get("type").String(),
+			URL:         m.Get("url").String(),
+			Description: m.Get("description").String(),
+		}
+
+		// Remote media that the instance has not cached only has a remote URL
+
+		if media.URL == "" {
+			media.URL = m.Get("remote_url").String()
+		}
+
+		st.Media = append(st.Media, media)
+	}
+
+	return st
+}
@@ -0,0 +1,120 @@
+package mastodon
+
+import (
+	"fmt"
+	"log/slog"
+	"strings"
+	"unicode"
+)
+
+// normalizeTags converts each element of 'tags' in to a CamelCase hashtag and returns the unique, non-empty, results.
+func normalizeTags(tags []string) ([]string, error) {
+
+	normalized := make([]string, 0)
+	seen := make(map[string]bool)
+
+	for _, t := range tags {
+
+		t = strings.TrimSpace(t)
+
+		if t == "" {
+			continue
+		}
+
+		tag, err := NormalizeHashtag(t)
+
+		if err != nil {
+			return nil, err
+		}
+
+		k := strings.ToLower(tag)
+
+		if seen[k] {
+			continue
+		}
+
+		seen[k] = true
+		normalized = append(normalized, tag)
+	}
+
+	return normalized, nil
+}
+
+// NormalizeHashtag converts 'str' in to a CamelCase hashtag, so that screen readers read each word separately.
+// Words may be separated by spaces, hyphens or changes in case; for example "san francisco", "san-francisco"
+// and "#sanFrancisco" all become "#SanFrancisco". An error is returned if 'str' contains characters other than
+// letters, numbers, underscores or word separators or if it does not contain at least one letter.
+func NormalizeHashtag(str string) (string, error) {
+
+	str = strings.TrimPrefix(strings.TrimSpace(str), "#")
+
+	has_letter := false
+
+	for _, r := range str {
+
+		switch {
+		case unicode.IsLetter(r):
+			has_letter = true
+		case unicode.IsNumber(r), unicode.IsMark(r), r == '_', r == ' ', r == '-':
+			// pass
+		default:
+			return "", fmt.Errorf("Invalid character '%c' in hashtag '%s'", r, str)
+		}
+	}
+
+	if !has_letter {
+		return "", fmt.Errorf("Invalid hashtag '%s', hashtags must contain at least one letter", str)
+	}
+
+	return Hashtag(str), nil
+}
+
+// appendTags appends those elements of 'tags' that are not already present in 'status' to the end of 'status'.
+// Tags are compared case-insensitively. Tags are appended in order until the next tag would cause 'status' to
+// exceed 'max' characters; any remaining tags are skipped and logged to 'logger'.
+func appendTags(status string, tags []string, max int, logger *slog.Logger) string {
+
+	if len(tags) == 0 {
+		return status
+	}
+
+	present := make(map[string]bool)
+
+	for _, t := range extractHashtags(status) {
+		present[strings.ToLower(t)] = true
+	}
+
+	sep := "\n\n"
+
+	if strings.TrimSpace(status) == "" {
+		status = ""
+		sep = ""
+	}
+
+	added := make([]string, 0)
+
+	for _, t := range tags {
+
+		k := strings.ToLower(strings.TrimPrefix(t, "#"))
+
+		if present[k] {
+			continue
+		}
+
+		candidate := status + sep + strings.Join(append(added, t), " ")
+
+		if CountCharacters(candidate) > max {
+			logger.Debug("Skip default hashtag, status would exceed maximum length", "tag", t, "max", max)
+			continue
+		}
+
+		present[k] = true
+		added = append(added, t)
+	}
+
+	if len(added) == 0 {
+		return status
+	}
+
+	return status + sep + strings.Join(added, " ")
+}
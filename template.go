@@ -0,0 +1,244 @@
+package mastodon
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+	"text/template"
+	"time"
+	"unicode"
+
+	"github.com/sfomuseum/runtimevar"
+)
+
+// TemplateVars are the variables passed to the template used to render statuses.
+type TemplateVars struct {
+	// Title is the title of the message being broadcast.
+	Title string
+	// Body is the body of the message being broadcast.
+	Body string
+	// Data is the arbitrary key/value data associated with the message.
+	Data map[string]any
+	// MaxCharacters is the maximum number of characters allowed in a status by the Mastodon instance.
+	MaxCharacters int
+}
+
+// readConfig returns the contents of 'uri' which may be a sfomuseum/runtimevar URI or the path to a file
+// on the local filesystem.
+func readConfig(ctx context.Context, uri string) (string, error) {
+
+	u, err := url.Parse(uri)
+
+	if err != nil || u.Scheme == "" {
+
+		b, err := os.ReadFile(uri)
+
+		if err != nil {
+			return "", err
+		}
+
+		return string(b), nil
+	}
+
+	rt_ctx, rt_cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer rt_cancel()
+
+	return runtimevar.StringVar(rt_ctx, uri)
+}
+
+func parseTemplate(name string, body string) (*template.Template, error) {
+
+	t, err := template.New(name).Funcs(templateFuncs()).Parse(body)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to parse template, %w", err)
+	}
+
+	return t, nil
+}
+
+// renderTemplate executes 't' with 'vars' and returns the result with any leading or trailing whitespace removed.
+func renderTemplate(t *template.Template, vars *TemplateVars) (string, error) {
+
+	var buf strings.Builder
+
+	err := t.Execute(&buf, vars)
+
+	if err != nil {
+		return "", fmt.Errorf("Failed to render template, %w", err)
+	}
+
+	return strings.TrimSpace(buf.String()), nil
+}
+
+// templateFuncs returns the helper functions available to status templates.
+func templateFuncs() template.FuncMap {
+
+	return template.FuncMap{
+		"hashtag":     Hashtag,
+		"hashtags":    hashtags,
+		"truncate":    truncate,
+		"shorten_url": shortenURL,
+		"date":        formatDate,
+		"now":         time.Now,
+	}
+}
+
+// Hashtag converts 'str' in to a CamelCase hashtag. For example "san francisco" becomes "#SanFrancisco".
+// Characters that are not letters, numbers, combining marks (for example the vowel signs of Indic scripts) or
+// underscores are removed. Mastodon does not recognize hashtags made up only of numbers, for example "#2024", so
+// an empty string is returned for them.
+func Hashtag(str string) string {
+
+	str = strings.TrimPrefix(strings.TrimSpace(str), "#")
+
+	var buf strings.Builder
+	upper := true
+
+	for _, r := range str {
+
+		switch {
+		case unicode.IsLetter(r) || unicode.IsNumber(r):
+
+			if upper {
+				r = unicode.ToUpper(r)
+			}
+
+			buf.WriteRune(r)
+			upper = false
+
+		case unicode.IsMark(r), r == '_':
+			buf.WriteRune(r)
+		default:
+			upper = true
+		}
+	}
+
+	tag := buf.String()
+
+	if !strings.ContainsFunc(tag, isHashtagLetter) {
+		return ""
+	}
+
+	return "#" + tag
+}
+
+// isHashtagLetter returns true if 'r' is one of the characters, at least one of which a hashtag must contain.
+func isHashtagLetter(r rune) bool {
+	return unicode.IsLetter(r) || r == '_'
+}
+
+// hashtags converts each element of 'values' in to a hashtag and returns them separated by spaces.
+func hashtags(values any) (string, error) {
+
+	var list []string
+
+	switch v := values.(type) {
+	case []string:
+		list = v
+	case []any:
+
+		for _, i := range v {
+			list = append(list, fmt.Sprintf("%v", i))
+		}
+
+	case string:
+		list = strings.Split(v, ",")
+	default:
+		return "", fmt.Errorf("Unsupported type for hashtags, %T", values)
+	}
+
+	tags := make([]string, 0)
+
+	for _, str := range list {
+
+		t := Hashtag(str)
+
+		if t != "" {
+			tags = append(tags, t)
+		}
+	}
+
+	return strings.Join(tags, " "), nil
+}
+
+// truncate shortens 'str' to at most 'max' characters, as counted by `CountCharacters`, breaking on a word boundary
+// where possible and appending an ellipsis if anything was removed.
+func truncate(max int, str string) string {
+
+	if CountCharacters(str) <= max {
+		return str
+	}
+
+	if max < 1 {
+		return ""
+	}
+
+	if max == 1 {
+		return "…"
+	}
+
+	parts := splitStatus(str, max-1)
+
+	if len(parts) == 0 {
+		return "…"
+	}
+
+	return parts[0] + "…"
+}
+
+// shortenURL removes tracking parameters (utm_*, fbclid and the like), fragments and trailing slashes from 'str'.
+func shortenURL(str string) string {
+
+	u, err := url.Parse(str)
+
+	if err != nil || u.Host == "" {
+		return str
+	}
+
+	q := u.Query()
+
+	for k := range q {
+
+		lower := strings.ToLower(k)
+
+		if strings.HasPrefix(lower, "utm_") || lower == "fbclid" || lower == "gclid" || lower == "mc_cid" || lower == "mc_eid" {
+			q.Del(k)
+		}
+	}
+
+	u.RawQuery = q.Encode()
+	u.Fragment = ""
+	u.Path = strings.TrimRight(u.Path, "/")
+
+	return u.String()
+}
+
+// formatDate formats 'v', which may be a `time.Time` instance or an RFC3339 string, using 'layout'.
+func formatDate(layout string, v any) (string, error) {
+
+	var t time.Time
+
+	switch d := v.(type) {
+	case time.Time:
+		t = d
+	case *time.Time:
+		t = *d
+	case string:
+
+		parsed, err := time.Parse(time.RFC3339, d)
+
+		if err != nil {
+			return "", fmt.Errorf("Failed to parse date '%s', %w", d, err)
+		}
+
+		t = parsed
+
+	default:
+		return "", fmt.Errorf("Unsupported type for date, %T", v)
+	}
+
+	return t.Format(layout), nil
+}
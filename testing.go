@@ -0,0 +1,157 @@
+package mastodon
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"text/template"
+	"time"
+
+	"github.com/aaronland/go-broadcaster"
+)
+
+// The default prefix for statuses posted in testing mode.
+const default_testing_prefix = "this is a test and there may be more / please disregard and apologies for the distraction / meanwhile:"
+
+// testingPolicy defines how statuses are handled when a broadcaster is in testing mode.
+type testingPolicy struct {
+	// A template used to render the prefix for test statuses. If nil no prefix is added.
+	prefix *template.Template
+	// If not empty, the visibility that all test statuses are posted with.
+	visibility string
+	// If greater than zero, the amount of time after which test statuses are deleted.
+	delete_after time.Duration
+}
+
+// newTestingPolicy returns a new `testingPolicy` instance derived from 'opts'.
+func newTestingPolicy(opts *TestingOptions) (*testingPolicy, error) {
+
+	p := &testingPolicy{
+		visibility:   opts.Visibility,
+		delete_after: opts.DeleteAfter,
+	}
+
+	prefix := default_testing_prefix
+
+	if opts.Prefix != "" {
+		prefix = opts.Prefix
+	}
+
+	if !opts.NoPrefix {
+
+		t, err := parseTemplate("testing_prefix", prefix)
+
+		if err != nil {
+			return nil, fmt.Errorf("Invalid testing prefix, %w", err)
+		}
+
+		p.prefix = t
+	}
+
+	if p.visibility != "" {
+
+		err := ensureVisibility(p.visibility)
+
+		if err != nil {
+			return nil, fmt.Errorf("Invalid testing visibility, %w", err)
+		}
+	}
+
+	return p, nil
+}
+
+// applyPrefix renders the testing prefix for 'msg' and prepends it to 'status'.
+func (p *testingPolicy) applyPrefix(msg *broadcaster.Message, opts *MessageOptions, status string) (string, error) {
+
+	if p.prefix == nil {
+		return status, nil
+	}
+
+	vars := &TemplateVars{
+		Title: msg.Title,
+		Body:  msg.Body,
+		Data:  opts.Data,
+	}
+
+	prefix, err := renderTemplate(p.prefix, vars)
+
+	if err != nil {
+		return "", fmt.Errorf("Failed to render testing prefix, %w", err)
+	}
+
+	if prefix == "" {
+		return status, nil
+	}
+
+	return fmt.Sprintf("%s %s", prefix, status), nil
+}
+
+// scheduleDelete deletes the status described by 'rsp' after the testing policy's delete_after duration
+// has elapsed. Deletions happen in the background; use `Close` to wait for them to complete.
+func (b *MastodonBroadcaster) scheduleDelete(rsp *Result) {
+
+	api_method := fmt.Sprintf("/api/v1/statuses/%s", rsp.Id)
+
+	if rsp.ScheduledAt != "" {
+		api_method = fmt.Sprintf("/api/v1/scheduled_statuses/%s", rsp.Id)
+	}
+
+	b.pending.Add(1)
+
+	timer := time.NewTimer(b.testing_policy.delete_after)
+
+	go func() {
+
+		defer b.pending.Done()
+
+		select {
+		case <-timer.C:
+		case <-b.closing:
+			timer.Stop()
+		}
+
+		// Use a fresh context since the one the status was posted with may have been cancelled.
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+
+		_, err := b.executeJSON(ctx, "DELETE", api_method, &url.Values{})
+
+		if err != nil {
+			b.logger.Error("Failed to delete test status", "id", rsp.Id, "error", withPhase(err, PhaseDelete))
+			return
+		}
+
+		b.logger.Info("Deleted test status", "id", rsp.Id)
+	}()
+}
+
+// Close waits for any pending deletions of test statuses, including those of any additional accounts, to complete.
+// If 'ctx' is cancelled before then all pending deletions are performed immediately.
+func (b *MastodonBroadcaster) Close(ctx context.Context) error {
+
+	for _, acct := range b.accounts {
+		acct.Close(ctx)
+	}
+
+	done := make(chan struct{})
+
+	go func() {
+		b.pending.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+	}
+
+	b.close_once.Do(func() {
+		close(b.closing)
+	})
+
+	b.logger.Debug("Deleting pending test statuses early", "reason", ctx.Err())
+
+	<-done
+	return nil
+}
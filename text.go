@@ -0,0 +1,92 @@
+package mastodon
+
+import (
+	"regexp"
+	"strings"
+)
+
+// Mastodon considers URLs to be http or https URLs terminated by whitespace.
+var re_link = regexp.MustCompile(`https?://[^\s]+`)
+
+// Hashtags must be preceded by the start of the text or a character that can not be part of a word or URL.
+var re_hashtag = regexp.MustCompile(`(?:^|[^\p{L}\p{N}_/#&])#([\p{L}\p{N}_]*[\p{L}_][\p{L}\p{N}_]*)`)
+
+// Mentions are either @username or @username@domain.
+var re_mention = regexp.MustCompile(`(?:^|[^\p{L}\p{N}_/@])@([\p{L}\p{N}_]+(?:[.\-]+[\p{L}\p{N}_]+)*)(?:@([\p{L}\p{N}\-]+(?:\.[\p{L}\p{N}\-]+)+))?`)
+
+// extractLinks returns the URLs in 'text'. Trailing punctuation that is unlikely to be part of the URL is removed.
+func extractLinks(text string) []string {
+
+	links := make([]string, 0)
+
+	for _, l := range re_link.FindAllString(text, -1) {
+		links = append(links, trimLink(l))
+	}
+
+	return links
+}
+
+// trimLink removes trailing punctuation that is unlikely to be part of the URL, for example the full stop at the
+// end of a sentence, from 'link' which is expected to be matched by `re_link`.
+func trimLink(link string) string {
+	return strings.TrimRight(link, ".,:;!?)'\"")
+}
+
+// extractHashtags returns the hashtags, without the leading "#", in 'text'.
+func extractHashtags(text string) []string {
+
+	text = re_link.ReplaceAllString(text, " ")
+
+	tags := make([]string, 0)
+
+	for _, m := range re_hashtag.FindAllStringSubmatch(text, -1) {
+		tags = append(tags, m[1])
+	}
+
+	return tags
+}
+
+// mention is an account mentioned in a status.
+type mention struct {
+	// The text of the mention as it appears in the status, including the leading "@".
+	Text string
+	// The username of the account.
+	Username string
+	// The domain of the account. Empty for accounts on the local instance.
+	Domain string
+}
+
+// Acct returns the Webfinger account URI of 'm', relative to the local instance.
+func (m *mention) Acct() string {
+
+	if m.Domain == "" {
+		return m.Username
+	}
+
+	return m.Username + "@" + m.Domain
+}
+
+// extractMentions returns the accounts mentioned in 'text'.
+func extractMentions(text string) []*mention {
+
+	text = re_link.ReplaceAllString(text, " ")
+
+	mentions := make([]*mention, 0)
+
+	for _, m := range re_mention.FindAllStringSubmatch(text, -1) {
+
+		text := "@" + m[1]
+
+		if m[2] != "" {
+			text = text + "@" + m[2]
+		}
+
+		mentions = append(mentions, &mention{
+			Text:     text,
+			Username: m[1],
+			Domain:   m[2],
+		})
+	}
+
+	return mentions
+}
@@ -0,0 +1,125 @@
+package mastodon
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"time"
+)
+
+// TransportOptions defines the HTTP transport used to call the Mastodon API.
+type TransportOptions struct {
+	// RequestTimeout is the maximum amount of time a single HTTP request, including reading the response body, may take.
+	// If zero there is no timeout.
+	RequestTimeout time.Duration
+	// ProxyURL is the URL of an HTTP proxy to send requests through. If empty the proxy defined by the HTTP_PROXY,
+	// HTTPS_PROXY and NO_PROXY environment variables, if any, is used.
+	ProxyURL string
+	// CABundle is the path to a file containing one or more PEM-encoded certificates to trust, in addition to the
+	// system certificates, when verifying the Mastodon instance's TLS certificate.
+	CABundle string
+	// UserAgent is the value of the User-Agent header sent with each request. If empty the Go default is used.
+	UserAgent string
+}
+
+// transportOptionsFromQuery returns a `TransportOptions` instance derived from the "request_timeout", "proxy",
+// "ca_bundle" and "user_agent" parameters in 'q'.
+func transportOptionsFromQuery(q url.Values) (*TransportOptions, error) {
+
+	opts := &TransportOptions{
+		ProxyURL:  q.Get("proxy"),
+		CABundle:  q.Get("ca_bundle"),
+		UserAgent: q.Get("user_agent"),
+	}
+
+	if q.Has("request_timeout") {
+
+		d, err := parseTimeout(q.Get("request_timeout"))
+
+		if err != nil {
+			return nil, fmt.Errorf("Failed to parse ?request_timeout= parameter, %w", err)
+		}
+
+		opts.RequestTimeout = d
+	}
+
+	return opts, nil
+}
+
+// NewHTTPClient returns a new `http.Client` instance configured by 'opts'. The User-Agent property of 'opts' is not
+// applied to the client; use `OAuth2Client.SetUserAgent` instead.
+func NewHTTPClient(opts *TransportOptions) (*http.Client, error) {
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+
+	if opts.ProxyURL != "" {
+
+		proxy_url, err := url.Parse(opts.ProxyURL)
+
+		if err != nil {
+			return nil, fmt.Errorf("Failed to parse proxy URL, %w", err)
+		}
+
+		transport.Proxy = http.ProxyURL(proxy_url)
+	}
+
+	if opts.CABundle != "" {
+
+		pem, err := os.ReadFile(opts.CABundle)
+
+		if err != nil {
+			return nil, fmt.Errorf("Failed to read CA bundle, %w", err)
+		}
+
+		pool, err := x509.SystemCertPool()
+
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("CA bundle %s does not contain any valid certificates", opts.CABundle)
+		}
+
+		transport.TLSClientConfig = &tls.Config{
+			RootCAs: pool,
+		}
+	}
+
+	http_client := &http.Client{
+		Transport: transport,
+		Timeout:   opts.RequestTimeout,
+	}
+
+	return http_client, nil
+}
+
+// parseTimeout parses 'str' as a duration (for example "30s") or, if it has no units, a number of seconds. Negative
+// timeouts are an error.
+func parseTimeout(str string) (time.Duration, error) {
+
+	var d time.Duration
+
+	secs, err := strconv.Atoi(str)
+
+	if err == nil {
+		d = time.Duration(secs) * time.Second
+	} else {
+
+		d, err = time.ParseDuration(str)
+
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	if d < 0 {
+		return 0, fmt.Errorf("Timeout must not be negative")
+	}
+
+	return d, nil
+}